@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"car4race/internal/service"
+)
+
+const usage = `用法: api [命令]
+
+不带命令时启动 HTTP 服务。
+
+命令:
+  reconcile [-apply]   检查 MinIO 与数据库的文件一致性，-apply 时执行清理
+  reindex              重建笔记和课程的全文搜索索引
+  import-notes [-category 分类] [-author 用户ID] <目录>
+                       从 Markdown 目录（如 Obsidian 仓库）批量导入笔记，按 slug 新建或更新
+  set-vip -user-id 用户ID [-months 月数]
+                       为用户开通或续期会员，默认时长见 VIP_MONTHS
+`
+
+// runCommand 执行命令行子命令，返回进程退出码
+func runCommand(args []string, fileService *service.FileService, searchService *service.SearchService, importService *service.NoteImportService, memberService *service.MemberService) int {
+	switch args[0] {
+	case "reconcile":
+		return runReconcile(args[1:], fileService)
+	case "reindex":
+		return runReindex(searchService)
+	case "import-notes":
+		return runImportNotes(args[1:], importService, searchService)
+	case "set-vip":
+		return runSetVIP(args[1:], memberService)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// runReconcile 存储一致性检查，默认 dry-run
+func runReconcile(args []string, fileService *service.FileService) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "删除孤儿对象和失效记录（默认仅输出报告）")
+	fs.Parse(args)
+
+	report, err := fileService.ReconcileStorage(*apply)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile failed: %v\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runReindex 重建全文搜索索引
+func runReindex(searchService *service.SearchService) int {
+	count, err := searchService.Rebuild()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reindex failed after %d docs: %v\n", count, err)
+		return 1
+	}
+	fmt.Printf("indexed %d docs\n", count)
+	return 0
+}
+
+// runImportNotes 从本地目录批量导入笔记，完成后重建搜索索引
+func runImportNotes(args []string, importService *service.NoteImportService, searchService *service.SearchService) int {
+	fs := flag.NewFlagSet("import-notes", flag.ExitOnError)
+	category := fs.Uint("category", 0, "front matter 未指定分类时使用的分类 ID")
+	author := fs.Uint("author", 0, "记录为修订作者的用户 ID（默认 0 表示系统）")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	report, err := importService.ImportDir(fs.Arg(0), service.NoteImportOptions{
+		DefaultCategoryID: *category,
+		AuthorID:          *author,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if report.Imported > 0 {
+		if code := runReindex(searchService); code != 0 {
+			return code
+		}
+	}
+	if report.Skipped > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runSetVIP 开通或续期会员
+func runSetVIP(args []string, memberService *service.MemberService) int {
+	fs := flag.NewFlagSet("set-vip", flag.ExitOnError)
+	userID := fs.Uint("user-id", 0, "用户 ID")
+	months := fs.Int("months", 0, "会员月数（默认 0 表示使用 VIP_MONTHS）")
+	fs.Parse(args)
+	if *userID == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	user, err := memberService.GrantVIP(*userID, *months)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "set-vip failed: %v\n", err)
+		return 1
+	}
+	if user.VIPExpireAt == nil {
+		fmt.Printf("user %d is a permanent vip\n", user.ID)
+		return 0
+	}
+	fmt.Printf("user %d is vip until %s\n", user.ID, user.VIPExpireAt.Format(time.RFC3339))
+	return 0
+}
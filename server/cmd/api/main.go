@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"car4race/internal/config"
 	"car4race/internal/handler"
@@ -30,21 +31,68 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	contentRepo := repository.NewContentRepository(db)
 	courseRepo := repository.NewCourseRepository(db)
+	videoRepo := repository.NewVideoRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+	analyticsRepo := repository.NewAnalyticsRepository(db)
+	dashboardRepo := repository.NewDashboardRepository(db)
+	announcementRepo := repository.NewAnnouncementRepository(db)
+	historyRepo := repository.NewHistoryRepository(db)
 
 	// 初始化服务层
 	userService := service.NewUserService(userRepo, cfg.JWTSecret)
-	contentService := service.NewContentService(contentRepo)
-	courseService := service.NewCourseService(courseRepo, userRepo)
-	fileService, err := service.NewFileService(courseRepo, cfg)
+	contentService := service.NewContentService(contentRepo, userRepo, courseRepo, cfg)
+	tagService := service.NewTagService(tagRepo, contentRepo, courseRepo)
+	courseService := service.NewCourseService(courseRepo, userRepo, cfg)
+	fileService, err := service.NewFileService(courseRepo, videoRepo, cfg)
 	if err != nil {
 		log.Fatalf("Failed to init file service: %v", err)
 	}
 
+	videoService := service.NewVideoService(videoRepo, courseRepo, courseService, fileService, cfg)
+	certService := service.NewCertificateService(courseRepo, userRepo, fileService, cfg)
+	searchService := service.NewSearchService(searchRepo, contentRepo, courseRepo, fileService)
+	markdownService := service.NewMarkdownService()
+	analyticsService := service.NewAnalyticsService(analyticsRepo, cfg)
+	dashboardService := service.NewDashboardService(dashboardRepo, analyticsRepo)
+	imageService := service.NewImageService(fileService)
+	importService := service.NewNoteImportService(contentRepo, contentService, tagService, imageService)
+	announcementService := service.NewAnnouncementService(announcementRepo, userRepo, cfg)
+	memberService := service.NewMemberService(userRepo, announcementService, cfg)
+	homeService := service.NewHomeService(contentService, courseService, announcementService)
+	feedService := service.NewFeedService(contentRepo, courseRepo, cfg)
+	historyService := service.NewHistoryService(historyRepo, cfg)
+
+	// 命令行子命令（如 reconcile），执行完直接退出
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], fileService, searchService, importService, memberService))
+	}
+
+	// 后台任务
+	fileService.StartUploadCleaner(10 * time.Minute)
+	videoService.StartWorkers()
+	contentService.StartPublishScheduler(time.Minute)
+	courseService.StartPublishScheduler(time.Minute)
+	searchService.StartIndexer()
+	analyticsService.StartCollector()
+	historyService.StartPruner()
+
 	// 初始化处理器
-	userHandler := handler.NewUserHandler(userService)
-	contentHandler := handler.NewContentHandler(contentService)
-	courseHandler := handler.NewCourseHandler(courseService, fileService)
-	adminHandler := handler.NewAdminHandler(contentService, courseService, fileService)
+	userHandler := handler.NewUserHandler(userService, memberService)
+	contentHandler := handler.NewContentHandler(contentService, markdownService, analyticsService, historyService)
+	courseHandler := handler.NewCourseHandler(courseService, fileService, certService, markdownService, analyticsService, historyService)
+	videoHandler := handler.NewVideoHandler(videoService)
+	certificateHandler := handler.NewCertificateHandler(certService)
+	searchHandler := handler.NewSearchHandler(searchService)
+	imageHandler := handler.NewImageHandler(fileService, imageService)
+	tagHandler := handler.NewTagHandler(tagService)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService)
+	dashboardHandler := handler.NewDashboardHandler(dashboardService)
+	homeHandler := handler.NewHomeHandler(homeService)
+	feedHandler := handler.NewFeedHandler(feedService)
+	historyHandler := handler.NewHistoryHandler(historyService)
+	announcementHandler := handler.NewAnnouncementHandler(announcementService, homeService)
+	adminHandler := handler.NewAdminHandler(contentService, courseService, fileService, videoService, certService, searchService, tagService, importService, homeService)
 
 	// 设置 Gin 模式
 	if cfg.Env == "production" {
@@ -63,6 +111,12 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// 订阅源与搜索引擎
+	r.GET("/feed/notes.xml", feedHandler.NotesFeed)
+	r.GET("/feed/categories/:file", feedHandler.CategoryFeed)
+	r.GET("/sitemap.xml", feedHandler.Sitemap)
+	r.GET("/robots.txt", feedHandler.Robots)
+
 	// API 路由组
 	api := r.Group("/api/v1")
 	{
@@ -79,28 +133,50 @@ func main() {
 		{
 			protected.GET("/user/profile", userHandler.GetProfile)
 			protected.PUT("/user/profile", userHandler.UpdateProfile)
+			protected.POST("/upload/image", imageHandler.Upload)
 		}
 
 		// ========== 私域视频网站 (HPA) ==========
 		hpa := api.Group("/hpa")
 		{
 			// 公开接口
+			hpa.GET("/home", middleware.OptionalJWTAuth(cfg.JWTSecret), homeHandler.GetHome)
 			hpa.GET("/categories", contentHandler.GetCategories)
 			hpa.GET("/notes", contentHandler.GetNotes)
-			hpa.GET("/notes/:slug", contentHandler.GetNote)
+			hpa.GET("/notes/:slug", middleware.OptionalJWTAuth(cfg.JWTSecret), contentHandler.GetNote)
+			hpa.GET("/search", searchHandler.Search)
+			hpa.GET("/images/*path", imageHandler.Serve)
+			hpa.GET("/tags", tagHandler.GetTags)
+			hpa.GET("/tags/:slug", tagHandler.GetTag)
 			hpa.GET("/courses", courseHandler.GetCourses)
 			hpa.GET("/courses/:slug", middleware.OptionalJWTAuth(cfg.JWTSecret), courseHandler.GetCourse)
+			hpa.GET("/courses/:slug/reviews", middleware.OptionalJWTAuth(cfg.JWTSecret), courseHandler.GetReviews)
+			hpa.GET("/bundles", courseHandler.GetBundles)
+			hpa.GET("/bundles/:slug", middleware.OptionalJWTAuth(cfg.JWTSecret), courseHandler.GetBundle)
+			hpa.GET("/hls/:assetId/*path", videoHandler.Stream) // 签名校验，无需登录
+			hpa.GET("/videos/keys/:keyId", videoHandler.Key)    // 签名校验，播放器请求密钥时不携带登录凭证
+			hpa.GET("/certificates/:serial", certificateHandler.Verify)
+			hpa.GET("/announcements", middleware.OptionalJWTAuth(cfg.JWTSecret), announcementHandler.GetAnnouncements)
+			hpa.POST("/track", middleware.OptionalJWTAuth(cfg.JWTSecret), analyticsHandler.TrackPage)
 
 			// 需要登录
 			hpaAuth := hpa.Group("")
 			hpaAuth.Use(middleware.JWTAuth(cfg.JWTSecret))
 			{
-				hpaAuth.GET("/history", contentHandler.GetBrowseHistory)
+				hpaAuth.GET("/history", historyHandler.GetHistory)
+				hpaAuth.DELETE("/history", historyHandler.ClearHistory)
+				hpaAuth.DELETE("/history/:id", historyHandler.DeleteHistory)
 				hpaAuth.POST("/orders", courseHandler.CreateOrder)
 				hpaAuth.GET("/orders", courseHandler.GetOrders)
 				hpaAuth.POST("/redeem", courseHandler.RedeemCode)
 				hpaAuth.POST("/download", courseHandler.CreateDownload)
 				hpaAuth.GET("/download/:token", courseHandler.Download)
+				hpaAuth.GET("/courses/:slug/progress", courseHandler.GetProgress)
+				hpaAuth.POST("/courses/:slug/progress", courseHandler.ReportProgress)
+				hpaAuth.POST("/courses/:slug/reviews", courseHandler.SubmitReview)
+				hpaAuth.DELETE("/courses/:slug/reviews", courseHandler.DeleteReview)
+				hpaAuth.GET("/courses/:slug/videos/:fileId/play", videoHandler.Play)
+				hpaAuth.GET("/certificates", certificateHandler.GetMine)
 			}
 		}
 
@@ -109,30 +185,102 @@ func main() {
 		admin.Use(middleware.JWTAuth(cfg.JWTSecret))
 		admin.Use(middleware.AdminAuth())
 		{
+			// 概览
+			admin.GET("/dashboard", dashboardHandler.GetDashboard)
+
 			// 分类管理
 			admin.POST("/categories", adminHandler.CreateCategory)
+			admin.PUT("/categories/reorder", adminHandler.ReorderCategories)
 			admin.PUT("/categories/:id", adminHandler.UpdateCategory)
+			admin.PUT("/categories/:id/move", adminHandler.MoveCategory)
 			admin.DELETE("/categories/:id", adminHandler.DeleteCategory)
 
+			// 标签管理
+			admin.GET("/tags", adminHandler.GetTags)
+			admin.POST("/tags", adminHandler.CreateTag)
+			admin.PUT("/tags/:id", adminHandler.UpdateTag)
+			admin.DELETE("/tags/:id", adminHandler.DeleteTag)
+
 			// 笔记管理
 			admin.POST("/notes", adminHandler.CreateNote)
 			admin.PUT("/notes/:id", adminHandler.UpdateNote)
 			admin.DELETE("/notes/:id", adminHandler.DeleteNote)
+			admin.POST("/notes/import", adminHandler.ImportNotes)
+			admin.POST("/notes/:id/preview", adminHandler.CreateNotePreview)
+			admin.GET("/notes/:id/revisions", adminHandler.GetNoteRevisions)
+			admin.GET("/notes/:id/revisions/diff", adminHandler.DiffNoteRevisions)
+			admin.GET("/notes/:id/revisions/:version", adminHandler.GetNoteRevision)
+			admin.POST("/notes/:id/revisions/:version/restore", adminHandler.RestoreNoteRevision)
 
 			// 课程管理
 			admin.GET("/courses", adminHandler.GetCourses)
 			admin.POST("/courses", adminHandler.CreateCourse)
 			admin.PUT("/courses/:id", adminHandler.UpdateCourse)
 			admin.DELETE("/courses/:id", adminHandler.DeleteCourse)
+			admin.POST("/courses/:id/preview", adminHandler.CreateCoursePreview)
+
+			// 套餐管理
+			admin.GET("/bundles", adminHandler.GetBundles)
+			admin.POST("/bundles", adminHandler.CreateBundle)
+			admin.PUT("/bundles/:id", adminHandler.UpdateBundle)
+			admin.DELETE("/bundles/:id", adminHandler.DeleteBundle)
+
+			// 课程大纲
+			admin.GET("/courses/:id/chapters", adminHandler.GetCourseOutline)
+			admin.POST("/courses/:id/chapters", adminHandler.CreateChapter)
+			admin.PUT("/courses/:id/chapters/reorder", adminHandler.ReorderChapters)
+			admin.PUT("/chapters/:id", adminHandler.UpdateChapter)
+			admin.DELETE("/chapters/:id", adminHandler.DeleteChapter)
+			admin.POST("/chapters/:id/lessons", adminHandler.CreateLesson)
+			admin.PUT("/chapters/:id/lessons/reorder", adminHandler.ReorderLessons)
+			admin.PUT("/lessons/:id", adminHandler.UpdateLesson)
+			admin.DELETE("/lessons/:id", adminHandler.DeleteLesson)
 
 			// 课程文件管理
 			admin.POST("/courses/:id/files", adminHandler.UploadCourseFile)
+			admin.POST("/courses/:id/files/presign", adminHandler.PresignCourseFileUpload)
+			admin.POST("/courses/:id/files/confirm", adminHandler.ConfirmCourseFileUpload)
 			admin.GET("/courses/:id/files", adminHandler.GetCourseFiles)
 			admin.DELETE("/courses/:id/files/:fileId", adminHandler.DeleteCourseFile)
+			admin.GET("/courses/:id/files/:fileId/verify", adminHandler.VerifyCourseFile)
+			admin.POST("/courses/:id/files/:fileId/transcode", adminHandler.TranscodeCourseFile)
+			admin.GET("/courses/:id/videos", adminHandler.GetCourseVideos)
+			admin.POST("/courses/:id/videos/rotate-key", adminHandler.RotateCourseVideoKey)
+			admin.GET("/videos/key-access", adminHandler.GetVideoKeyAccessStats)
+
+			// 访问统计
+			admin.GET("/analytics/series", analyticsHandler.GetSeries)
+			admin.GET("/analytics/top", analyticsHandler.GetTop)
+
+			// 存储一致性
+			admin.GET("/storage/reconcile", adminHandler.CheckStorage)
+			admin.POST("/storage/reconcile", adminHandler.ReconcileStorage)
+
+			// 搜索索引
+			admin.POST("/search/reindex", adminHandler.RebuildSearchIndex)
+
+			// 评价管理
+			admin.GET("/reviews", adminHandler.GetReviews)
+			admin.PUT("/reviews/:id", adminHandler.ModerateReview)
+			admin.DELETE("/reviews/:id", adminHandler.DeleteReview)
+
+			// 结业证书
+			admin.GET("/certificates", adminHandler.GetCertificates)
+			admin.POST("/certificates", adminHandler.IssueCertificate)
+			admin.POST("/certificates/:id/revoke", adminHandler.RevokeCertificate)
 
 			// 邀请码管理
 			admin.GET("/invite-codes", adminHandler.GetInviteCodes)
 			admin.POST("/invite-codes", adminHandler.CreateInviteCode)
+
+			// 公告
+			admin.GET("/announcements", announcementHandler.List)
+			admin.POST("/announcements", announcementHandler.Create)
+			admin.PUT("/announcements/:id", announcementHandler.Update)
+			admin.DELETE("/announcements/:id", announcementHandler.Delete)
+
+			// 会员
+			admin.POST("/users/:id/vip", userHandler.GrantVIP)
 		}
 	}
 
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -18,6 +20,32 @@ type Config struct {
 	MinIOBucket    string
 	MinIOUseSSL    bool
 
+	// 上传限制（MB）
+	UploadMaxIntroMB    int64
+	UploadMaxResourceMB int64
+
+	// 视频转码 / HLS 播放
+	FFmpegPath         string
+	FFprobePath        string
+	TranscodeWorkers   int
+	HLSSignSecret      string
+	HLSURLTTLMinutes   int64
+	VideoKeyRotateDays int64 // 课程加密密钥轮换周期，仅影响之后转码的视频
+
+	// 站点
+	SiteURL  string // 对外访问地址，用于生成证书验证链接、订阅源等，生产环境必填，例如 https://example.com
+	SiteName string // 站点名称，用于订阅源标题
+
+	// 结业证书
+	CertificatePercent int // 课程进度达到该百分比时自动颁发证书
+
+	// 浏览记录
+	BrowseHistoryDays int // 浏览记录保留天数
+
+	// 会员
+	VIPMonths       int // 开通会员默认时长（月）
+	VIPAnnounceDays int // 新会员首页播报展示天数
+
 	// 短信服务配置
 	SMSProvider   string // aliyun | tencent
 	SMSAccessKey  string
@@ -40,6 +68,25 @@ func Load() (*Config, error) {
 		MinIOBucket:    getEnv("MINIO_BUCKET", "car4race"),
 		MinIOUseSSL:    getEnvBool("MINIO_USE_SSL", false),
 
+		UploadMaxIntroMB:    getEnvInt64("UPLOAD_MAX_INTRO_MB", 20),
+		UploadMaxResourceMB: getEnvInt64("UPLOAD_MAX_RESOURCE_MB", 4096),
+
+		FFmpegPath:         getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:        getEnv("FFPROBE_PATH", "ffprobe"),
+		TranscodeWorkers:   int(getEnvInt64("TRANSCODE_WORKERS", 1)),
+		HLSSignSecret:      getEnv("HLS_SIGN_SECRET", ""),
+		HLSURLTTLMinutes:   getEnvInt64("HLS_URL_TTL_MINUTES", 120),
+		VideoKeyRotateDays: getEnvInt64("VIDEO_KEY_ROTATE_DAYS", 30),
+
+		SiteURL:            strings.TrimRight(getEnv("SITE_URL", ""), "/"),
+		SiteName:           getEnv("SITE_NAME", "Car4Race"),
+		CertificatePercent: int(getEnvInt64("CERTIFICATE_PERCENT", 100)),
+
+		BrowseHistoryDays: int(getEnvInt64("BROWSE_HISTORY_DAYS", 180)),
+
+		VIPMonths:       int(getEnvInt64("VIP_MONTHS", 12)),
+		VIPAnnounceDays: int(getEnvInt64("VIP_ANNOUNCE_DAYS", 3)),
+
 		SMSProvider:   getEnv("SMS_PROVIDER", "aliyun"),
 		SMSAccessKey:  getEnv("SMS_ACCESS_KEY", ""),
 		SMSSecretKey:  getEnv("SMS_SECRET_KEY", ""),
@@ -47,6 +94,16 @@ func Load() (*Config, error) {
 		SMSTemplateID: getEnv("SMS_TEMPLATE_ID", ""),
 	}
 
+	// 未单独配置时复用 JWT 密钥
+	if cfg.HLSSignSecret == "" {
+		cfg.HLSSignSecret = cfg.JWTSecret
+	}
+
+	// 订阅源、sitemap 和证书链接需要对外地址，生产环境不从请求头推断
+	if cfg.SiteURL == "" && cfg.Env == "production" {
+		return nil, errors.New("SITE_URL is required in production")
+	}
+
 	return cfg, nil
 }
 
@@ -67,3 +124,14 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return n
+	}
+	return defaultValue
+}
@@ -0,0 +1,20 @@
+package config
+
+import "testing"
+
+func TestLoadRequiresSiteURLInProduction(t *testing.T) {
+	t.Setenv("ENV", "production")
+	t.Setenv("SITE_URL", "")
+	if _, err := Load(); err == nil {
+		t.Error("Load() without SITE_URL in production: want error")
+	}
+
+	t.Setenv("SITE_URL", "https://example.com/")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if cfg.SiteURL != "https://example.com" {
+		t.Errorf("SiteURL = %q, want https://example.com", cfg.SiteURL)
+	}
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"car4race/internal/model"
 	"car4race/internal/service"
+	"car4race/pkg/errcode"
 	"car4race/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -16,13 +20,25 @@ type AdminHandler struct {
 	contentService *service.ContentService
 	courseService  *service.CourseService
 	fileService    *service.FileService
+	videoService   *service.VideoService
+	certService    *service.CertificateService
+	searchService  *service.SearchService
+	tagService     *service.TagService
+	importService  *service.NoteImportService
+	homeService    *service.HomeService
 }
 
-func NewAdminHandler(contentService *service.ContentService, courseService *service.CourseService, fileService *service.FileService) *AdminHandler {
+func NewAdminHandler(contentService *service.ContentService, courseService *service.CourseService, fileService *service.FileService, videoService *service.VideoService, certService *service.CertificateService, searchService *service.SearchService, tagService *service.TagService, importService *service.NoteImportService, homeService *service.HomeService) *AdminHandler {
 	return &AdminHandler{
 		contentService: contentService,
 		courseService:  courseService,
 		fileService:    fileService,
+		videoService:   videoService,
+		certService:    certService,
+		searchService:  searchService,
+		tagService:     tagService,
+		importService:  importService,
+		homeService:    homeService,
 	}
 }
 
@@ -52,7 +68,7 @@ func (h *AdminHandler) CreateCategory(c *gin.Context) {
 	}
 
 	if err := h.contentService.CreateCategory(category); err != nil {
-		response.Error(c, http.StatusInternalServerError, "创建失败")
+		respondError(c, err)
 		return
 	}
 
@@ -69,28 +85,162 @@ func (h *AdminHandler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	category := &model.Category{
-		ID:       uint(id),
-		Name:     req.Name,
-		Slug:     req.Slug,
-		ParentID: req.ParentID,
-		Sort:     req.Sort,
+	category, err := h.contentService.UpdateCategory(uint(id), req.Name, req.Slug, req.ParentID, req.Sort)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
-	if err := h.contentService.UpdateCategory(category); err != nil {
-		response.Error(c, http.StatusInternalServerError, "更新失败")
+	response.Success(c, category)
+}
+
+// MoveCategoryRequest 移动分类请求
+type MoveCategoryRequest struct {
+	ParentID *uint `json:"parent_id"` // 为空表示移到顶级
+	Sort     *int  `json:"sort"`      // 为空表示排到末尾
+}
+
+// MoveCategory 移动分类到新的父分类下
+func (h *AdminHandler) MoveCategory(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	var req MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	category, err := h.contentService.MoveCategory(uint(id), req.ParentID, req.Sort)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
 	response.Success(c, category)
 }
 
+// ReorderCategoriesRequest 分类拖拽排序请求，ids 为 parent_id 下排序后的完整顺序
+type ReorderCategoriesRequest struct {
+	ParentID *uint  `json:"parent_id"`
+	IDs      []uint `json:"ids" binding:"required"`
+}
+
+// ReorderCategories 批量排序同级分类
+func (h *AdminHandler) ReorderCategories(c *gin.Context) {
+	var req ReorderCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if err := h.contentService.ReorderCategories(req.ParentID, req.IDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "排序成功"})
+}
+
 // DeleteCategory 删除分类
+// 分类非空时拒绝删除，可通过 reassign_to 指定接收笔记和子分类的分类
 func (h *AdminHandler) DeleteCategory(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 
-	if err := h.contentService.DeleteCategory(uint(id)); err != nil {
-		response.Error(c, http.StatusInternalServerError, "删除失败")
+	var reassignTo *uint
+	if value := c.Query("reassign_to"); value != "" {
+		target, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "接收分类ID无效")
+			return
+		}
+		t := uint(target)
+		reassignTo = &t
+	}
+
+	if err := h.contentService.DeleteCategory(uint(id), reassignTo); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ========== Tag ==========
+
+// TagRequest 创建/更新标签请求
+type TagRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug" binding:"required"`
+	Description string `json:"description"`
+	Sort        int    `json:"sort"`
+}
+
+// GetTags 获取全部标签及使用数
+func (h *AdminHandler) GetTags(c *gin.Context) {
+	tags, err := h.tagService.GetAllTags()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取标签失败")
+		return
+	}
+
+	response.Success(c, tags)
+}
+
+// CreateTag 创建标签
+func (h *AdminHandler) CreateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	tag := &model.Tag{
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		Sort:        req.Sort,
+	}
+
+	if err := h.tagService.CreateTag(tag); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, tag)
+}
+
+// UpdateTag 更新标签
+func (h *AdminHandler) UpdateTag(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	tag := &model.Tag{
+		ID:          uint(id),
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		Sort:        req.Sort,
+	}
+
+	if err := h.tagService.UpdateTag(tag); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, tag)
+}
+
+// DeleteTag 删除标签
+func (h *AdminHandler) DeleteTag(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.tagService.DeleteTag(uint(id)); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -107,8 +257,15 @@ type CreateNoteRequest struct {
 	Summary    string `json:"summary"`
 	Content    string `json:"content"`
 	CoverImage string `json:"cover_image"`
-	IsPublic   bool   `json:"is_public"`
+	IsPublic   bool   `json:"is_public"`  // 未传 status 时兼容旧接口：true 为发布，false 为草稿
+	Status     string `json:"status"`     // draft | scheduled | published | archived
+	PublishAt  string `json:"publish_at"` // RFC3339 格式，定时发布时必填
 	Sort       int    `json:"sort"`
+	Version    int    `json:"version"` // 更新时必填：编辑者读取到的版本号
+	TagIDs     []uint `json:"tag_ids"` // 标签 ID，不传则不修改
+
+	AccessLevel    string `json:"access_level"`     // public | login | vip | course，默认 public
+	AccessCourseID *uint  `json:"access_course_id"` // access_level 为 course 时必填
 }
 
 // CreateNote 创建笔记
@@ -118,6 +275,11 @@ func (h *AdminHandler) CreateNote(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	publishAt, err := parseTime(req.PublishAt)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "发布时间格式应为 RFC3339")
+		return
+	}
 
 	note := &model.Note{
 		CategoryID: req.CategoryID,
@@ -127,13 +289,33 @@ func (h *AdminHandler) CreateNote(c *gin.Context) {
 		Content:    req.Content,
 		CoverImage: req.CoverImage,
 		IsPublic:   req.IsPublic,
+		Status:     req.Status,
+		PublishAt:  publishAt,
 		Sort:       req.Sort,
+
+		AccessLevel:    req.AccessLevel,
+		AccessCourseID: req.AccessCourseID,
 	}
 
-	if err := h.contentService.CreateNote(note); err != nil {
-		response.Error(c, http.StatusInternalServerError, "创建失败")
+	tags, err := h.tagService.ResolveTags(req.TagIDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := h.contentService.CreateNote(note, c.GetUint("user_id")); err != nil {
+		respondError(c, err)
 		return
 	}
+	if tags != nil {
+		if err := h.tagService.SetNoteTags(note.ID, tags); err != nil {
+			respondError(c, err)
+			return
+		}
+		note.Tags = tags
+	}
+	h.searchService.RefreshNote(note.ID)
+	h.homeService.Invalidate()
 
 	response.Success(c, note)
 }
@@ -153,6 +335,20 @@ func (h *AdminHandler) UpdateNote(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	publishAt, err := parseTime(req.PublishAt)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "发布时间格式应为 RFC3339")
+		return
+	}
+	if req.Version < 1 {
+		response.Error(c, http.StatusBadRequest, "缺少版本号")
+		return
+	}
+	tags, err := h.tagService.ResolveTags(req.TagIDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
 	note.CategoryID = req.CategoryID
 	note.Title = req.Title
@@ -161,12 +357,25 @@ func (h *AdminHandler) UpdateNote(c *gin.Context) {
 	note.Content = req.Content
 	note.CoverImage = req.CoverImage
 	note.IsPublic = req.IsPublic
+	note.Status = req.Status
+	note.PublishAt = publishAt
 	note.Sort = req.Sort
+	note.AccessLevel = req.AccessLevel
+	note.AccessCourseID = req.AccessCourseID
 
-	if err := h.contentService.UpdateNote(note); err != nil {
-		response.Error(c, http.StatusInternalServerError, "更新失败")
+	if err := h.contentService.UpdateNote(note, req.Version, c.GetUint("user_id")); err != nil {
+		respondError(c, err)
 		return
 	}
+	if tags != nil {
+		if err := h.tagService.SetNoteTags(note.ID, tags); err != nil {
+			respondError(c, err)
+			return
+		}
+		note.Tags = tags
+	}
+	h.searchService.RefreshNote(note.ID)
+	h.homeService.Invalidate()
 
 	response.Success(c, note)
 }
@@ -179,10 +388,133 @@ func (h *AdminHandler) DeleteNote(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "删除失败")
 		return
 	}
+	h.searchService.RefreshNote(uint(id))
+	h.homeService.Invalidate()
 
 	response.Success(c, gin.H{"message": "删除成功"})
 }
 
+// CreateNotePreview 生成笔记预览链接（草稿无需发布即可预览）
+func (h *AdminHandler) CreateNotePreview(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	link, err := h.contentService.CreateNotePreview(uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, link)
+}
+
+// GetNoteRevisions 获取笔记修订记录
+func (h *AdminHandler) GetNoteRevisions(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	revisions, err := h.contentService.GetNoteRevisions(uint(id))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, revisions)
+}
+
+// GetNoteRevision 获取笔记指定版本的完整内容
+func (h *AdminHandler) GetNoteRevision(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	version, _ := strconv.Atoi(c.Param("version"))
+
+	revision, err := h.contentService.GetNoteRevision(uint(id), version)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, revision)
+}
+
+// DiffNoteRevisions 对比笔记的两个版本
+func (h *AdminHandler) DiffNoteRevisions(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	from, err1 := strconv.Atoi(c.Query("from"))
+	to, err2 := strconv.Atoi(c.Query("to"))
+	if err1 != nil || err2 != nil {
+		response.Error(c, http.StatusBadRequest, "请指定要对比的版本")
+		return
+	}
+
+	diff, err := h.contentService.DiffNoteRevisions(uint(id), from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, diff)
+}
+
+// RestoreNoteRevisionRequest 恢复版本请求
+type RestoreNoteRevisionRequest struct {
+	Version int `json:"version" binding:"required"` // 笔记当前版本号，用于乐观锁
+}
+
+// RestoreNoteRevision 将笔记恢复到指定版本（生成新版本，不删除历史）
+func (h *AdminHandler) RestoreNoteRevision(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	version, _ := strconv.Atoi(c.Param("version"))
+
+	var req RestoreNoteRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "缺少版本号")
+		return
+	}
+
+	note, err := h.contentService.RestoreNoteRevision(uint(id), version, req.Version, c.GetUint("user_id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.searchService.RefreshNote(note.ID)
+	h.homeService.Invalidate()
+
+	response.Success(c, note)
+}
+
+// ImportNotes 批量导入笔记：上传 zip 包（Markdown 文件及其引用的图片）
+// 可选 category_id 为 front matter 未指定分类时的默认分类
+func (h *AdminHandler) ImportNotes(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "请选择文件")
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(file.Filename), ".zip") {
+		response.Error(c, http.StatusBadRequest, "仅支持 zip 文件")
+		return
+	}
+	categoryID, _ := strconv.ParseUint(c.PostForm("category_id"), 10, 64)
+
+	f, err := file.Open()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "读取文件失败")
+		return
+	}
+	defer f.Close()
+
+	report, err := h.importService.ImportZip(f, file.Size, service.NoteImportOptions{
+		DefaultCategoryID: uint(categoryID),
+		AuthorID:          c.GetUint("user_id"),
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.searchService.RefreshNotes(report.NoteIDs)
+	h.homeService.Invalidate()
+
+	response.Success(c, report)
+}
+
 // ========== Course ==========
 
 // CreateCourseRequest 创建课程请求
@@ -193,8 +525,11 @@ type CreateCourseRequest struct {
 	CoverImage  string  `json:"cover_image"`
 	Price       float64 `json:"price" binding:"required"`
 	OrigPrice   float64 `json:"orig_price"`
-	IsPublic    bool    `json:"is_public"`
+	IsPublic    bool    `json:"is_public"`  // 未传 status 时兼容旧接口：true 为发布，false 为草稿
+	Status      string  `json:"status"`     // draft | scheduled | published | archived
+	PublishAt   string  `json:"publish_at"` // RFC3339 格式，定时发布时必填
 	Sort        int     `json:"sort"`
+	TagIDs      []uint  `json:"tag_ids"` // 标签 ID，不传则不修改
 }
 
 // GetCourses 获取课程列表（管理后台）
@@ -223,6 +558,11 @@ func (h *AdminHandler) CreateCourse(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	publishAt, err := parseTime(req.PublishAt)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "发布时间格式应为 RFC3339")
+		return
+	}
 
 	course := &model.Course{
 		Title:       req.Title,
@@ -232,13 +572,30 @@ func (h *AdminHandler) CreateCourse(c *gin.Context) {
 		Price:       req.Price,
 		OrigPrice:   req.OrigPrice,
 		IsPublic:    req.IsPublic,
+		Status:      req.Status,
+		PublishAt:   publishAt,
 		Sort:        req.Sort,
 	}
 
+	tags, err := h.tagService.ResolveTags(req.TagIDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
 	if err := h.courseService.CreateCourse(course); err != nil {
-		response.Error(c, http.StatusInternalServerError, "创建失败")
+		respondError(c, err)
 		return
 	}
+	if tags != nil {
+		if err := h.tagService.SetCourseTags(course.ID, tags); err != nil {
+			respondError(c, err)
+			return
+		}
+		course.Tags = tags
+	}
+	h.searchService.RefreshCourse(course.ID)
+	h.homeService.Invalidate()
 
 	response.Success(c, course)
 }
@@ -258,6 +615,11 @@ func (h *AdminHandler) UpdateCourse(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	publishAt, err := parseTime(req.PublishAt)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "发布时间格式应为 RFC3339")
+		return
+	}
 
 	course.Title = req.Title
 	course.Slug = req.Slug
@@ -266,12 +628,29 @@ func (h *AdminHandler) UpdateCourse(c *gin.Context) {
 	course.Price = req.Price
 	course.OrigPrice = req.OrigPrice
 	course.IsPublic = req.IsPublic
+	course.Status = req.Status
+	course.PublishAt = publishAt
 	course.Sort = req.Sort
 
+	tags, err := h.tagService.ResolveTags(req.TagIDs)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
 	if err := h.courseService.UpdateCourse(course); err != nil {
-		response.Error(c, http.StatusInternalServerError, "更新失败")
+		respondError(c, err)
 		return
 	}
+	if tags != nil {
+		if err := h.tagService.SetCourseTags(course.ID, tags); err != nil {
+			respondError(c, err)
+			return
+		}
+		course.Tags = tags
+	}
+	h.searchService.RefreshCourse(course.ID)
+	h.homeService.Invalidate()
 
 	response.Success(c, course)
 }
@@ -284,79 +663,537 @@ func (h *AdminHandler) DeleteCourse(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "删除失败")
 		return
 	}
+	h.searchService.RefreshCourse(uint(id))
+	h.homeService.Invalidate()
 
 	response.Success(c, gin.H{"message": "删除成功"})
 }
 
-// ========== InviteCode ==========
-
-// CreateInviteCodeRequest 创建邀请码请求
-type CreateInviteCodeRequest struct {
-	CourseID uint   `json:"course_id" binding:"required"`
-	MaxUses  int    `json:"max_uses"`
-	ExpireAt string `json:"expire_at"` // RFC3339 格式
-}
-
-// GetInviteCodes 获取邀请码列表
-func (h *AdminHandler) GetInviteCodes(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+// CreateCoursePreview 生成课程预览链接（草稿无需发布即可预览）
+func (h *AdminHandler) CreateCoursePreview(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 
-	codes, total, err := h.courseService.GetAllInviteCodes(page, pageSize)
+	link, err := h.courseService.CreateCoursePreview(uint(id))
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "获取邀请码失败")
+		respondError(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{
-		"list":      codes,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	response.Success(c, link)
 }
 
-// CreateInviteCode 创建邀请码
-func (h *AdminHandler) CreateInviteCode(c *gin.Context) {
-	var req CreateInviteCodeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, "参数错误")
-		return
-	}
+// ========== Chapter / Lesson ==========
 
-	maxUses := req.MaxUses
-	if maxUses <= 0 {
-		maxUses = 1
-	}
+// ChapterRequest 章节请求
+type ChapterRequest struct {
+	Title string `json:"title" binding:"required"`
+	Sort  int    `json:"sort"`
+}
 
-	var expireAt *time.Time
-	if req.ExpireAt != "" {
-		t, err := time.Parse(time.RFC3339, req.ExpireAt)
-		if err == nil {
-			expireAt = &t
-		}
+// LessonRequest 课时请求
+type LessonRequest struct {
+	ChapterID     uint   `json:"chapter_id"` // 更新时可移动到其他章节
+	Title         string `json:"title" binding:"required"`
+	Duration      int    `json:"duration"`
+	FileID        *uint  `json:"file_id"`
+	IsFreePreview bool   `json:"is_free_preview"`
+	Sort          int    `json:"sort"`
+}
+
+// ReorderRequest 拖拽排序请求，ids 为排序后的完整顺序
+type ReorderRequest struct {
+	IDs []uint `json:"ids" binding:"required"`
+}
+
+// GetCourseOutline 获取课程大纲
+func (h *AdminHandler) GetCourseOutline(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
 	}
 
-	code, err := h.courseService.CreateInviteCode(req.CourseID, maxUses, expireAt)
+	chapters, err := h.courseService.GetCourseOutline(uint(courseID), true)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "创建失败")
+		response.Error(c, http.StatusInternalServerError, "获取大纲失败")
 		return
 	}
 
-	response.Success(c, code)
+	response.Success(c, chapters)
 }
 
-// ========== CourseFile ==========
-
-// UploadCourseFile 上传课程文件
-func (h *AdminHandler) UploadCourseFile(c *gin.Context) {
+// CreateChapter 创建章节
+func (h *AdminHandler) CreateChapter(c *gin.Context) {
 	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
 		response.Error(c, http.StatusBadRequest, "课程ID无效")
 		return
 	}
 
-	fileType := c.DefaultPostForm("file_type", "resource") // intro | resource
+	var req ChapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	chapter := &model.Chapter{
+		CourseID: uint(courseID),
+		Title:    req.Title,
+		Sort:     req.Sort,
+	}
+
+	if err := h.courseService.CreateChapter(chapter); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, chapter)
+}
+
+// UpdateChapter 更新章节
+func (h *AdminHandler) UpdateChapter(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	chapter, err := h.courseService.GetChapterByID(uint(id))
+	if err != nil {
+		response.Error(c, http.StatusNotFound, "章节不存在")
+		return
+	}
+
+	var req ChapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	chapter.Title = req.Title
+	chapter.Sort = req.Sort
+
+	if err := h.courseService.UpdateChapter(chapter); err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新失败")
+		return
+	}
+
+	response.Success(c, chapter)
+}
+
+// DeleteChapter 删除章节（同时删除其课时）
+func (h *AdminHandler) DeleteChapter(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.courseService.DeleteChapter(uint(id)); err != nil {
+		response.Error(c, http.StatusInternalServerError, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ReorderChapters 拖拽排序章节
+func (h *AdminHandler) ReorderChapters(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if err := h.courseService.ReorderChapters(uint(courseID), req.IDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "排序成功"})
+}
+
+// CreateLesson 创建课时
+func (h *AdminHandler) CreateLesson(c *gin.Context) {
+	chapterID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "章节ID无效")
+		return
+	}
+
+	var req LessonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	lesson := &model.Lesson{
+		ChapterID:     uint(chapterID),
+		Title:         req.Title,
+		Duration:      req.Duration,
+		FileID:        req.FileID,
+		IsFreePreview: req.IsFreePreview,
+		Sort:          req.Sort,
+	}
+
+	if err := h.courseService.CreateLesson(lesson); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, lesson)
+}
+
+// UpdateLesson 更新课时
+func (h *AdminHandler) UpdateLesson(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	lesson, err := h.courseService.GetLessonByID(uint(id))
+	if err != nil {
+		response.Error(c, http.StatusNotFound, "课时不存在")
+		return
+	}
+
+	var req LessonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if req.ChapterID > 0 {
+		lesson.ChapterID = req.ChapterID
+	}
+	lesson.Title = req.Title
+	lesson.Duration = req.Duration
+	lesson.FileID = req.FileID
+	lesson.IsFreePreview = req.IsFreePreview
+	lesson.Sort = req.Sort
+
+	if err := h.courseService.UpdateLesson(lesson); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, lesson)
+}
+
+// DeleteLesson 删除课时
+func (h *AdminHandler) DeleteLesson(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.courseService.DeleteLesson(uint(id)); err != nil {
+		response.Error(c, http.StatusInternalServerError, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ReorderLessons 拖拽排序课时（支持从其他章节拖入）
+func (h *AdminHandler) ReorderLessons(c *gin.Context) {
+	chapterID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "章节ID无效")
+		return
+	}
+
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if err := h.courseService.ReorderLessons(uint(chapterID), req.IDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "排序成功"})
+}
+
+// ========== InviteCode ==========
+
+// CreateInviteCodeRequest 创建邀请码请求
+type CreateInviteCodeRequest struct {
+	CourseID uint   `json:"course_id" binding:"required"`
+	MaxUses  int    `json:"max_uses"`
+	ExpireAt string `json:"expire_at"` // RFC3339 格式
+}
+
+// GetInviteCodes 获取邀请码列表
+func (h *AdminHandler) GetInviteCodes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	codes, total, err := h.courseService.GetAllInviteCodes(page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取邀请码失败")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      codes,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// CreateInviteCode 创建邀请码
+func (h *AdminHandler) CreateInviteCode(c *gin.Context) {
+	var req CreateInviteCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	maxUses := req.MaxUses
+	if maxUses <= 0 {
+		maxUses = 1
+	}
+
+	var expireAt *time.Time
+	if req.ExpireAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpireAt)
+		if err == nil {
+			expireAt = &t
+		}
+	}
+
+	code, err := h.courseService.CreateInviteCode(req.CourseID, maxUses, expireAt)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "创建失败")
+		return
+	}
+
+	response.Success(c, code)
+}
+
+// ========== Bundle ==========
+
+// BundleRequest 创建/更新套餐请求
+type BundleRequest struct {
+	Title       string  `json:"title" binding:"required"`
+	Slug        string  `json:"slug" binding:"required"`
+	Description string  `json:"description"`
+	CoverImage  string  `json:"cover_image"`
+	Price       float64 `json:"price" binding:"min=0"`
+	IsPublic    bool    `json:"is_public"`
+	Sort        int     `json:"sort"`
+	CourseIDs   []uint  `json:"course_ids" binding:"required"`
+}
+
+// GetBundles 获取套餐列表
+func (h *AdminHandler) GetBundles(c *gin.Context) {
+	bundles, err := h.courseService.GetAllBundles()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取套餐失败")
+		return
+	}
+
+	response.Success(c, bundles)
+}
+
+// CreateBundle 创建套餐
+func (h *AdminHandler) CreateBundle(c *gin.Context) {
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	bundle := &model.Bundle{}
+	applyBundleRequest(bundle, &req)
+
+	if err := h.courseService.SaveBundle(bundle, req.CourseIDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, bundle)
+}
+
+// UpdateBundle 更新套餐
+func (h *AdminHandler) UpdateBundle(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	bundle, err := h.courseService.GetBundleByID(uint(id))
+	if err != nil {
+		response.Error(c, http.StatusNotFound, "套餐不存在")
+		return
+	}
+
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	applyBundleRequest(bundle, &req)
+
+	if err := h.courseService.SaveBundle(bundle, req.CourseIDs); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, bundle)
+}
+
+// DeleteBundle 删除套餐
+func (h *AdminHandler) DeleteBundle(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.courseService.DeleteBundle(uint(id)); err != nil {
+		response.Error(c, http.StatusInternalServerError, "删除失败")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// applyBundleRequest 将请求字段写入套餐
+func applyBundleRequest(bundle *model.Bundle, req *BundleRequest) {
+	bundle.Title = req.Title
+	bundle.Slug = req.Slug
+	bundle.Description = req.Description
+	bundle.CoverImage = req.CoverImage
+	bundle.Price = req.Price
+	bundle.IsPublic = req.IsPublic
+	bundle.Sort = req.Sort
+}
+
+// ========== Review ==========
+
+// ModerateReviewRequest 评价审核请求，未传的字段保持不变
+type ModerateReviewRequest struct {
+	IsHidden *bool `json:"is_hidden"`
+	IsPinned *bool `json:"is_pinned"`
+}
+
+// GetReviews 获取评价列表，可按课程和隐藏状态筛选
+func (h *AdminHandler) GetReviews(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	courseID, _ := strconv.ParseUint(c.Query("course_id"), 10, 64)
+
+	var hidden *bool
+	if v := c.Query("hidden"); v != "" {
+		b := v == "true" || v == "1"
+		hidden = &b
+	}
+
+	reviews, total, err := h.courseService.GetAllReviews(uint(courseID), hidden, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取评价失败")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      reviews,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ModerateReview 隐藏/置顶评价
+func (h *AdminHandler) ModerateReview(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	review, err := h.courseService.ModerateReview(uint(id), req.IsHidden, req.IsPinned)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// DeleteReview 删除评价
+func (h *AdminHandler) DeleteReview(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.courseService.DeleteReview(uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ========== Certificate ==========
+
+// IssueCertificateRequest 手动颁发证书请求
+type IssueCertificateRequest struct {
+	UserID   uint   `json:"user_id" binding:"required"`
+	CourseID uint   `json:"course_id" binding:"required"`
+	Name     string `json:"name"` // 证书姓名，留空使用用户昵称
+}
+
+// GetCertificates 获取证书列表
+func (h *AdminHandler) GetCertificates(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	courseID, _ := strconv.ParseUint(c.Query("course_id"), 10, 64)
+
+	certs, total, err := h.certService.GetAllCertificates(uint(courseID), page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取证书失败")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      certs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// IssueCertificate 手动标记学员完成课程并颁发证书
+func (h *AdminHandler) IssueCertificate(c *gin.Context) {
+	var req IssueCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	cert, err := h.certService.Issue(req.UserID, req.CourseID, req.Name, "admin")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, cert)
+}
+
+// RevokeCertificate 吊销证书
+func (h *AdminHandler) RevokeCertificate(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.certService.Revoke(uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "已吊销"})
+}
+
+// ========== CourseFile ==========
+
+// UploadCourseFile 上传课程文件
+func (h *AdminHandler) UploadCourseFile(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+
+	fileType := c.DefaultPostForm("file_type", "resource") // intro | resource
 	if fileType != "intro" && fileType != "resource" {
 		response.Error(c, http.StatusBadRequest, "文件类型无效")
 		return
@@ -370,10 +1207,96 @@ func (h *AdminHandler) UploadCourseFile(c *gin.Context) {
 
 	courseFile, err := h.fileService.UploadCourseFile(uint(courseID), fileType, file)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err.Error())
+		respondError(c, err)
+		return
+	}
+
+	// 视频文件进入转码队列
+	if err := h.videoService.EnqueueFile(courseFile); err != nil {
+		log.Printf("enqueue transcode for file %d failed: %v", courseFile.ID, err)
+	}
+	// 介绍文件纳入搜索索引
+	if courseFile.FileType == "intro" {
+		h.searchService.RefreshCourse(courseFile.CourseID)
+	}
+
+	response.Success(c, courseFile)
+}
+
+// PresignUploadRequest 申请直传链接请求
+type PresignUploadRequest struct {
+	FileType string `json:"file_type"` // intro | resource
+	FileName string `json:"file_name" binding:"required"`
+	FileSize int64  `json:"file_size" binding:"required"`
+	Checksum string `json:"checksum" binding:"required"` // SHA-256（十六进制）
+	Method   string `json:"method"`                      // put | post，默认 put
+}
+
+// PresignCourseFileUpload 申请课程文件直传链接
+func (h *AdminHandler) PresignCourseFileUpload(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+
+	var req PresignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if req.FileType == "" {
+		req.FileType = "resource"
+	}
+	if req.FileType != "intro" && req.FileType != "resource" {
+		response.Error(c, http.StatusBadRequest, "文件类型无效")
+		return
+	}
+
+	upload, err := h.fileService.CreatePresignedUpload(uint(courseID), req.FileType, req.FileName, req.FileSize, req.Checksum, req.Method)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, upload)
+}
+
+// ConfirmUploadRequest 确认直传请求
+type ConfirmUploadRequest struct {
+	ObjectKey string `json:"object_key" binding:"required"`
+}
+
+// ConfirmCourseFileUpload 确认课程文件直传完成
+func (h *AdminHandler) ConfirmCourseFileUpload(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
 		return
 	}
 
+	var req ConfirmUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	courseFile, err := h.fileService.ConfirmUpload(uint(courseID), req.ObjectKey)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// 视频文件进入转码队列
+	if err := h.videoService.EnqueueFile(courseFile); err != nil {
+		log.Printf("enqueue transcode for file %d failed: %v", courseFile.ID, err)
+	}
+	// 介绍文件纳入搜索索引
+	if courseFile.FileType == "intro" {
+		h.searchService.RefreshCourse(courseFile.CourseID)
+	}
+
 	response.Success(c, courseFile)
 }
 
@@ -407,5 +1330,156 @@ func (h *AdminHandler) DeleteCourseFile(c *gin.Context) {
 		return
 	}
 
+	// 删除的可能是介绍文件，刷新课程索引
+	if courseID, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil {
+		h.searchService.RefreshCourse(uint(courseID))
+	}
+
 	response.Success(c, gin.H{"message": "删除成功"})
 }
+
+// VerifyCourseFile 校验课程文件完整性
+func (h *AdminHandler) VerifyCourseFile(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "文件ID无效")
+		return
+	}
+
+	result, err := h.fileService.VerifyCourseFile(uint(fileID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// ========== Video ==========
+
+// GetCourseVideos 获取课程视频转码状态
+func (h *AdminHandler) GetCourseVideos(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+
+	assets, err := h.videoService.GetCourseAssets(uint(courseID))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取视频失败")
+		return
+	}
+
+	response.Success(c, assets)
+}
+
+// TranscodeCourseFile 重新转码课程视频
+func (h *AdminHandler) TranscodeCourseFile(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "文件ID无效")
+		return
+	}
+
+	asset, err := h.videoService.Retranscode(uint(courseID), uint(fileID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, asset)
+}
+
+// RotateCourseVideoKey 轮换课程视频密钥并重新加密
+func (h *AdminHandler) RotateCourseVideoKey(c *gin.Context) {
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "课程ID无效")
+		return
+	}
+
+	count, err := h.videoService.RotateCourseKey(uint(courseID))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "密钥已轮换", "retranscode": count})
+}
+
+// GetVideoKeyAccessStats 获取视频密钥请求统计
+func (h *AdminHandler) GetVideoKeyAccessStats(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+
+	stats, err := h.videoService.GetKeyAccessStats(hours)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取统计失败")
+		return
+	}
+
+	response.Success(c, stats)
+}
+
+// ========== Storage ==========
+
+// CheckStorage 检查存储一致性（仅报告，不做修改）
+func (h *AdminHandler) CheckStorage(c *gin.Context) {
+	report, err := h.fileService.ReconcileStorage(false)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// ReconcileStorage 修复存储不一致（删除孤儿对象和失效记录）
+func (h *AdminHandler) ReconcileStorage(c *gin.Context) {
+	report, err := h.fileService.ReconcileStorage(true)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// ========== Search ==========
+
+// RebuildSearchIndex 重建全文搜索索引
+func (h *AdminHandler) RebuildSearchIndex(c *gin.Context) {
+	count, err := h.searchService.Rebuild()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"indexed": count})
+}
+
+// respondError 业务错误返回对应错误码，其余按服务端错误处理
+func respondError(c *gin.Context, err error) {
+	if errcode.GetCode(err) != 0 {
+		response.ErrorFromErr(c, err)
+		return
+	}
+	response.Error(c, http.StatusInternalServerError, err.Error())
+}
+
+// parseTime 解析 RFC3339 时间，为空时返回 nil
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"car4race/internal/model"
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	service *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(service *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{service: service}
+}
+
+// trackEvent 记录一次浏览；登录状态来自 JWT 或可选登录中间件
+func trackEvent(c *gin.Context, analytics *service.AnalyticsService, eventType string, target uint) {
+	analytics.Track(service.AnalyticsEvent{
+		Type:      eventType,
+		Target:    strconv.FormatUint(uint64(target), 10),
+		UserID:    c.GetUint("user_id"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// TrackPageRequest 页面浏览上报
+type TrackPageRequest struct {
+	Path string `json:"path" binding:"required"`
+}
+
+// TrackPage 前端页面浏览上报（单页应用路由切换时调用）
+func (h *AnalyticsHandler) TrackPage(c *gin.Context) {
+	var req TrackPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	path, err := service.NormalizePagePath(req.Path)
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	h.service.Track(service.AnalyticsEvent{
+		Type:      model.EventPage,
+		Target:    path,
+		UserID:    c.GetUint("user_id"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// ========== 管理后台 ==========
+
+// GetSeries 每日 PV/UV 时间序列
+// type 为 page | course | note | download；target 为页面路径或 ID，不传表示该类型汇总
+func (h *AnalyticsHandler) GetSeries(c *gin.Context) {
+	series, err := h.service.GetSeries(c.DefaultQuery("type", "page"), c.Query("target"), c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, series)
+}
+
+// GetTop 区间内 PV 最高的页面、课程、笔记或下载文件
+func (h *AnalyticsHandler) GetTop(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	list, err := h.service.GetTop(c.DefaultQuery("type", "course"), c.Query("from"), c.Query("to"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, list)
+}
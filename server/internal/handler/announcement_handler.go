@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnnouncementHandler struct {
+	service     *service.AnnouncementService
+	homeService *service.HomeService
+}
+
+func NewAnnouncementHandler(service *service.AnnouncementService, homeService *service.HomeService) *AnnouncementHandler {
+	return &AnnouncementHandler{service: service, homeService: homeService}
+}
+
+// AnnouncementRequest 创建/更新公告请求
+type AnnouncementRequest struct {
+	Content  string `json:"content" binding:"required"`
+	Link     string `json:"link"`
+	Type     string `json:"type"`     // system | vip | promo，默认 system
+	Audience string `json:"audience"` // all | login | vip，默认 all
+	Pinned   bool   `json:"pinned"`
+	StartAt  string `json:"start_at"` // RFC3339 格式，为空表示立即开始（更新时保留原值）
+	EndAt    string `json:"end_at"`   // RFC3339 格式，为空表示长期有效
+}
+
+// toInput 转换为服务层参数，时间格式错误时返回 false
+func (req *AnnouncementRequest) toInput() (service.AnnouncementInput, bool) {
+	input := service.AnnouncementInput{
+		Content:  req.Content,
+		Link:     req.Link,
+		Type:     req.Type,
+		Audience: req.Audience,
+		Pinned:   req.Pinned,
+	}
+	var startErr, endErr error
+	input.StartAt, startErr = parseTime(req.StartAt)
+	input.EndAt, endErr = parseTime(req.EndAt)
+	return input, startErr == nil && endErr == nil
+}
+
+// GetAnnouncements 获取当前展示中的公告（按登录状态和会员身份过滤）
+func (h *AnnouncementHandler) GetAnnouncements(c *gin.Context) {
+	list, err := h.service.GetActive(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取公告失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// ========== 管理后台 ==========
+
+// List 获取公告列表
+func (h *AnnouncementHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	list, total, err := h.service.List(c.Query("type"), page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取公告失败")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Create 创建公告
+func (h *AnnouncementHandler) Create(c *gin.Context) {
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	input, ok := req.toInput()
+	if !ok {
+		response.Error(c, http.StatusBadRequest, "时间格式应为 RFC3339")
+		return
+	}
+
+	a, err := h.service.Create(input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.homeService.Invalidate()
+	response.Success(c, a)
+}
+
+// Update 更新公告
+func (h *AnnouncementHandler) Update(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	var req AnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	input, ok := req.toInput()
+	if !ok {
+		response.Error(c, http.StatusBadRequest, "时间格式应为 RFC3339")
+		return
+	}
+
+	a, err := h.service.Update(uint(id), input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.homeService.Invalidate()
+	response.Success(c, a)
+}
+
+// Delete 删除公告
+func (h *AnnouncementHandler) Delete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.service.Delete(uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+	h.homeService.Invalidate()
+	response.Success(c, gin.H{"message": "删除成功"})
+}
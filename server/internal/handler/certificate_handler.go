@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CertificateHandler struct {
+	service *service.CertificateService
+}
+
+func NewCertificateHandler(service *service.CertificateService) *CertificateHandler {
+	return &CertificateHandler{service: service}
+}
+
+// Verify 根据编号验证证书（公开接口，供合作方核验）
+func (h *CertificateHandler) Verify(c *gin.Context) {
+	view, err := h.service.Verify(c.Param("serial"))
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, view)
+}
+
+// GetMine 获取当前用户的证书
+func (h *CertificateHandler) GetMine(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	certs, err := h.service.GetUserCertificates(userID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取证书失败")
+		return
+	}
+
+	response.Success(c, certs)
+}
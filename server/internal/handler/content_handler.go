@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"car4race/internal/model"
 	"car4race/internal/service"
 	"car4race/pkg/response"
 
@@ -11,16 +12,26 @@ import (
 )
 
 type ContentHandler struct {
-	service *service.ContentService
+	service          *service.ContentService
+	markdownService  *service.MarkdownService
+	analyticsService *service.AnalyticsService
+	historyService   *service.HistoryService
 }
 
-func NewContentHandler(service *service.ContentService) *ContentHandler {
-	return &ContentHandler{service: service}
+func NewContentHandler(service *service.ContentService, markdownService *service.MarkdownService, analyticsService *service.AnalyticsService, historyService *service.HistoryService) *ContentHandler {
+	return &ContentHandler{service: service, markdownService: markdownService, analyticsService: analyticsService, historyService: historyService}
 }
 
-// GetCategories 获取所有分类
+// noteDetail 笔记详情，在原始 Markdown 之外附带渲染后的 HTML 和目录
+type noteDetail struct {
+	*model.Note
+	ContentHTML string            `json:"content_html"`
+	TOC         []service.TOCItem `json:"toc"`
+}
+
+// GetCategories 获取完整分类树（含各分类笔记数）
 func (h *ContentHandler) GetCategories(c *gin.Context) {
-	categories, err := h.service.GetAllCategories()
+	categories, err := h.service.GetCategoryTree()
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "获取分类失败")
 		return
@@ -33,8 +44,9 @@ func (h *ContentHandler) GetNotes(c *gin.Context) {
 	categoryID, _ := strconv.ParseUint(c.Query("category_id"), 10, 64)
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	tag := c.Query("tag") // 标签 slug
 
-	notes, total, err := h.service.GetNotes(uint(categoryID), page, pageSize)
+	notes, total, err := h.service.GetNotes(uint(categoryID), tag, page, pageSize)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "获取笔记失败")
 		return
@@ -53,36 +65,26 @@ func (h *ContentHandler) GetNote(c *gin.Context) {
 	slug := c.Param("slug")
 	userID := c.GetUint("user_id") // 可能为 0（未登录）
 
-	note, err := h.service.GetNoteBySlug(slug, userID)
+	preview := previewToken(c)
+	note, err := h.service.GetNoteBySlug(slug, userID, preview)
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "笔记不存在")
 		return
 	}
+	if preview == nil {
+		trackEvent(c, h.analyticsService, model.EventNote, note.ID)
+		h.historyService.Record(userID, model.HistoryNote, note.ID)
+	}
 
-	response.Success(c, note)
+	rendered := h.markdownService.Render(note.Content)
+	response.Success(c, noteDetail{Note: note, ContentHTML: rendered.HTML, TOC: rendered.TOC})
 }
 
-// GetBrowseHistory 获取浏览记录
-func (h *ContentHandler) GetBrowseHistory(c *gin.Context) {
-	userID := c.GetUint("user_id")
-	if userID == 0 {
-		response.Error(c, http.StatusUnauthorized, "未登录")
-		return
+// previewToken 读取草稿预览签名参数
+func previewToken(c *gin.Context) *service.PreviewToken {
+	sig := c.Query("preview_sig")
+	if sig == "" {
+		return nil
 	}
-
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-
-	history, total, err := h.service.GetUserBrowseHistory(userID, page, pageSize)
-	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "获取浏览记录失败")
-		return
-	}
-
-	response.Success(c, gin.H{
-		"list":      history,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	return &service.PreviewToken{Exp: c.Query("preview_exp"), Sig: sig}
 }
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"car4race/internal/model"
@@ -15,21 +16,26 @@ import (
 )
 
 type CourseHandler struct {
-	service     *service.CourseService
-	fileService *service.FileService
+	service          *service.CourseService
+	fileService      *service.FileService
+	certService      *service.CertificateService
+	markdownService  *service.MarkdownService
+	analyticsService *service.AnalyticsService
+	historyService   *service.HistoryService
 }
 
-func NewCourseHandler(service *service.CourseService, fileService *service.FileService) *CourseHandler {
-	return &CourseHandler{service: service, fileService: fileService}
+func NewCourseHandler(service *service.CourseService, fileService *service.FileService, certService *service.CertificateService, markdownService *service.MarkdownService, analyticsService *service.AnalyticsService, historyService *service.HistoryService) *CourseHandler {
+	return &CourseHandler{service: service, fileService: fileService, certService: certService, markdownService: markdownService, analyticsService: analyticsService, historyService: historyService}
 }
 
 // GetCourses 获取课程列表
 func (h *CourseHandler) GetCourses(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	sortBy := c.DefaultQuery("sort", "newest") // newest | price_asc | price_desc | sales
+	sortBy := c.DefaultQuery("sort", "newest") // newest | price_asc | price_desc | sales | rating | views
+	tag := c.Query("tag")                      // 标签 slug
 
-	courses, total, err := h.service.GetCourses(page, pageSize, sortBy)
+	courses, total, err := h.service.GetCourses(page, pageSize, sortBy, tag)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "获取课程失败")
 		return
@@ -47,12 +53,12 @@ func (h *CourseHandler) GetCourses(c *gin.Context) {
 func (h *CourseHandler) GetCourse(c *gin.Context) {
 	slug := c.Param("slug")
 
+	preview := previewToken(c)
 	course, err := h.service.GetCourseBySlugWithFiles(slug)
-	if err != nil {
+	if err != nil || !h.service.CanViewCourse(course, preview) {
 		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
 		return
 	}
-
 	// 检查用户是否已购买（支持可选登录）
 	var userID uint
 	if val, exists := c.Get("user_id"); exists {
@@ -61,15 +67,26 @@ func (h *CourseHandler) GetCourse(c *gin.Context) {
 		}
 	}
 
+	if preview == nil {
+		trackEvent(c, h.analyticsService, model.EventCourse, course.ID)
+		h.historyService.Record(userID, model.HistoryCourse, course.ID)
+	}
+
 	purchased := false
 	if userID > 0 {
 		purchased, _ = h.service.CheckUserPurchased(userID, course.ID)
 	}
 
+	access := purchased || c.GetString("role") == "admin"
+
+	// 课程大纲：未购买时隐藏非试看课时的文件
+	outline, _ := h.service.GetCourseOutline(course.ID, access)
+
 	// 获取课程介绍 Markdown 内容
 	introContent, _ := h.fileService.GetCourseIntroContent(course.ID)
+	intro := h.markdownService.Render(introContent)
 
-	// 分离 intro 和 resource 文件
+	// 分离 intro 和 resource 文件，未购买时资源文件只返回名称和大小
 	var introFiles, resourceFiles []model.CourseFile
 	for _, f := range course.Files {
 		if f.FileType == "intro" {
@@ -78,19 +95,177 @@ func (h *CourseHandler) GetCourse(c *gin.Context) {
 			resourceFiles = append(resourceFiles, f)
 		}
 	}
+	var resources interface{} = resourceFiles
+	if !access {
+		course.Files = nil
+		locked := make([]lockedFile, 0, len(resourceFiles))
+		for _, f := range resourceFiles {
+			locked = append(locked, lockedFile{FileName: f.FileName, FileSize: f.FileSize})
+		}
+		resources = locked
+	}
 
 	response.Success(c, gin.H{
 		"course":         course,
 		"purchased":      purchased,
+		"outline":        outline,
 		"intro_content":  introContent,
+		"intro_html":     intro.HTML,
+		"intro_toc":      intro.TOC,
 		"intro_files":    introFiles,
-		"resource_files": resourceFiles,
+		"resource_files": resources,
 	})
 }
 
-// CreateOrderRequest 创建订单请求
+// lockedFile 未购买用户可见的资源文件信息
+type lockedFile struct {
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+}
+
+// GetProgress 获取课程学习进度
+func (h *CourseHandler) GetProgress(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	course, err := h.service.GetCourseBySlug(c.Param("slug"))
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
+		return
+	}
+
+	progress, err := h.service.GetCourseProgress(userID, course.ID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取学习进度失败")
+		return
+	}
+
+	response.Success(c, progress)
+}
+
+// ReportProgressRequest 学习进度心跳请求
+type ReportProgressRequest struct {
+	Items []service.ProgressItem `json:"items" binding:"required,dive"`
+}
+
+// ReportProgress 批量上报学习进度
+func (h *CourseHandler) ReportProgress(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req ReportProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "参数错误")
+		return
+	}
+
+	course, err := h.service.GetCourseBySlug(c.Param("slug"))
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
+		return
+	}
+
+	progress, err := h.service.ReportProgress(userID, course.ID, req.Items)
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	// 本次上报越过完成阈值时颁发结业证书
+	if progress.Purchased {
+		h.certService.IssueOnCompletion(userID, course.ID, progress.Previous, progress.Percent)
+	}
+
+	response.Success(c, progress)
+}
+
+// GetReviews 获取课程评价列表，登录用户同时返回自己的评价
+func (h *CourseHandler) GetReviews(c *gin.Context) {
+	course, err := h.service.GetCourseBySlug(c.Param("slug"))
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	reviews, total, err := h.service.GetCourseReviews(course.ID, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取评价失败")
+		return
+	}
+
+	var myReview *model.Review
+	if userID := c.GetUint("user_id"); userID > 0 {
+		if review, err := h.service.GetUserReview(userID, course.ID); err == nil {
+			myReview = review
+		}
+	}
+
+	response.Success(c, gin.H{
+		"list":         reviews,
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+		"rating_avg":   course.RatingAvg,
+		"rating_count": course.RatingCount,
+		"my_review":    myReview,
+	})
+}
+
+// SubmitReviewRequest 发表评价请求
+type SubmitReviewRequest struct {
+	Rating  int    `json:"rating" binding:"required,min=1,max=5"`
+	Content string `json:"content"`
+}
+
+// SubmitReview 发表或修改课程评价
+func (h *CourseHandler) SubmitReview(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req SubmitReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "参数错误")
+		return
+	}
+
+	course, err := h.service.GetCourseBySlug(c.Param("slug"))
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
+		return
+	}
+
+	review, err := h.service.SubmitReview(userID, course.ID, req.Rating, strings.TrimSpace(req.Content))
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// DeleteReview 删除自己的课程评价
+func (h *CourseHandler) DeleteReview(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	course, err := h.service.GetCourseBySlug(c.Param("slug"))
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeCourseNotFound, errcode.Message(errcode.CodeCourseNotFound))
+		return
+	}
+
+	if err := h.service.DeleteUserReview(userID, course.ID); err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// CreateOrderRequest 创建订单请求，三种方式任选其一
 type CreateOrderRequest struct {
-	CourseID uint `json:"course_id" binding:"required"`
+	CourseID  uint   `json:"course_id"`  // 单门课程
+	CourseIDs []uint `json:"course_ids"` // 购物车多门课程
+	BundleID  uint   `json:"bundle_id"`  // 套餐
 }
 
 // CreateOrder 创建订单
@@ -107,7 +282,19 @@ func (h *CourseHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.service.CreateOrder(userID, req.CourseID)
+	var order *model.Order
+	var err error
+	switch {
+	case req.BundleID > 0:
+		order, err = h.service.CreateBundleOrder(userID, req.BundleID)
+	case len(req.CourseIDs) > 0:
+		order, err = h.service.CreateCartOrder(userID, req.CourseIDs)
+	case req.CourseID > 0:
+		order, err = h.service.CreateOrder(userID, req.CourseID)
+	default:
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "参数错误")
+		return
+	}
 	if err != nil {
 		response.ErrorFromErr(c, err)
 		return
@@ -116,6 +303,37 @@ func (h *CourseHandler) CreateOrder(c *gin.Context) {
 	response.Success(c, order)
 }
 
+// GetBundles 获取套餐列表
+func (h *CourseHandler) GetBundles(c *gin.Context) {
+	bundles, err := h.service.GetBundles()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取套餐失败")
+		return
+	}
+
+	response.Success(c, bundles)
+}
+
+// GetBundle 获取套餐详情，登录用户同时返回已购课程抵扣后的报价
+func (h *CourseHandler) GetBundle(c *gin.Context) {
+	bundle, err := h.service.GetBundleBySlug(c.Param("slug"))
+	if err != nil || !bundle.IsPublic {
+		response.ErrorWithCode(c, http.StatusNotFound, errcode.CodeNotFound, "套餐不存在")
+		return
+	}
+
+	quote, err := h.service.QuoteBundle(c.GetUint("user_id"), bundle)
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"bundle": bundle,
+		"quote":  quote,
+	})
+}
+
 // GetOrders 获取用户订单列表
 func (h *CourseHandler) GetOrders(c *gin.Context) {
 	userID := c.GetUint("user_id")
@@ -223,6 +441,7 @@ func (h *CourseHandler) Download(c *gin.Context) {
 			response.Error(c, http.StatusNotFound, "文件不存在")
 			return
 		}
+		trackEvent(c, h.analyticsService, model.EventDownload, download.FileID)
 		c.Redirect(http.StatusTemporaryRedirect, presignedURL)
 		return
 
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandler struct {
+	service *service.DashboardService
+}
+
+func NewDashboardHandler(service *service.DashboardService) *DashboardHandler {
+	return &DashboardHandler{service: service}
+}
+
+// GetDashboard 管理后台概览
+// 区间用 from、to（YYYY-MM-DD）指定，或用 days 指定截至今天的最近天数；refresh=1 跳过缓存
+func (h *DashboardHandler) GetDashboard(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if days, _ := strconv.Atoi(c.Query("days")); days > 0 && from == "" && to == "" {
+		from = time.Now().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	}
+
+	dashboard, err := h.service.GetDashboard(from, to, c.Query("refresh") == "1")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, dashboard)
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"car4race/internal/service"
+	"car4race/pkg/errcode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const feedCacheControl = "public, max-age=600"
+
+type FeedHandler struct {
+	service *service.FeedService
+}
+
+func NewFeedHandler(service *service.FeedService) *FeedHandler {
+	return &FeedHandler{service: service}
+}
+
+// NotesFeed 全部笔记的 Atom 订阅源
+func (h *FeedHandler) NotesFeed(c *gin.Context) {
+	doc, err := h.service.NotesFeed("")
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	writeDocument(c, doc, "application/atom+xml; charset=utf-8")
+}
+
+// CategoryFeed 分类的 Atom 订阅源，路径形如 /feed/categories/{slug}.xml
+func (h *FeedHandler) CategoryFeed(c *gin.Context) {
+	slug, ok := strings.CutSuffix(c.Param("file"), ".xml")
+	if !ok || slug == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	doc, err := h.service.NotesFeed(slug)
+	if err != nil {
+		if errcode.Is(err, errcode.CodeNotFound) {
+			c.Status(http.StatusNotFound)
+		} else {
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+	writeDocument(c, doc, "application/atom+xml; charset=utf-8")
+}
+
+// Sitemap 站点地图
+func (h *FeedHandler) Sitemap(c *gin.Context) {
+	doc, err := h.service.Sitemap()
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	writeDocument(c, doc, "application/xml; charset=utf-8")
+}
+
+// Robots robots.txt
+func (h *FeedHandler) Robots(c *gin.Context) {
+	writeDocument(c, h.service.Robots(), "text/plain; charset=utf-8")
+}
+
+// writeDocument 输出文档，支持 If-None-Match / If-Modified-Since 条件请求
+func writeDocument(c *gin.Context, doc *service.FeedDocument, contentType string) {
+	c.Header("ETag", doc.ETag)
+	c.Header("Cache-Control", feedCacheControl)
+	if !doc.LastModified.IsZero() {
+		c.Header("Last-Modified", doc.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if etagMatches(inm, doc.ETag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !doc.LastModified.IsZero() {
+		// If-None-Match 优先；Last-Modified 精确到秒
+		if t, err := http.ParseTime(ims); err == nil && !doc.LastModified.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, contentType, doc.Body)
+}
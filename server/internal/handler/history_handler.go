@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	service *service.HistoryService
+}
+
+func NewHistoryHandler(service *service.HistoryService) *HistoryHandler {
+	return &HistoryHandler{service: service}
+}
+
+// GetHistory 获取浏览记录，type 为 note 或 course，为空表示全部
+func (h *HistoryHandler) GetHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	history, total, err := h.service.List(userID, c.Query("type"), page, pageSize)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      history,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// DeleteHistory 删除一条浏览记录
+func (h *HistoryHandler) DeleteHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	if err := h.service.Delete(userID, uint(id)); err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "删除成功"})
+}
+
+// ClearHistory 清空浏览记录，type 为 note 或 course 时只清空该类型
+func (h *HistoryHandler) ClearHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		response.Error(c, http.StatusUnauthorized, "未登录")
+		return
+	}
+
+	deleted, err := h.service.Clear(userID, c.Query("type"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"deleted": deleted})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HomeHandler struct {
+	service *service.HomeService
+}
+
+func NewHomeHandler(service *service.HomeService) *HomeHandler {
+	return &HomeHandler{service: service}
+}
+
+// GetHome 首页聚合数据：最新笔记、热门课程、公告
+// 支持 If-None-Match，数据未变化时返回 304
+func (h *HomeHandler) GetHome(c *gin.Context) {
+	page, err := h.service.GetHome(c.GetUint("user_id"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取首页失败")
+		return
+	}
+
+	// 公告因登录状态而异，需按 Authorization 区分缓存；每次使用前向服务端确认
+	c.Header("ETag", page.ETag)
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Vary", "Authorization")
+	if etagMatches(c.GetHeader("If-None-Match"), page.ETag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	response.Success(c, page.Data)
+}
+
+// etagMatches If-None-Match 是否包含指定 ETag（弱比较，支持多个值和 *）
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	target := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == target {
+			return true
+		}
+	}
+	return false
+}
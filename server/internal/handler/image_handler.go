@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"car4race/internal/service"
+	"car4race/pkg/errcode"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxUploadImageSize = 10 << 20
+
+type ImageHandler struct {
+	fileService  *service.FileService
+	imageService *service.ImageService
+}
+
+func NewImageHandler(fileService *service.FileService, imageService *service.ImageService) *ImageHandler {
+	return &ImageHandler{fileService: fileService, imageService: imageService}
+}
+
+// Upload 上传图片（multipart 字段 file，purpose 为 cover / content / avatar）
+// 管理员可上传任意用途；普通用户只能上传头像，默认用途为 avatar
+// 返回的地址可直接填入封面、头像字段或插入笔记正文
+func (h *ImageHandler) Upload(c *gin.Context) {
+	isAdmin := c.GetString("role") == "admin"
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		purpose = service.ImagePurposeAvatar
+		if isAdmin {
+			purpose = service.ImagePurposeContent
+		}
+	}
+	if !service.IsValidImagePurpose(purpose) {
+		response.Error(c, http.StatusBadRequest, "不支持的图片用途")
+		return
+	}
+	if !isAdmin && purpose != service.ImagePurposeAvatar {
+		response.ErrorWithCode(c, http.StatusForbidden, errcode.CodeForbidden, "仅可上传头像")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "请选择文件")
+		return
+	}
+	if file.Size > maxUploadImageSize {
+		response.ErrorFromErr(c, errcode.NewWithMessage(errcode.CodeFileTooLarge, "图片不能超过 10MB"))
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "读取文件失败")
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxUploadImageSize+1))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "读取文件失败")
+		return
+	}
+
+	img, err := h.imageService.Upload(data, purpose)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response.Success(c, img)
+}
+
+// Serve 输出图片（笔记正文、封面等引用的图片）
+// 图片按内容哈希命名，地址不变则内容不变，可长期缓存
+func (h *ImageHandler) Serve(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("path"), "/")
+
+	etag := `"` + key + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	obj, contentType, size, err := h.fileService.OpenImage(key)
+	if err != nil {
+		if errcode.Is(err, errcode.CodeNotFound) {
+			c.Status(http.StatusNotFound)
+		} else {
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+	defer obj.Close()
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("ETag", etag)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, obj)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	service *service.SearchService
+}
+
+func NewSearchHandler(service *service.SearchService) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+// Search 全文搜索笔记和课程
+// type 可选 note、course，多个用逗号分隔
+func (h *SearchHandler) Search(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	var types []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	results, total, err := h.service.Search(c.Query("q"), types, page, pageSize)
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"list":      results,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"car4race/internal/service"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandler struct {
+	service *service.TagService
+}
+
+func NewTagHandler(service *service.TagService) *TagHandler {
+	return &TagHandler{service: service}
+}
+
+// GetTags 标签云（含各标签下公开内容数）
+func (h *TagHandler) GetTags(c *gin.Context) {
+	tags, err := h.service.GetTagCloud()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取标签失败")
+		return
+	}
+
+	response.Success(c, tags)
+}
+
+// GetTag 标签页：标签信息及其下的笔记和课程
+func (h *TagHandler) GetTag(c *gin.Context) {
+	landing, err := h.service.GetTagLanding(c.Param("slug"))
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, landing)
+}
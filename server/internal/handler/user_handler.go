@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"regexp"
+	"strconv"
 
 	"car4race/internal/service"
 	"car4race/pkg/errcode"
@@ -12,11 +15,12 @@ import (
 )
 
 type UserHandler struct {
-	service *service.UserService
+	service       *service.UserService
+	memberService *service.MemberService
 }
 
-func NewUserHandler(service *service.UserService) *UserHandler {
-	return &UserHandler{service: service}
+func NewUserHandler(service *service.UserService, memberService *service.MemberService) *UserHandler {
+	return &UserHandler{service: service, memberService: memberService}
 }
 
 // SendCodeRequest 发送验证码请求
@@ -143,6 +147,35 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	response.Success(c, user)
 }
 
+// GrantVIPRequest 开通会员请求
+type GrantVIPRequest struct {
+	Months int `json:"months"` // 为 0 时使用默认时长
+}
+
+// GrantVIP 管理员为用户开通或续期会员
+func (h *UserHandler) GrantVIP(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+
+	// 请求体可省略，等同于 months 为 0
+	var req GrantVIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "参数错误")
+		return
+	}
+
+	user, err := h.memberService.GrantVIP(uint(id), req.Months)
+	if err != nil {
+		if errcode.GetCode(err) != 0 {
+			response.ErrorFromErr(c, err)
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "开通会员失败")
+		return
+	}
+
+	response.Success(c, user)
+}
+
 // isValidPhone 验证手机号格式
 func isValidPhone(phone string) bool {
 	matched, _ := regexp.MatchString(`^1[3-9]\d{9}$`, phone)
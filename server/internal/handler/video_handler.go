@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"car4race/internal/service"
+	"car4race/pkg/errcode"
+	"car4race/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VideoHandler struct {
+	service *service.VideoService
+}
+
+func NewVideoHandler(service *service.VideoService) *VideoHandler {
+	return &VideoHandler{service: service}
+}
+
+// Play 获取课程视频的播放地址（需已购买）
+func (h *VideoHandler) Play(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		response.ErrorWithCode(c, http.StatusUnauthorized, errcode.CodeUnauthorized, errcode.Message(errcode.CodeUnauthorized))
+		return
+	}
+
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 64)
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "文件ID无效")
+		return
+	}
+
+	info, err := h.service.AuthorizePlayback(userID, c.GetString("role") == "admin", c.Param("slug"), uint(fileID))
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"playlist_url": fmt.Sprintf("/api/v1/hpa/hls/%d/master.m3u8?%s", info.AssetID, info.Query.Encode()),
+		"expire_at":    info.ExpireAt,
+		"duration":     info.Duration,
+		"renditions":   info.Renditions,
+	})
+}
+
+// Stream 输出签名后的播放列表，分片重定向到短期预签名地址
+func (h *VideoHandler) Stream(c *gin.Context) {
+	assetID, err := strconv.ParseUint(c.Param("assetId"), 10, 64)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	name, ok := service.CleanStreamPath(c.Param("path"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	query := c.Request.URL.Query()
+	asset, err := h.service.VerifyStream(uint(assetID), query)
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+
+	if strings.HasSuffix(name, ".m3u8") {
+		playlist, err := h.service.RenderPlaylist(asset, name, query)
+		if err != nil {
+			response.ErrorFromErr(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/vnd.apple.mpegurl", playlist)
+		return
+	}
+
+	segmentURL, err := h.service.SegmentURL(asset, name)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Redirect(http.StatusFound, segmentURL)
+}
+
+// Key 下发 HLS 解密密钥，公开路由：播放器请求密钥时不携带登录凭证
+// 校验播放链接签名（证明链接由播放授权接口签发给 uid、绑定该视频且未过期），
+// 再按 uid 重新校验购买、试看或管理员权限，并按用户和视频限流
+func (h *VideoHandler) Key(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("keyId"), 10, 64)
+	if err != nil {
+		response.ErrorWithCode(c, http.StatusBadRequest, errcode.CodeInvalidParam, "密钥ID无效")
+		return
+	}
+
+	key, err := h.service.DeliverKey(&service.KeyRequest{
+		KeyID:     uint(keyID),
+		Query:     c.Request.URL.Query(),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		response.ErrorFromErr(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/octet-stream", key)
+}
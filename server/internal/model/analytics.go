@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// 统计事件类型
+const (
+	EventPage     = "page"     // 前端页面浏览，Target 为页面路径
+	EventCourse   = "course"   // 课程详情浏览，Target 为课程 ID
+	EventNote     = "note"     // 笔记详情浏览，Target 为笔记 ID
+	EventDownload = "download" // 资源下载，Target 为文件 ID
+)
+
+// AnalyticsVisitor 当日访客记录，用于按天去重计算 UV
+// Target 为空表示该类型的全站汇总；只需保留最近几天，历史数据见 AnalyticsDaily
+type AnalyticsVisitor struct {
+	ID        uint      `gorm:"primaryKey"`
+	Day       string    `gorm:"uniqueIndex:idx_analytics_visitor;size:10;not null"` // 2006-01-02
+	EventType string    `gorm:"uniqueIndex:idx_analytics_visitor;size:20;not null"`
+	Target    string    `gorm:"uniqueIndex:idx_analytics_visitor;size:200;not null"`
+	Visitor   string    `gorm:"uniqueIndex:idx_analytics_visitor;size:40;not null"` // u:{用户ID} 或 IP+UA 的当日哈希
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (AnalyticsVisitor) TableName() string {
+	return "hpa_analytics_visitors"
+}
+
+// AnalyticsDaily 每日汇总，Target 为空表示该类型的全站汇总
+type AnalyticsDaily struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Day       string    `gorm:"uniqueIndex:idx_analytics_daily;size:10;not null" json:"day"`
+	EventType string    `gorm:"uniqueIndex:idx_analytics_daily;size:20;not null" json:"type"`
+	Target    string    `gorm:"uniqueIndex:idx_analytics_daily;size:200;not null" json:"target"`
+	PV        int64     `gorm:"default:0" json:"pv"`
+	UV        int64     `gorm:"default:0" json:"uv"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (AnalyticsDaily) TableName() string {
+	return "hpa_analytics_daily"
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 公告类型
+const (
+	AnnouncementSystem = "system" // 系统通知，如赛道日安排
+	AnnouncementVIP    = "vip"    // 新会员播报，用户成为会员时自动生成
+	AnnouncementPromo  = "promo"  // 促销活动
+)
+
+// 公告可见范围
+const (
+	AudienceAll   = "all"
+	AudienceLogin = "login"
+	AudienceVIP   = "vip"
+)
+
+// Announcement 公告 / 首页横幅
+type Announcement struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Content   string         `gorm:"size:500;not null" json:"content"`
+	Link      string         `gorm:"size:500" json:"link"`                     // 点击跳转地址，可为空
+	Type      string         `gorm:"size:20;default:system;index" json:"type"` // system | vip | promo
+	Audience  string         `gorm:"size:20;default:all" json:"audience"`      // all | login | vip
+	Pinned    bool           `gorm:"default:false" json:"pinned"`              // 置顶
+	StartAt   time.Time      `gorm:"index;not null" json:"start_at"`           // 开始展示时间
+	EndAt     *time.Time     `gorm:"index" json:"end_at"`                      // 结束展示时间，为空表示长期有效
+	UserID    uint           `gorm:"index;default:0" json:"-"`                 // 会员播报对应的用户，不对外输出
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Announcement) TableName() string {
+	return "hpa_announcements"
+}
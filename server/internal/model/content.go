@@ -6,6 +6,45 @@ import (
 	"gorm.io/gorm"
 )
 
+// 笔记/课程的发布状态
+const (
+	StatusDraft     = "draft"     // 草稿，仅管理员可见
+	StatusScheduled = "scheduled" // 定时发布，到达 PublishAt 后上线
+	StatusPublished = "published" // 已发布
+	StatusArchived  = "archived"  // 已归档，不再出现在列表但链接仍可访问
+)
+
+// IsListed 是否出现在公开列表：已发布，或定时发布时间已到
+func IsListed(status string, publishAt *time.Time, now time.Time) bool {
+	switch status {
+	case StatusPublished:
+		return true
+	case StatusScheduled:
+		return publishAt != nil && !publishAt.After(now)
+	}
+	return false
+}
+
+// IsReachable 是否可通过链接访问：可列出或已归档
+func IsReachable(status string, publishAt *time.Time, now time.Time) bool {
+	return status == StatusArchived || IsListed(status, publishAt, now)
+}
+
+// 笔记访问级别
+const (
+	NoteAccessPublic = "public" // 所有人可见
+	NoteAccessLogin  = "login"  // 登录后可见
+	NoteAccessVIP    = "vip"    // 会员可见
+	NoteAccessCourse = "course" // 购买指定课程后可见
+)
+
+// 笔记锁定原因（无权查看全文时返回）
+const (
+	NoteLockedLogin    = "login_required"
+	NoteLockedVIP      = "vip_required"
+	NoteLockedPurchase = "purchase_required"
+)
+
 // Category 分类表
 type Category struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
@@ -36,30 +75,76 @@ type Note struct {
 	Content    string         `gorm:"type:text" json:"content"`
 	CoverImage string         `gorm:"size:500" json:"cover_image"`
 	ViewCount  int            `gorm:"default:0" json:"view_count"`
-	IsPublic   bool           `gorm:"default:true" json:"is_public"`
+	IsPublic   bool           `gorm:"default:false" json:"is_public"`                // 与 Status 同步，等价于已发布
+	Status     string         `gorm:"size:20;default:published;index" json:"status"` // draft | scheduled | published | archived
+	PublishAt  *time.Time     `gorm:"index" json:"publish_at"`                       // 发布时间（定时发布的上线时间）
+	Version    int            `gorm:"default:1" json:"version"`                      // 乐观锁版本号，每次更新递增
 	Sort       int            `gorm:"default:0" json:"sort"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// 访问控制
+	AccessLevel    string  `gorm:"size:20;default:public" json:"access_level"` // public | login | vip | course
+	AccessCourseID *uint   `json:"access_course_id"`                           // AccessLevel 为 course 时，购买该课程可见
+	Locked         string  `gorm:"-" json:"locked,omitempty"`                  // 无权查看全文时的原因，Content 仅为节选，不落库
+	AccessCourse   *Course `gorm:"-" json:"access_course,omitempty"`           // 因未购买而锁定时附带课程信息，便于引导购买
+
 	// 关联
 	Category Category `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Tags     []Tag    `gorm:"many2many:hpa_note_tags" json:"tags,omitempty"`
+}
+
+// IsRestricted 是否需要权限才能查看全文
+func (n *Note) IsRestricted() bool {
+	return n.AccessLevel != "" && n.AccessLevel != NoteAccessPublic
 }
 
 func (Note) TableName() string {
 	return "hpa_notes"
 }
 
-// BrowseHistory 浏览记录表
-type BrowseHistory struct {
+// NoteRevision 笔记修订记录，每次保存生成一条
+type NoteRevision struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"index;not null" json:"user_id"`
-	NoteID    uint      `gorm:"index;not null" json:"note_id"`
+	NoteID    uint      `gorm:"uniqueIndex:idx_revision_note_version;not null" json:"note_id"`
+	Version   int       `gorm:"uniqueIndex:idx_revision_note_version;not null" json:"version"`
+	Title     string    `gorm:"size:200;not null" json:"title"`
+	Summary   string    `gorm:"size:500" json:"summary"`
+	Content   string    `gorm:"type:text" json:"content,omitempty"`
+	AuthorID  uint      `gorm:"index;default:0" json:"author_id"` // 0 表示系统（如历史数据快照）
+	Comment   string    `gorm:"size:200" json:"comment"`          // 例如“恢复自版本 3”
 	CreatedAt time.Time `json:"created_at"`
 
 	// 关联
-	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Note Note `gorm:"foreignKey:NoteID" json:"note,omitempty"`
+	Author User `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+}
+
+func (NoteRevision) TableName() string {
+	return "hpa_note_revisions"
+}
+
+// 浏览记录的目标类型
+const (
+	HistoryNote   = "note"
+	HistoryCourse = "course"
+)
+
+// BrowseHistory 浏览记录表，记录笔记和课程详情的浏览
+// 同一目标在同一合并窗口内的重复浏览只更新最近浏览时间和次数
+type BrowseHistory struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_history_user_target_window,priority:1;index:idx_history_user_viewed,priority:1;not null" json:"user_id"`
+	TargetType string    `gorm:"uniqueIndex:idx_history_user_target_window,priority:2;size:20;not null;default:note" json:"target_type"` // note | course
+	TargetID   uint      `gorm:"uniqueIndex:idx_history_user_target_window,priority:3;not null;default:0" json:"target_id"`
+	ViewWindow int64     `gorm:"uniqueIndex:idx_history_user_target_window,priority:4;not null;default:0" json:"-"` // 合并窗口编号
+	ViewCount  int       `gorm:"default:1" json:"view_count"`                                                       // 合并的浏览次数
+	CreatedAt  time.Time `json:"created_at"`                                                                        // 首次浏览时间
+	UpdatedAt  time.Time `gorm:"index:idx_history_user_viewed,priority:2;index" json:"updated_at"`                  // 最近浏览时间
+
+	// 关联（按 TargetType 加载，目标已删除时为空）
+	Note   *Note   `gorm:"-" json:"note,omitempty"`
+	Course *Course `gorm:"-" json:"course,omitempty"`
 }
 
 func (BrowseHistory) TableName() string {
@@ -17,14 +17,21 @@ type Course struct {
 	OrigPrice   float64        `gorm:"default:0" json:"orig_price"`
 	IntroPath   string         `gorm:"size:500" json:"intro_path"` // Markdown 介绍文件路径
 	SalesCount  int            `gorm:"default:0" json:"sales_count"`
-	IsPublic    bool           `gorm:"default:true" json:"is_public"`
+	ViewCount   int            `gorm:"default:0" json:"view_count"`                   // 去重后的浏览次数，由统计任务累加
+	RatingAvg   float64        `gorm:"default:0" json:"rating_avg"`                   // 可见评价的平均星级
+	RatingCount int            `gorm:"default:0" json:"rating_count"`                 // 可见评价数
+	IsPublic    bool           `gorm:"default:false" json:"is_public"`                // 与 Status 同步，等价于已发布
+	Status      string         `gorm:"size:20;default:published;index" json:"status"` // draft | scheduled | published | archived
+	PublishAt   *time.Time     `gorm:"index" json:"publish_at"`                       // 发布时间（定时发布的上线时间）
 	Sort        int            `gorm:"default:0" json:"sort"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// 关联
-	Files []CourseFile `gorm:"foreignKey:CourseID" json:"files,omitempty"`
+	Files    []CourseFile `gorm:"foreignKey:CourseID" json:"files,omitempty"`
+	Chapters []Chapter    `gorm:"foreignKey:CourseID" json:"chapters,omitempty"`
+	Tags     []Tag        `gorm:"many2many:hpa_course_tags" json:"tags,omitempty"`
 }
 
 func (Course) TableName() string {
@@ -39,6 +46,7 @@ type CourseFile struct {
 	FileName  string    `gorm:"size:200;not null" json:"file_name"`
 	FilePath  string    `gorm:"size:500;not null" json:"file_path"`
 	FileSize  int64     `gorm:"default:0" json:"file_size"`
+	Checksum  string    `gorm:"index;size:64" json:"checksum"` // SHA-256（十六进制）
 	Sort      int       `gorm:"default:0" json:"sort"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -47,29 +55,190 @@ func (CourseFile) TableName() string {
 	return "hpa_course_files"
 }
 
+// Chapter 课程章节
+type Chapter struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"index;not null" json:"course_id"`
+	Title     string    `gorm:"size:200;not null" json:"title"`
+	Sort      int       `gorm:"default:0" json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	Lessons []Lesson `gorm:"foreignKey:ChapterID" json:"lessons"`
+}
+
+func (Chapter) TableName() string {
+	return "hpa_chapters"
+}
+
+// Lesson 课时
+type Lesson struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CourseID      uint      `gorm:"index;not null" json:"course_id"`
+	ChapterID     uint      `gorm:"index;not null" json:"chapter_id"`
+	Title         string    `gorm:"size:200;not null" json:"title"`
+	Duration      int       `gorm:"default:0" json:"duration"`            // 时长（秒）
+	FileID        *uint     `gorm:"index" json:"file_id"`                 // 关联的课程文件（资料或视频）
+	IsFreePreview bool      `gorm:"default:false" json:"is_free_preview"` // 是否可免费试看
+	Sort          int       `gorm:"default:0" json:"sort"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	Locked bool `gorm:"-" json:"locked"` // 未购买且非试看时为 true，不落库
+
+	// 关联
+	File *CourseFile `gorm:"foreignKey:FileID" json:"file,omitempty"`
+}
+
+func (Lesson) TableName() string {
+	return "hpa_lessons"
+}
+
+// LearningProgress 用户对课程文件的学习进度
+type LearningProgress struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"uniqueIndex:idx_progress_user_file;index:idx_progress_user_course;not null" json:"user_id"`
+	CourseID    uint       `gorm:"index:idx_progress_user_course;not null" json:"course_id"`
+	FileID      uint       `gorm:"uniqueIndex:idx_progress_user_file;not null" json:"file_id"`
+	Position    float64    `gorm:"default:0" json:"position"` // 视频为秒，文档为页
+	Total       float64    `gorm:"default:0" json:"total"`    // 总时长或总页数，0 表示未知
+	Completed   bool       `gorm:"default:false" json:"completed"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ClientTime  int64      `gorm:"default:0" json:"-"` // 客户端上报时间（毫秒），用于丢弃重复或乱序的心跳
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (LearningProgress) TableName() string {
+	return "hpa_learning_progress"
+}
+
+// PendingUpload 待确认的直传上传记录
+// 管理员通过预签名 URL 直传 MinIO 后，需调用确认接口才会生成 CourseFile
+type PendingUpload struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CourseID     uint      `gorm:"index;not null" json:"course_id"`
+	FileType     string    `gorm:"size:20;not null" json:"file_type"` // intro | resource
+	FileName     string    `gorm:"size:200;not null" json:"file_name"`
+	ObjectKey    string    `gorm:"uniqueIndex;size:500;not null" json:"object_key"`
+	ExpectedSize int64     `gorm:"not null" json:"expected_size"`
+	Checksum     string    `gorm:"size:64" json:"checksum"` // SHA-256（十六进制）
+	ContentType  string    `gorm:"size:100" json:"content_type"`
+	ExpireAt     time.Time `gorm:"index" json:"expire_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (PendingUpload) TableName() string {
+	return "hpa_pending_uploads"
+}
+
 // Order 订单表
 type Order struct {
 	ID          uint       `gorm:"primaryKey" json:"id"`
 	OrderNo     string     `gorm:"uniqueIndex;size:50;not null" json:"order_no"`
 	UserID      uint       `gorm:"index;not null" json:"user_id"`
-	CourseID    uint       `gorm:"index;not null" json:"course_id"`
+	CourseID    uint       `gorm:"index;not null" json:"course_id"`  // 单课程订单的课程，多课程/套餐订单为 0
+	BundleID    uint       `gorm:"index;default:0" json:"bundle_id"` // 套餐订单的套餐
 	Amount      float64    `gorm:"not null" json:"amount"`
-	Status      string     `gorm:"size:20;default:pending" json:"status"` // pending | paid | refunded | cancelled
-	PayMethod   string     `gorm:"size:20" json:"pay_method"`             // wechat | alipay | invite_code
-	PayTime     *time.Time `json:"pay_time"`
+	Discount    float64    `gorm:"default:0" json:"discount"`                   // 已购课程抵扣金额
+	Status      string     `gorm:"size:20;default:pending;index" json:"status"` // pending | paid | refunded | cancelled
+	PayMethod   string     `gorm:"size:20" json:"pay_method"`                   // wechat | alipay | invite_code
+	PayTime     *time.Time `gorm:"index" json:"pay_time"`
 	InviteCode  string     `gorm:"size:50" json:"invite_code"` // 使用的邀请码
-	CreatedAt   time.Time  `json:"created_at"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
 
 	// 关联
-	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Course Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	User   User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Course Course      `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+	Bundle *Bundle     `gorm:"foreignKey:BundleID" json:"bundle,omitempty"`
+	Items  []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
 }
 
 func (Order) TableName() string {
 	return "hpa_orders"
 }
 
+// OrderItem 订单明细，每门课程一行
+type OrderItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OrderID   uint      `gorm:"index;not null" json:"order_id"`
+	CourseID  uint      `gorm:"index;not null" json:"course_id"`
+	BundleID  uint      `gorm:"default:0" json:"bundle_id"` // 来自套餐时记录套餐
+	Price     float64   `gorm:"not null" json:"price"`      // 课程原价
+	Amount    float64   `gorm:"not null" json:"amount"`     // 分摊后的实付金额
+	CreatedAt time.Time `json:"created_at"`
+
+	// 关联
+	Course Course `gorm:"foreignKey:CourseID" json:"course,omitempty"`
+}
+
+func (OrderItem) TableName() string {
+	return "hpa_order_items"
+}
+
+// Bundle 课程套餐（如赛季包），以套餐价打包销售多门课程
+type Bundle struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Title       string         `gorm:"size:200;not null" json:"title"`
+	Slug        string         `gorm:"uniqueIndex;size:200;not null" json:"slug"`
+	Description string         `gorm:"type:text" json:"description"`
+	CoverImage  string         `gorm:"size:500" json:"cover_image"`
+	Price       float64        `gorm:"not null" json:"price"`
+	IsPublic    bool           `gorm:"default:false" json:"is_public"`
+	Sort        int            `gorm:"default:0" json:"sort"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 关联
+	Courses []Course `gorm:"many2many:hpa_bundle_courses" json:"courses,omitempty"`
+}
+
+func (Bundle) TableName() string {
+	return "hpa_bundles"
+}
+
+// Review 课程评价，仅购买用户可评价，每人每课程一条
+type Review struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"uniqueIndex:idx_review_course_user;not null" json:"course_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_review_course_user;index;not null" json:"user_id"`
+	Rating    int       `gorm:"not null" json:"rating"` // 1-5 星
+	Content   string    `gorm:"type:text" json:"content"`
+	IsHidden  bool      `gorm:"default:false" json:"is_hidden"` // 管理员隐藏，不计入评分
+	IsPinned  bool      `gorm:"default:false" json:"is_pinned"` // 管理员置顶
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (Review) TableName() string {
+	return "hpa_reviews"
+}
+
+// Certificate 课程结业证书
+type Certificate struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Serial      string     `gorm:"uniqueIndex;size:32;not null" json:"serial"`
+	UserID      uint       `gorm:"uniqueIndex:idx_certificate_user_course;not null" json:"user_id"`
+	CourseID    uint       `gorm:"uniqueIndex:idx_certificate_user_course;index;not null" json:"course_id"`
+	Name        string     `gorm:"size:50;not null" json:"name"`          // 证书上的学员姓名
+	CourseTitle string     `gorm:"size:200;not null" json:"course_title"` // 颁发时的课程名称
+	FilePath    string     `gorm:"size:500" json:"-"`                     // PDF 对象路径
+	IssuedBy    string     `gorm:"size:20;not null" json:"issued_by"`     // progress | admin
+	IssuedAt    time.Time  `json:"issued_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (Certificate) TableName() string {
+	return "hpa_certificates"
+}
+
 // InviteCode 邀请码表
 type InviteCode struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
@@ -99,7 +268,7 @@ type Download struct {
 	Token     string    `gorm:"uniqueIndex;size:100;not null" json:"token"`
 	ExpireAt  time.Time `json:"expire_at"`
 	Used      bool      `gorm:"default:false" json:"used"`
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 
 	// 关联
 	User   User   `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// 搜索文档类型
+const (
+	SearchTypeNote   = "note"
+	SearchTypeCourse = "course"
+)
+
+// SearchDoc 搜索文档，保存笔记/课程的可检索文本，FTS5 索引以此表为外部内容表
+// 中文按二元切分后存入 *Tokens 字段，原文保留在 Body 用于生成摘要片段
+type SearchDoc struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DocType     string    `gorm:"uniqueIndex:idx_search_doc_ref;size:20;not null" json:"doc_type"` // note | course
+	RefID       uint      `gorm:"uniqueIndex:idx_search_doc_ref;not null" json:"ref_id"`
+	Body        string    `gorm:"type:text" json:"body"` // 去除 Markdown 标记后的正文
+	TitleTokens string    `gorm:"type:text" json:"-"`
+	BodyTokens  string    `gorm:"type:text" json:"-"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (SearchDoc) TableName() string {
+	return "hpa_search_docs"
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Tag 标签，笔记与课程共用（如“赛道日”“轮胎”“刹车”“数据分析”）
+type Tag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:30;not null" json:"name"`
+	Slug        string    `gorm:"uniqueIndex;size:50;not null" json:"slug"`
+	Description string    `gorm:"size:200" json:"description"`
+	Sort        int       `gorm:"default:0" json:"sort"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Tag) TableName() string {
+	return "hpa_tags"
+}
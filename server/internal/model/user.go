@@ -15,12 +15,13 @@ type User struct {
 	Avatar    string         `gorm:"size:500" json:"avatar"`
 	Role      string         `gorm:"size:20;default:user" json:"role"` // user | vip | admin
 	Status    string         `gorm:"size:20;default:active" json:"status"` // active | banned
-	CreatedAt time.Time      `json:"created_at"`
+	CreatedAt time.Time      `gorm:"index" json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// 会员相关（私域视频网站）
 	VIPExpireAt   *time.Time `json:"vip_expire_at"`
+	VIPSince      *time.Time `gorm:"column:vip_since;index" json:"vip_since"` // 本次成为会员的时间，续期不变
 	YearlySpend   float64    `gorm:"default:0" json:"yearly_spend"` // 年消费金额
 	CanDownload   bool       `gorm:"default:false" json:"can_download"` // 是否有下载权限
 }
@@ -30,6 +31,14 @@ func (User) TableName() string {
 	return "users"
 }
 
+// IsVIP 是否为有效会员：会员未过期，或角色为 vip 且未设置过期时间（永久会员）
+func (u *User) IsVIP(now time.Time) bool {
+	if u.VIPExpireAt != nil {
+		return u.VIPExpireAt.After(now)
+	}
+	return u.Role == "vip"
+}
+
 // VerificationCode 验证码表
 type VerificationCode struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// VideoAsset 课程视频的 HLS 转码结果
+type VideoAsset struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CourseID   uint      `gorm:"index;not null" json:"course_id"`
+	FileID     uint      `gorm:"uniqueIndex;not null" json:"file_id"`
+	Status     string    `gorm:"size:20;default:pending" json:"status"` // pending | processing | ready | failed
+	Prefix     string    `gorm:"size:500" json:"prefix"`                // HLS 文件所在目录
+	Renditions string    `gorm:"size:100" json:"renditions"`            // 例如 1080p,720p,480p
+	Duration   float64   `gorm:"default:0" json:"duration"`             // 秒
+	KeyID      uint      `gorm:"index;default:0" json:"key_id"`         // 加密密钥，0 表示未加密
+	PendingKey uint      `gorm:"default:0" json:"pending_key"`          // 轮换中的新密钥，重新转码完成前继续使用 KeyID 播放
+	Error      string    `gorm:"size:1000" json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (VideoAsset) TableName() string {
+	return "hpa_video_assets"
+}
+
+// VideoKey 课程视频 AES-128 加密密钥，按课程定期轮换
+type VideoKey struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CourseID  uint      `gorm:"index;not null" json:"course_id"`
+	Key       string    `gorm:"size:32;not null" json:"-"` // 十六进制
+	IV        string    `gorm:"size:32;not null" json:"-"` // 十六进制
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (VideoKey) TableName() string {
+	return "hpa_video_keys"
+}
+
+// VideoKeyAccess 密钥请求日志，用于发现批量盗录
+type VideoKeyAccess struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	CourseID  uint      `gorm:"index;not null" json:"course_id"`
+	AssetID   uint      `gorm:"not null" json:"asset_id"`
+	KeyID     uint      `gorm:"not null" json:"key_id"`
+	Session   string    `gorm:"size:32;index" json:"session"` // 播放会话（签名前缀）
+	IP        string    `gorm:"size:64" json:"ip"`
+	UserAgent string    `gorm:"size:300" json:"user_agent"`
+	Blocked   bool      `gorm:"default:false" json:"blocked"` // 是否因超频被拒绝
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (VideoKeyAccess) TableName() string {
+	return "hpa_video_key_access"
+}
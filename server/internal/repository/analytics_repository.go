@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnalyticsHit 一次有效浏览（已过滤爬虫和短时间内的重复刷新）
+type AnalyticsHit struct {
+	Day       string
+	EventType string
+	Target    string
+	Visitor   string
+}
+
+// AnalyticsTop 排行榜条目
+type AnalyticsTop struct {
+	Target string `json:"target"`
+	Title  string `json:"title"`
+	PV     int64  `json:"pv"`
+	UV     int64  `json:"uv"` // 各天 UV 之和（访客·天）
+}
+
+// analyticsTitleSources 各事件类型的 Target 对应的标题来源
+var analyticsTitleSources = map[string]struct{ table, column string }{
+	model.EventCourse:   {"hpa_courses", "title"},
+	model.EventNote:     {"hpa_notes", "title"},
+	model.EventDownload: {"hpa_course_files", "file_name"},
+}
+
+type AnalyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+// analyticsKey 每日汇总的维度
+type analyticsKey struct {
+	day, eventType, target string
+}
+
+// SaveHits 写入一批浏览：登记当日访客（首次出现计 UV），累加每日汇总
+// 每次浏览同时计入具体目标和该类型的全站汇总（Target 为空）
+// 笔记和课程的 view_count 同步累加
+func (r *AnalyticsRepository) SaveHits(hits []AnalyticsHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		deltas := make(map[analyticsKey]*model.AnalyticsDaily)
+		var order []analyticsKey
+
+		for _, hit := range hits {
+			for _, target := range []string{hit.Target, ""} {
+				key := analyticsKey{hit.Day, hit.EventType, target}
+				d, ok := deltas[key]
+				if !ok {
+					d = &model.AnalyticsDaily{Day: key.day, EventType: key.eventType, Target: key.target}
+					deltas[key] = d
+					order = append(order, key)
+				}
+				d.PV++
+
+				visitor := model.AnalyticsVisitor{Day: key.day, EventType: key.eventType, Target: key.target, Visitor: hit.Visitor}
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&visitor)
+				if result.Error != nil {
+					return result.Error
+				}
+				d.UV += result.RowsAffected
+			}
+		}
+
+		for _, key := range order {
+			d := deltas[key]
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "day"}, {Name: "event_type"}, {Name: "target"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"pv":         gorm.Expr("pv + ?", d.PV),
+					"uv":         gorm.Expr("uv + ?", d.UV),
+					"updated_at": time.Now(),
+				}),
+			}).Create(d).Error
+			if err != nil {
+				return err
+			}
+
+			// 浏览次数
+			table := ""
+			switch key.eventType {
+			case model.EventNote:
+				table = "hpa_notes"
+			case model.EventCourse:
+				table = "hpa_courses"
+			}
+			if table == "" || key.target == "" {
+				continue
+			}
+			id, err := strconv.ParseUint(key.target, 10, 64)
+			if err != nil {
+				continue
+			}
+			if err := tx.Table(table).Where("id = ?", id).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", d.PV)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDailySeries 获取指定目标（为空表示全站汇总）在日期区间内的每日数据
+func (r *AnalyticsRepository) GetDailySeries(eventType, target, from, to string) ([]model.AnalyticsDaily, error) {
+	var list []model.AnalyticsDaily
+	err := r.db.Where("event_type = ? AND target = ? AND day BETWEEN ? AND ?", eventType, target, from, to).
+		Order("day ASC").
+		Find(&list).Error
+	return list, err
+}
+
+// GetTopTargets 按 PV 排序获取日期区间内的热门目标，并附带标题
+func (r *AnalyticsRepository) GetTopTargets(eventType, from, to string, limit int) ([]AnalyticsTop, error) {
+	var list []AnalyticsTop
+	query := r.db.Table("hpa_analytics_daily AS d").
+		Where("d.event_type = ? AND d.target <> '' AND d.day BETWEEN ? AND ?", eventType, from, to).
+		Group("d.target").
+		Order("pv DESC").
+		Limit(limit)
+
+	if src, ok := analyticsTitleSources[eventType]; ok {
+		query = query.
+			Select("d.target, COALESCE(MAX(t." + src.column + "), '') AS title, SUM(d.pv) AS pv, SUM(d.uv) AS uv").
+			Joins("LEFT JOIN " + src.table + " AS t ON CAST(t.id AS TEXT) = d.target")
+	} else {
+		query = query.Select("d.target, d.target AS title, SUM(d.pv) AS pv, SUM(d.uv) AS uv")
+	}
+	err := query.Scan(&list).Error
+	return list, err
+}
+
+// PruneVisitors 删除早于指定日期的访客记录（UV 已计入每日汇总）
+func (r *AnalyticsRepository) PruneVisitors(before string) (int64, error) {
+	result := r.db.Where("day < ?", before).Delete(&model.AnalyticsVisitor{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type AnnouncementRepository struct {
+	db *gorm.DB
+}
+
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+// Create 创建公告
+func (r *AnnouncementRepository) Create(a *model.Announcement) error {
+	return r.db.Create(a).Error
+}
+
+// Update 更新公告
+func (r *AnnouncementRepository) Update(a *model.Announcement) error {
+	return r.db.Save(a).Error
+}
+
+// Delete 删除公告
+func (r *AnnouncementRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Announcement{}, id).Error
+}
+
+// GetByID 根据 ID 获取公告
+func (r *AnnouncementRepository) GetByID(id uint) (*model.Announcement, error) {
+	var a model.Announcement
+	err := r.db.First(&a, id).Error
+	return &a, err
+}
+
+// List 获取全部公告（管理后台），annType 为空不按类型过滤
+func (r *AnnouncementRepository) List(annType string, page, pageSize int) ([]model.Announcement, int64, error) {
+	var list []model.Announcement
+	var total int64
+
+	query := r.db.Model(&model.Announcement{})
+	if annType != "" {
+		query = query.Where("type = ?", annType)
+	}
+	query.Count(&total)
+
+	err := query.Order("pinned DESC, start_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error
+	return list, total, err
+}
+
+// GetActive 获取当前展示中的公告：已开始且未结束，可见范围在 audiences 内；置顶的在前
+func (r *AnnouncementRepository) GetActive(now time.Time, audiences []string, limit int) ([]model.Announcement, error) {
+	var list []model.Announcement
+	err := r.db.
+		Where("start_at <= ? AND (end_at IS NULL OR end_at > ?)", now, now).
+		Where("audience IN ?", audiences).
+		Order("pinned DESC, start_at DESC, id DESC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
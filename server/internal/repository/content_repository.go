@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"car4race/internal/model"
 
 	"gorm.io/gorm"
@@ -16,13 +18,10 @@ func NewContentRepository(db *gorm.DB) *ContentRepository {
 
 // ========== Category ==========
 
-// GetAllCategories 获取所有分类（树形结构）
+// GetAllCategories 获取所有分类（平铺，按排序），树形结构由调用方组装
 func (r *ContentRepository) GetAllCategories() ([]model.Category, error) {
 	var categories []model.Category
-	err := r.db.Where("parent_id IS NULL").
-		Preload("Children").
-		Order("sort ASC").
-		Find(&categories).Error
+	err := r.db.Order("sort ASC, id ASC").Find(&categories).Error
 	return categories, err
 }
 
@@ -33,6 +32,43 @@ func (r *ContentRepository) GetCategoryBySlug(slug string) (*model.Category, err
 	return &category, err
 }
 
+// GetCategoryByID 根据 ID 获取分类
+func (r *ContentRepository) GetCategoryByID(id uint) (*model.Category, error) {
+	var category model.Category
+	err := r.db.First(&category, id).Error
+	return &category, err
+}
+
+// CountListedNotesByCategory 统计各分类下的公开笔记数（不含子分类）
+func (r *ContentRepository) CountListedNotesByCategory() (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := r.db.Model(&model.Note{}).Scopes(listedScope).
+		Select("category_id, COUNT(*) AS count").
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountCategoryContents 统计分类的直接子分类数和笔记数（含未发布笔记）
+func (r *ContentRepository) CountCategoryContents(id uint) (children, notes int64, err error) {
+	if err = r.db.Model(&model.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return
+	}
+	err = r.db.Model(&model.Note{}).Where("category_id = ?", id).Count(&notes).Error
+	return
+}
+
 // CreateCategory 创建分类
 func (r *ContentRepository) CreateCategory(category *model.Category) error {
 	return r.db.Create(category).Error
@@ -43,27 +79,80 @@ func (r *ContentRepository) UpdateCategory(category *model.Category) error {
 	return r.db.Save(category).Error
 }
 
-// DeleteCategory 删除分类
-func (r *ContentRepository) DeleteCategory(id uint) error {
-	return r.db.Delete(&model.Category{}, id).Error
+// GetMaxCategorySort 获取同级分类的最大排序值
+func (r *ContentRepository) GetMaxCategorySort(parentID *uint) (int, error) {
+	var maxSort int
+	query := r.db.Model(&model.Category{})
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	err := query.Select("COALESCE(MAX(sort), 0)").Scan(&maxSort).Error
+	return maxSort, err
+}
+
+// MoveCategory 修改分类的父分类和排序
+func (r *ContentRepository) MoveCategory(id uint, parentID *uint, sort int) error {
+	return r.db.Model(&model.Category{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"parent_id": parentID, "sort": sort}).Error
+}
+
+// ReorderCategories 按给定顺序重排同级分类，列表中的分类都归入 parentID 下
+func (r *ContentRepository) ReorderCategories(parentID *uint, ids []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&model.Category{}).
+				Where("id = ?", id).
+				Updates(map[string]interface{}{"parent_id": parentID, "sort": i + 1})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCategory 删除分类；reassignTo 不为空时先将笔记和子分类移到目标分类
+func (r *ContentRepository) DeleteCategory(id uint, reassignTo *uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if reassignTo != nil {
+			if err := tx.Unscoped().Model(&model.Note{}).Where("category_id = ?", id).
+				Update("category_id", *reassignTo).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).
+				Update("parent_id", *reassignTo).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.Category{}, id).Error
+	})
 }
 
 // ========== Note ==========
 
-// GetNotes 获取笔记列表
-func (r *ContentRepository) GetNotes(categoryID uint, page, pageSize int) ([]model.Note, int64, error) {
+// GetNotes 获取笔记列表，categoryIDs 为空不按分类过滤，tag 为标签 slug（为空不过滤）
+func (r *ContentRepository) GetNotes(categoryIDs []uint, tag string, page, pageSize int) ([]model.Note, int64, error) {
 	var notes []model.Note
 	var total int64
 
-	query := r.db.Model(&model.Note{}).Where("is_public = ?", true)
-	if categoryID > 0 {
-		query = query.Where("category_id = ?", categoryID)
+	query := r.db.Model(&model.Note{}).Scopes(listedScope)
+	if len(categoryIDs) > 0 {
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+	if tag != "" {
+		query = query.Scopes(taggedScope("hpa_note_tags", "note_id", tag))
 	}
 
 	query.Count(&total)
 
 	err := query.
 		Preload("Category").
+		Preload("Tags").
 		Order("sort DESC, created_at DESC").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
@@ -72,28 +161,115 @@ func (r *ContentRepository) GetNotes(categoryID uint, page, pageSize int) ([]mod
 	return notes, total, err
 }
 
+// GetFeedNotes 获取订阅源中的最新笔记（按发布时间倒序），categoryIDs 为空表示全部分类
+func (r *ContentRepository) GetFeedNotes(categoryIDs []uint, limit int) ([]model.Note, error) {
+	var notes []model.Note
+	query := r.db.Model(&model.Note{}).Scopes(listedScope)
+	if len(categoryIDs) > 0 {
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+	err := query.
+		Preload("Category").
+		Order("COALESCE(publish_at, created_at) DESC, id DESC").
+		Limit(limit).
+		Find(&notes).Error
+	return notes, err
+}
+
+// GetSitemapNotes 获取站点地图中的已发布笔记，只查询生成地址所需的字段
+func (r *ContentRepository) GetSitemapNotes(limit int) ([]model.Note, error) {
+	var notes []model.Note
+	err := r.db.Model(&model.Note{}).Scopes(listedScope).
+		Select("id, category_id, slug, updated_at").
+		Order("updated_at DESC").
+		Limit(limit).
+		Find(&notes).Error
+	return notes, err
+}
+
 // GetNoteBySlug 根据 slug 获取笔记
 func (r *ContentRepository) GetNoteBySlug(slug string) (*model.Note, error) {
 	var note model.Note
-	err := r.db.Preload("Category").Where("slug = ?", slug).First(&note).Error
+	err := r.db.Preload("Category").Preload("Tags").Where("slug = ?", slug).First(&note).Error
 	return &note, err
 }
 
 // GetNoteByID 根据 ID 获取笔记
 func (r *ContentRepository) GetNoteByID(id uint) (*model.Note, error) {
 	var note model.Note
-	err := r.db.Preload("Category").First(&note, id).Error
+	err := r.db.Preload("Category").Preload("Tags").First(&note, id).Error
 	return &note, err
 }
 
-// CreateNote 创建笔记
-func (r *ContentRepository) CreateNote(note *model.Note) error {
-	return r.db.Create(note).Error
+// GetNoteIDs 获取全部笔记 ID（含未发布，用于重建搜索索引）
+func (r *ContentRepository) GetNoteIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.Note{}).Order("id ASC").Pluck("id", &ids).Error
+	return ids, err
 }
 
-// UpdateNote 更新笔记
-func (r *ContentRepository) UpdateNote(note *model.Note) error {
-	return r.db.Save(note).Error
+// CreateNote 创建笔记，同时记录版本 1
+func (r *ContentRepository) CreateNote(note *model.Note, authorID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		note.Version = 1
+		if err := tx.Create(note).Error; err != nil {
+			return err
+		}
+		return tx.Create(newNoteRevision(note, authorID, "")).Error
+	})
+}
+
+// UpdateNoteWithVersion 按版本号更新笔记并记录新版本（乐观锁）
+// 数据库中的版本号与 expectedVersion 不一致时不做修改，返回 false
+func (r *ContentRepository) UpdateNoteWithVersion(note *model.Note, expectedVersion int, authorID uint, comment string) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// 历史笔记没有修订记录，先为当前内容补一条基线快照，保证可回滚
+		var count int64
+		if err := tx.Model(&model.NoteRevision{}).Where("note_id = ?", note.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			var current model.Note
+			if err := tx.First(&current, note.ID).Error; err != nil {
+				return err
+			}
+			if current.Version == expectedVersion {
+				if err := tx.Create(newNoteRevision(&current, 0, "")).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		result := tx.Model(&model.Note{}).
+			Where("id = ? AND version = ?", note.ID, expectedVersion).
+			Updates(map[string]interface{}{
+				"category_id":      note.CategoryID,
+				"title":            note.Title,
+				"slug":             note.Slug,
+				"summary":          note.Summary,
+				"content":          note.Content,
+				"cover_image":      note.CoverImage,
+				"is_public":        note.IsPublic,
+				"status":           note.Status,
+				"publish_at":       note.PublishAt,
+				"sort":             note.Sort,
+				"access_level":     note.AccessLevel,
+				"access_course_id": note.AccessCourseID,
+				"version":          expectedVersion + 1,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		note.Version = expectedVersion + 1
+		updated = true
+		return tx.Create(newNoteRevision(note, authorID, comment)).Error
+	})
+	return updated, err
 }
 
 // DeleteNote 删除笔记
@@ -101,37 +277,49 @@ func (r *ContentRepository) DeleteNote(id uint) error {
 	return r.db.Delete(&model.Note{}, id).Error
 }
 
-// IncrementNoteViewCount 增加笔记浏览次数
-func (r *ContentRepository) IncrementNoteViewCount(id uint) error {
-	return r.db.Model(&model.Note{}).Where("id = ?", id).
-		UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+// PublishDueNotes 将到期的定时笔记置为已发布
+func (r *ContentRepository) PublishDueNotes(now time.Time) (int64, error) {
+	result := r.db.Model(&model.Note{}).
+		Where("status = ? AND publish_at <= ?", model.StatusScheduled, now).
+		Updates(map[string]interface{}{"status": model.StatusPublished, "is_public": true})
+	return result.RowsAffected, result.Error
 }
 
-// ========== BrowseHistory ==========
+// ========== NoteRevision ==========
 
-// AddBrowseHistory 添加浏览记录
-func (r *ContentRepository) AddBrowseHistory(userID, noteID uint) error {
-	history := &model.BrowseHistory{
-		UserID: userID,
-		NoteID: noteID,
-	}
-	return r.db.Create(history).Error
+// GetNoteRevisions 获取笔记的修订记录（不含正文，新版本在前）
+func (r *ContentRepository) GetNoteRevisions(noteID uint) ([]model.NoteRevision, error) {
+	var revisions []model.NoteRevision
+	err := r.db.Omit("content").
+		Preload("Author", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "nickname", "avatar")
+		}).
+		Where("note_id = ?", noteID).
+		Order("version DESC").
+		Find(&revisions).Error
+	return revisions, err
 }
 
-// GetUserBrowseHistory 获取用户浏览记录
-func (r *ContentRepository) GetUserBrowseHistory(userID uint, page, pageSize int) ([]model.BrowseHistory, int64, error) {
-	var history []model.BrowseHistory
-	var total int64
-
-	query := r.db.Model(&model.BrowseHistory{}).Where("user_id = ?", userID)
-	query.Count(&total)
-
-	err := query.
-		Preload("Note").
-		Order("created_at DESC").
-		Offset((page - 1) * pageSize).
-		Limit(pageSize).
-		Find(&history).Error
+// GetNoteRevision 获取笔记的指定版本
+func (r *ContentRepository) GetNoteRevision(noteID uint, version int) (*model.NoteRevision, error) {
+	var revision model.NoteRevision
+	err := r.db.Preload("Author", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "nickname", "avatar")
+	}).
+		Where("note_id = ? AND version = ?", noteID, version).
+		First(&revision).Error
+	return &revision, err
+}
 
-	return history, total, err
+// newNoteRevision 根据笔记当前内容生成修订记录
+func newNoteRevision(note *model.Note, authorID uint, comment string) *model.NoteRevision {
+	return &model.NoteRevision{
+		NoteID:   note.ID,
+		Version:  note.Version,
+		Title:    note.Title,
+		Summary:  note.Summary,
+		Content:  note.Content,
+		AuthorID: authorID,
+		Comment:  comment,
+	}
 }
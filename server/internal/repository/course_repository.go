@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"math"
 	"time"
 
 	"car4race/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CourseRepository struct {
@@ -18,12 +20,15 @@ func NewCourseRepository(db *gorm.DB) *CourseRepository {
 
 // ========== Course ==========
 
-// GetCourses 获取课程列表
-func (r *CourseRepository) GetCourses(page, pageSize int, sortBy string) ([]model.Course, int64, error) {
+// GetCourses 获取课程列表，tag 为标签 slug（为空不过滤）
+func (r *CourseRepository) GetCourses(page, pageSize int, sortBy, tag string) ([]model.Course, int64, error) {
 	var courses []model.Course
 	var total int64
 
-	query := r.db.Model(&model.Course{}).Where("is_public = ?", true)
+	query := r.db.Model(&model.Course{}).Scopes(listedScope)
+	if tag != "" {
+		query = query.Scopes(taggedScope("hpa_course_tags", "course_id", tag))
+	}
 	query.Count(&total)
 
 	// 排序方式
@@ -35,11 +40,16 @@ func (r *CourseRepository) GetCourses(page, pageSize int, sortBy string) ([]mode
 		orderBy = "price DESC"
 	case "sales":
 		orderBy = "sales_count DESC"
+	case "views":
+		orderBy = "view_count DESC, sales_count DESC"
+	case "rating":
+		orderBy = "rating_avg DESC, rating_count DESC"
 	case "newest":
 		orderBy = "created_at DESC"
 	}
 
 	err := query.Order(orderBy).
+		Preload("Tags").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
 		Find(&courses).Error
@@ -47,6 +57,17 @@ func (r *CourseRepository) GetCourses(page, pageSize int, sortBy string) ([]mode
 	return courses, total, err
 }
 
+// GetSitemapCourses 获取站点地图中的已发布课程，只查询生成地址所需的字段
+func (r *CourseRepository) GetSitemapCourses(limit int) ([]model.Course, error) {
+	var courses []model.Course
+	err := r.db.Model(&model.Course{}).Scopes(listedScope).
+		Select("id, slug, updated_at").
+		Order("updated_at DESC").
+		Limit(limit).
+		Find(&courses).Error
+	return courses, err
+}
+
 // GetCourseBySlug 根据 slug 获取课程
 func (r *CourseRepository) GetCourseBySlug(slug string) (*model.Course, error) {
 	var course model.Course
@@ -76,6 +97,42 @@ func (r *CourseRepository) DeleteCourse(id uint) error {
 	return r.db.Delete(&model.Course{}, id).Error
 }
 
+// UpdateCourseIntroPath 更新课程介绍文件路径
+func (r *CourseRepository) UpdateCourseIntroPath(id uint, introPath string) error {
+	return r.db.Model(&model.Course{}).Where("id = ?", id).Update("intro_path", introPath).Error
+}
+
+// GetCoursesWithIntro 获取设置了介绍文件的课程
+func (r *CourseRepository) GetCoursesWithIntro() ([]model.Course, error) {
+	var courses []model.Course
+	err := r.db.Where("intro_path <> ''").Find(&courses).Error
+	return courses, err
+}
+
+// GetCourseIDs 获取全部课程 ID（含未发布，用于重建搜索索引）
+func (r *CourseRepository) GetCourseIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.Course{}).Order("id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetDeletedCourseIDs 获取已软删除课程的 ID
+func (r *CourseRepository) GetDeletedCourseIDs() ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&model.Course{}).
+		Where("deleted_at IS NOT NULL").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// PublishDueCourses 将到期的定时课程置为已发布
+func (r *CourseRepository) PublishDueCourses(now time.Time) (int64, error) {
+	result := r.db.Model(&model.Course{}).
+		Where("status = ? AND publish_at <= ?", model.StatusScheduled, now).
+		Updates(map[string]interface{}{"status": model.StatusPublished, "is_public": true})
+	return result.RowsAffected, result.Error
+}
+
 // IncrementSalesCount 增加销量
 func (r *CourseRepository) IncrementSalesCount(id uint) error {
 	return r.db.Model(&model.Course{}).Where("id = ?", id).
@@ -92,7 +149,8 @@ func (r *CourseRepository) CreateOrder(order *model.Order) error {
 // GetOrderByNo 根据订单号获取订单
 func (r *CourseRepository) GetOrderByNo(orderNo string) (*model.Order, error) {
 	var order model.Order
-	err := r.db.Preload("Course").Where("order_no = ?", orderNo).First(&order).Error
+	err := r.db.Preload("Course").Preload("Bundle").Preload("Items.Course").
+		Where("order_no = ?", orderNo).First(&order).Error
 	return &order, err
 }
 
@@ -106,6 +164,8 @@ func (r *CourseRepository) GetUserOrders(userID uint, page, pageSize int) ([]mod
 
 	err := query.
 		Preload("Course").
+		Preload("Bundle").
+		Preload("Items.Course").
 		Order("created_at DESC").
 		Offset((page - 1) * pageSize).
 		Limit(pageSize).
@@ -124,13 +184,281 @@ func (r *CourseRepository) UpdateOrderStatus(orderNo, status string) error {
 	return r.db.Model(&model.Order{}).Where("order_no = ?", orderNo).Updates(updates).Error
 }
 
-// CheckUserPurchased 检查用户是否已购买课程
+// ownedCourseIDs 用户已购课程 ID 的子查询：单课程订单及订单明细
+// 套餐订单在下单时为每门课程生成明细，按购买时的明细授权，不受套餐后续调整影响
+func (r *CourseRepository) ownedCourseIDs(userID uint) *gorm.DB {
+	paid := r.db.Model(&model.Order{}).Select("id").Where("user_id = ? AND status = ?", userID, "paid")
+	return r.db.Raw(`
+		SELECT course_id FROM hpa_orders WHERE course_id > 0 AND id IN (?)
+		UNION SELECT course_id FROM hpa_order_items WHERE order_id IN (?)`,
+		paid, paid)
+}
+
+// CheckUserPurchased 检查用户是否已购买课程（含多课程订单和套餐）
 func (r *CourseRepository) CheckUserPurchased(userID, courseID uint) (bool, error) {
-	var count int64
-	err := r.db.Model(&model.Order{}).
-		Where("user_id = ? AND course_id = ? AND status = ?", userID, courseID, "paid").
-		Count(&count).Error
-	return count > 0, err
+	var ids []uint
+	err := r.db.Raw("SELECT course_id FROM (?) AS owned WHERE course_id = ? LIMIT 1", r.ownedCourseIDs(userID), courseID).
+		Scan(&ids).Error
+	return len(ids) > 0, err
+}
+
+// GetUserOwnedCourseIDs 获取用户已购买的全部课程 ID
+func (r *CourseRepository) GetUserOwnedCourseIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.ownedCourseIDs(userID).Scan(&ids).Error
+	return ids, err
+}
+
+// ========== LearningProgress ==========
+
+// GetUserCourseProgress 获取用户在课程下的全部学习进度
+func (r *CourseRepository) GetUserCourseProgress(userID, courseID uint) ([]model.LearningProgress, error) {
+	var list []model.LearningProgress
+	err := r.db.Where("user_id = ? AND course_id = ?", userID, courseID).
+		Order("updated_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+// UpsertProgress 批量写入学习进度
+// 冲突时仅当上报时间更新才覆盖，重复或乱序的心跳不会回退进度
+func (r *CourseRepository) UpsertProgress(list []model.LearningProgress) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"position", "total", "completed", "completed_at", "client_time", "updated_at",
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "excluded.client_time > hpa_learning_progress.client_time"},
+		}},
+	}).Create(&list).Error
+}
+
+// ========== Review ==========
+
+// reviewAuthor 评价列表只加载作者的公开信息
+func reviewAuthor(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "nickname", "avatar")
+}
+
+// GetCourseReviews 获取课程的可见评价（置顶优先）
+func (r *CourseRepository) GetCourseReviews(courseID uint, page, pageSize int) ([]model.Review, int64, error) {
+	var reviews []model.Review
+	var total int64
+
+	query := r.db.Model(&model.Review{}).Where("course_id = ? AND is_hidden = ?", courseID, false)
+	query.Count(&total)
+
+	err := query.Preload("User", reviewAuthor).
+		Order("is_pinned DESC, created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&reviews).Error
+
+	return reviews, total, err
+}
+
+// GetAllReviews 获取评价列表（管理后台用），courseID 为 0 时不限课程
+func (r *CourseRepository) GetAllReviews(courseID uint, hidden *bool, page, pageSize int) ([]model.Review, int64, error) {
+	var reviews []model.Review
+	var total int64
+
+	query := r.db.Model(&model.Review{})
+	if courseID > 0 {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if hidden != nil {
+		query = query.Where("is_hidden = ?", *hidden)
+	}
+	query.Count(&total)
+
+	err := query.Preload("User", reviewAuthor).
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&reviews).Error
+
+	return reviews, total, err
+}
+
+// GetReviewByID 根据 ID 获取评价
+func (r *CourseRepository) GetReviewByID(id uint) (*model.Review, error) {
+	var review model.Review
+	err := r.db.First(&review, id).Error
+	return &review, err
+}
+
+// GetUserReview 获取用户对课程的评价
+func (r *CourseRepository) GetUserReview(userID, courseID uint) (*model.Review, error) {
+	var review model.Review
+	err := r.db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&review).Error
+	return &review, err
+}
+
+// SaveReview 创建或更新评价
+func (r *CourseRepository) SaveReview(review *model.Review) error {
+	return r.db.Omit("User").Save(review).Error
+}
+
+// UpdateReviewModeration 更新评价的隐藏/置顶状态
+func (r *CourseRepository) UpdateReviewModeration(id uint, hidden, pinned bool) error {
+	return r.db.Model(&model.Review{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"is_hidden": hidden, "is_pinned": pinned}).Error
+}
+
+// DeleteReview 删除评价
+func (r *CourseRepository) DeleteReview(id uint) error {
+	return r.db.Delete(&model.Review{}, id).Error
+}
+
+// RefreshCourseRating 按可见评价重新计算课程的平均星级和评价数
+func (r *CourseRepository) RefreshCourseRating(courseID uint) error {
+	var stat struct {
+		Avg   float64
+		Count int
+	}
+	err := r.db.Model(&model.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS avg, COUNT(*) AS count").
+		Where("course_id = ? AND is_hidden = ?", courseID, false).
+		Scan(&stat).Error
+	if err != nil {
+		return err
+	}
+	return r.db.Model(&model.Course{}).Where("id = ?", courseID).
+		UpdateColumns(map[string]interface{}{
+			"rating_avg":   math.Round(stat.Avg*100) / 100,
+			"rating_count": stat.Count,
+		}).Error
+}
+
+// ========== Certificate ==========
+
+// CreateCertificate 创建证书
+func (r *CourseRepository) CreateCertificate(cert *model.Certificate) error {
+	return r.db.Create(cert).Error
+}
+
+// GetCertificateBySerial 根据编号获取证书
+func (r *CourseRepository) GetCertificateBySerial(serial string) (*model.Certificate, error) {
+	var cert model.Certificate
+	err := r.db.Where("serial = ?", serial).First(&cert).Error
+	return &cert, err
+}
+
+// GetCertificateByID 根据 ID 获取证书
+func (r *CourseRepository) GetCertificateByID(id uint) (*model.Certificate, error) {
+	var cert model.Certificate
+	err := r.db.First(&cert, id).Error
+	return &cert, err
+}
+
+// GetUserCertificate 获取用户某课程的证书
+func (r *CourseRepository) GetUserCertificate(userID, courseID uint) (*model.Certificate, error) {
+	var cert model.Certificate
+	err := r.db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&cert).Error
+	return &cert, err
+}
+
+// GetUserCertificates 获取用户的全部证书
+func (r *CourseRepository) GetUserCertificates(userID uint) ([]model.Certificate, error) {
+	var certs []model.Certificate
+	err := r.db.Where("user_id = ?", userID).Order("issued_at DESC").Find(&certs).Error
+	return certs, err
+}
+
+// GetAllCertificates 获取证书列表（管理后台用），courseID 为 0 时不限课程
+func (r *CourseRepository) GetAllCertificates(courseID uint, page, pageSize int) ([]model.Certificate, int64, error) {
+	var certs []model.Certificate
+	var total int64
+
+	query := r.db.Model(&model.Certificate{})
+	if courseID > 0 {
+		query = query.Where("course_id = ?", courseID)
+	}
+	query.Count(&total)
+
+	err := query.Order("issued_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&certs).Error
+
+	return certs, total, err
+}
+
+// UpdateCertificateFile 更新证书文件路径
+func (r *CourseRepository) UpdateCertificateFile(id uint, filePath string) error {
+	return r.db.Model(&model.Certificate{}).Where("id = ?", id).Update("file_path", filePath).Error
+}
+
+// RevokeCertificate 吊销证书
+func (r *CourseRepository) RevokeCertificate(id uint) error {
+	return r.db.Model(&model.Certificate{}).Where("id = ?", id).Update("revoked_at", time.Now()).Error
+}
+
+// ========== Bundle ==========
+
+// GetBundles 获取公开的套餐列表
+func (r *CourseRepository) GetBundles() ([]model.Bundle, error) {
+	var bundles []model.Bundle
+	err := r.db.Where("is_public = ?", true).
+		Preload("Courses", listedScope).
+		Order("sort ASC, created_at DESC").
+		Find(&bundles).Error
+	return bundles, err
+}
+
+// GetAllBundles 获取所有套餐（管理后台用）
+func (r *CourseRepository) GetAllBundles() ([]model.Bundle, error) {
+	var bundles []model.Bundle
+	err := r.db.Preload("Courses").Order("sort ASC, created_at DESC").Find(&bundles).Error
+	return bundles, err
+}
+
+// GetBundleBySlug 根据 slug 获取套餐及其课程
+func (r *CourseRepository) GetBundleBySlug(slug string) (*model.Bundle, error) {
+	var bundle model.Bundle
+	err := r.db.Preload("Courses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC, id ASC")
+	}).Where("slug = ?", slug).First(&bundle).Error
+	return &bundle, err
+}
+
+// GetBundleByID 根据 ID 获取套餐及其课程
+func (r *CourseRepository) GetBundleByID(id uint) (*model.Bundle, error) {
+	var bundle model.Bundle
+	err := r.db.Preload("Courses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC, id ASC")
+	}).First(&bundle, id).Error
+	return &bundle, err
+}
+
+// SaveBundle 创建或更新套餐，并替换其包含的课程
+func (r *CourseRepository) SaveBundle(bundle *model.Bundle, courses []model.Course) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Courses").Save(bundle).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(bundle).Association("Courses").Replace(courses); err != nil {
+			return err
+		}
+		bundle.Courses = courses
+		return nil
+	})
+}
+
+// DeleteBundle 删除套餐（已售订单按订单明细授权，不受影响）
+func (r *CourseRepository) DeleteBundle(id uint) error {
+	return r.db.Delete(&model.Bundle{}, id).Error
+}
+
+// GetCoursesByIDs 根据 ID 批量获取课程
+func (r *CourseRepository) GetCoursesByIDs(ids []uint) ([]model.Course, error) {
+	var courses []model.Course
+	err := r.db.Where("id IN ?", ids).Find(&courses).Error
+	return courses, err
 }
 
 // ========== InviteCode ==========
@@ -224,6 +552,46 @@ func (r *CourseRepository) CreateCourseFile(file *model.CourseFile) error {
 	return r.db.Create(file).Error
 }
 
+// SaveCourseFile 在一个事务中创建文件记录：排在课程文件末尾，setIntro 时同时更新课程介绍路径
+// pendingUploadID 非 0 时先认领对应的待确认上传记录，记录已被其他请求认领则返回 gorm.ErrRecordNotFound
+func (r *CourseRepository) SaveCourseFile(file *model.CourseFile, setIntro bool, pendingUploadID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if pendingUploadID > 0 {
+			result := tx.Where("id = ?", pendingUploadID).Delete(&model.PendingUpload{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+
+		var maxSort int
+		if err := tx.Model(&model.CourseFile{}).
+			Where("course_id = ?", file.CourseID).
+			Select("COALESCE(MAX(sort), 0)").
+			Scan(&maxSort).Error; err != nil {
+			return err
+		}
+		file.Sort = maxSort + 1
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+
+		if setIntro {
+			return tx.Model(&model.Course{}).Where("id = ?", file.CourseID).Update("intro_path", file.FilePath).Error
+		}
+		return nil
+	})
+}
+
+// CourseFileExistsByPath 对象路径是否已有课程文件记录
+func (r *CourseRepository) CourseFileExistsByPath(filePath string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.CourseFile{}).Where("file_path = ?", filePath).Count(&count).Error
+	return count > 0, err
+}
+
 // GetCourseFileByID 根据 ID 获取课程文件
 func (r *CourseRepository) GetCourseFileByID(id uint) (*model.CourseFile, error) {
 	var file model.CourseFile
@@ -231,6 +599,18 @@ func (r *CourseRepository) GetCourseFileByID(id uint) (*model.CourseFile, error)
 	return &file, err
 }
 
+// GetCourseFileByChecksum 根据 SHA-256 查找课程下的文件
+func (r *CourseRepository) GetCourseFileByChecksum(courseID uint, checksum string) (*model.CourseFile, error) {
+	var file model.CourseFile
+	err := r.db.Where("course_id = ? AND checksum = ?", courseID, checksum).First(&file).Error
+	return &file, err
+}
+
+// UpdateCourseFileChecksum 更新课程文件的 SHA-256
+func (r *CourseRepository) UpdateCourseFileChecksum(id uint, checksum string) error {
+	return r.db.Model(&model.CourseFile{}).Where("id = ?", id).Update("checksum", checksum).Error
+}
+
 // GetCourseFiles 获取课程的所有文件
 func (r *CourseRepository) GetCourseFiles(courseID uint) ([]model.CourseFile, error) {
 	var files []model.CourseFile
@@ -238,19 +618,68 @@ func (r *CourseRepository) GetCourseFiles(courseID uint) ([]model.CourseFile, er
 	return files, err
 }
 
+// GetVideoDurations 获取课程已转码视频的时长（秒），按文件 ID 索引
+func (r *CourseRepository) GetVideoDurations(courseID uint) (map[uint]float64, error) {
+	var assets []model.VideoAsset
+	err := r.db.Select("file_id", "duration").
+		Where("course_id = ? AND status = ? AND duration > 0", courseID, "ready").
+		Find(&assets).Error
+	if err != nil {
+		return nil, err
+	}
+	durations := make(map[uint]float64, len(assets))
+	for _, a := range assets {
+		durations[a.FileID] = a.Duration
+	}
+	return durations, nil
+}
+
 // DeleteCourseFile 删除课程文件记录
 func (r *CourseRepository) DeleteCourseFile(id uint) error {
 	return r.db.Delete(&model.CourseFile{}, id).Error
 }
 
-// GetMaxCourseFileSort 获取课程文件的最大排序号
-func (r *CourseRepository) GetMaxCourseFileSort(courseID uint) (int, error) {
-	var maxSort int
-	err := r.db.Model(&model.CourseFile{}).
-		Where("course_id = ?", courseID).
-		Select("COALESCE(MAX(sort), 0)").
-		Scan(&maxSort).Error
-	return maxSort, err
+// GetAllCourseFiles 获取所有课程文件记录（存储一致性检查用）
+func (r *CourseRepository) GetAllCourseFiles() ([]model.CourseFile, error) {
+	var files []model.CourseFile
+	err := r.db.Order("id ASC").Find(&files).Error
+	return files, err
+}
+
+// GetCertificatePaths 获取已生成的证书 PDF 对象路径（存储一致性检查用）
+func (r *CourseRepository) GetCertificatePaths() ([]string, error) {
+	var paths []string
+	err := r.db.Model(&model.Certificate{}).Where("file_path <> ''").Pluck("file_path", &paths).Error
+	return paths, err
+}
+
+// imageReferenceColumns 可能引用上传图片的文本字段
+var imageReferenceColumns = []struct{ table, column string }{
+	{"hpa_notes", "content"},
+	{"hpa_notes", "cover_image"},
+	{"hpa_note_revisions", "content"},
+	{"hpa_courses", "description"},
+	{"hpa_courses", "cover_image"},
+	{"hpa_bundles", "description"},
+	{"hpa_bundles", "cover_image"},
+	{"hpa_reviews", "content"},
+	{"hpa_announcements", "content"},
+	{"hpa_announcements", "link"},
+	{"users", "avatar"},
+}
+
+// GetImageReferenceTexts 获取包含 marker 的文本字段内容（存储一致性检查用）
+// 按表名直接查询，软删除的记录也会返回，恢复后仍需要其中的图片
+func (r *CourseRepository) GetImageReferenceTexts(marker string) ([]string, error) {
+	var texts []string
+	for _, ref := range imageReferenceColumns {
+		var values []string
+		if err := r.db.Table(ref.table).Where(ref.column+" LIKE ?", "%"+marker+"%").Pluck(ref.column, &values).Error; err != nil {
+			return nil, err
+		}
+		texts = append(texts, values...)
+	}
+	return texts, nil
 }
 
 // GetCourseWithFiles 获取课程及其文件
@@ -265,6 +694,176 @@ func (r *CourseRepository) GetCourseBySlugWithFiles(slug string) (*model.Course,
 	var course model.Course
 	err := r.db.Preload("Files", func(db *gorm.DB) *gorm.DB {
 		return db.Order("sort ASC, created_at ASC")
-	}).Where("slug = ?", slug).First(&course).Error
+	}).Preload("Tags").Where("slug = ?", slug).First(&course).Error
 	return &course, err
 }
+
+// ========== Chapter / Lesson ==========
+
+// GetCourseOutline 获取课程大纲（章节及课时，按排序）
+func (r *CourseRepository) GetCourseOutline(courseID uint) ([]model.Chapter, error) {
+	var chapters []model.Chapter
+	err := r.db.Where("course_id = ?", courseID).
+		Preload("Lessons", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort ASC, id ASC")
+		}).
+		Preload("Lessons.File").
+		Order("sort ASC, id ASC").
+		Find(&chapters).Error
+	return chapters, err
+}
+
+// GetChapterByID 根据 ID 获取章节
+func (r *CourseRepository) GetChapterByID(id uint) (*model.Chapter, error) {
+	var chapter model.Chapter
+	err := r.db.First(&chapter, id).Error
+	return &chapter, err
+}
+
+// GetMaxChapterSort 获取课程章节的最大排序号
+func (r *CourseRepository) GetMaxChapterSort(courseID uint) (int, error) {
+	var maxSort int
+	err := r.db.Model(&model.Chapter{}).
+		Where("course_id = ?", courseID).
+		Select("COALESCE(MAX(sort), 0)").
+		Scan(&maxSort).Error
+	return maxSort, err
+}
+
+// CreateChapter 创建章节
+func (r *CourseRepository) CreateChapter(chapter *model.Chapter) error {
+	return r.db.Create(chapter).Error
+}
+
+// UpdateChapter 更新章节
+func (r *CourseRepository) UpdateChapter(chapter *model.Chapter) error {
+	return r.db.Save(chapter).Error
+}
+
+// DeleteChapter 删除章节及其课时
+func (r *CourseRepository) DeleteChapter(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chapter_id = ?", id).Delete(&model.Lesson{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Chapter{}, id).Error
+	})
+}
+
+// ReorderChapters 按给定顺序重排课程章节
+func (r *CourseRepository) ReorderChapters(courseID uint, ids []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&model.Chapter{}).
+				Where("id = ? AND course_id = ?", id, courseID).
+				Update("sort", i+1)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// GetLessonByID 根据 ID 获取课时
+func (r *CourseRepository) GetLessonByID(id uint) (*model.Lesson, error) {
+	var lesson model.Lesson
+	err := r.db.First(&lesson, id).Error
+	return &lesson, err
+}
+
+// GetMaxLessonSort 获取章节课时的最大排序号
+func (r *CourseRepository) GetMaxLessonSort(chapterID uint) (int, error) {
+	var maxSort int
+	err := r.db.Model(&model.Lesson{}).
+		Where("chapter_id = ?", chapterID).
+		Select("COALESCE(MAX(sort), 0)").
+		Scan(&maxSort).Error
+	return maxSort, err
+}
+
+// CreateLesson 创建课时
+func (r *CourseRepository) CreateLesson(lesson *model.Lesson) error {
+	return r.db.Create(lesson).Error
+}
+
+// UpdateLesson 更新课时
+func (r *CourseRepository) UpdateLesson(lesson *model.Lesson) error {
+	return r.db.Save(lesson).Error
+}
+
+// DeleteLesson 删除课时
+func (r *CourseRepository) DeleteLesson(id uint) error {
+	return r.db.Delete(&model.Lesson{}, id).Error
+}
+
+// ReorderLessons 按给定顺序重排课时，可将其他章节的课时拖入本章节
+func (r *CourseRepository) ReorderLessons(courseID, chapterID uint, ids []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&model.Lesson{}).
+				Where("id = ? AND course_id = ?", id, courseID).
+				Updates(map[string]interface{}{"chapter_id": chapterID, "sort": i + 1})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
+
+// ClearLessonFile 课程文件删除后解除课时关联
+func (r *CourseRepository) ClearLessonFile(fileID uint) error {
+	return r.db.Model(&model.Lesson{}).Where("file_id = ?", fileID).Update("file_id", nil).Error
+}
+
+// IsFreePreviewFile 检查文件是否关联了可试看的课时
+func (r *CourseRepository) IsFreePreviewFile(courseID, fileID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Lesson{}).
+		Where("course_id = ? AND file_id = ? AND is_free_preview = ?", courseID, fileID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ========== PendingUpload ==========
+
+// CreatePendingUpload 创建待确认上传记录
+func (r *CourseRepository) CreatePendingUpload(upload *model.PendingUpload) error {
+	return r.db.Create(upload).Error
+}
+
+// GetPendingUploadByKey 根据对象路径获取待确认上传记录
+func (r *CourseRepository) GetPendingUploadByKey(objectKey string) (*model.PendingUpload, error) {
+	var upload model.PendingUpload
+	err := r.db.Where("object_key = ?", objectKey).First(&upload).Error
+	return &upload, err
+}
+
+// DeletePendingUpload 删除待确认上传记录
+func (r *CourseRepository) DeletePendingUpload(id uint) error {
+	return r.db.Delete(&model.PendingUpload{}, id).Error
+}
+
+// GetPendingUploadKeys 获取所有待确认上传的对象路径
+func (r *CourseRepository) GetPendingUploadKeys() ([]string, error) {
+	var keys []string
+	err := r.db.Model(&model.PendingUpload{}).Pluck("object_key", &keys).Error
+	return keys, err
+}
+
+// GetExpiredPendingUploads 获取已过期仍未确认的上传记录
+func (r *CourseRepository) GetExpiredPendingUploads(before time.Time, limit int) ([]model.PendingUpload, error) {
+	var uploads []model.PendingUpload
+	err := r.db.Where("expire_at < ?", before).
+		Order("expire_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
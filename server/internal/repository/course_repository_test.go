@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+)
+
+func createCourse(t *testing.T, repo *CourseRepository, slug string, price float64) model.Course {
+	t.Helper()
+	course := model.Course{Title: slug, Slug: slug, Price: price}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course %s: %v", slug, err)
+	}
+	return course
+}
+
+func TestOwnedCoursesFollowOrderItemsNotBundleContents(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewCourseRepository(db)
+
+	a := createCourse(t, repo, "a", 100)
+	b := createCourse(t, repo, "b", 100)
+	bundle := &model.Bundle{Title: "season", Slug: "season", Price: 80}
+	if err := repo.SaveBundle(bundle, []model.Course{a}); err != nil {
+		t.Fatalf("save bundle: %v", err)
+	}
+
+	now := time.Now()
+	order := &model.Order{
+		OrderNo:  "B1",
+		UserID:   1,
+		BundleID: bundle.ID,
+		Amount:   80,
+		Status:   "paid",
+		PayTime:  &now,
+		Items:    []model.OrderItem{{CourseID: a.ID, BundleID: bundle.ID, Price: 100, Amount: 80}},
+	}
+	if err := repo.CreateOrder(order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	// 购买后往套餐中加入新课程，老用户不应免费获得
+	if err := repo.SaveBundle(bundle, []model.Course{a, b}); err != nil {
+		t.Fatalf("update bundle: %v", err)
+	}
+	if owned, err := repo.CheckUserPurchased(1, b.ID); err != nil || owned {
+		t.Errorf("course added to a bundle after purchase: owned = %v, err = %v; want false", owned, err)
+	}
+
+	// 套餐移除课程或被删除，不影响已购课程
+	if err := repo.SaveBundle(bundle, []model.Course{b}); err != nil {
+		t.Fatalf("update bundle: %v", err)
+	}
+	if err := repo.DeleteBundle(bundle.ID); err != nil {
+		t.Fatalf("delete bundle: %v", err)
+	}
+	if owned, err := repo.CheckUserPurchased(1, a.ID); err != nil || !owned {
+		t.Errorf("course bought in a removed bundle: owned = %v, err = %v; want true", owned, err)
+	}
+
+	ids, err := repo.GetUserOwnedCourseIDs(1)
+	if err != nil {
+		t.Fatalf("owned ids: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != a.ID {
+		t.Errorf("owned ids = %v, want [%d]", ids, a.ID)
+	}
+}
+
+func TestOwnedCoursesIgnoreUnpaidOrders(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewCourseRepository(db)
+
+	a := createCourse(t, repo, "a", 100)
+	if err := repo.CreateOrder(&model.Order{OrderNo: "P1", UserID: 1, CourseID: a.ID, Amount: 100, Status: "pending"}); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	if owned, _ := repo.CheckUserPurchased(1, a.ID); owned {
+		t.Error("pending order should not grant access")
+	}
+
+	if err := repo.UpdateOrderStatus("P1", "paid"); err != nil {
+		t.Fatalf("pay order: %v", err)
+	}
+	if owned, _ := repo.CheckUserPurchased(1, a.ID); !owned {
+		t.Error("paid single-course order should grant access")
+	}
+	if owned, _ := repo.CheckUserPurchased(2, a.ID); owned {
+		t.Error("another user's order should not grant access")
+	}
+}
+
+func TestSaveCourseFileClaimsPendingUploadOnce(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewCourseRepository(db)
+
+	course := createCourse(t, repo, "a", 100)
+	upload := &model.PendingUpload{CourseID: course.ID, FileType: "intro", FileName: "intro.md", ObjectKey: "courses/1/intro.md", ExpireAt: time.Now().Add(time.Hour)}
+	if err := repo.CreatePendingUpload(upload); err != nil {
+		t.Fatalf("create pending upload: %v", err)
+	}
+
+	first := &model.CourseFile{CourseID: course.ID, FileType: "intro", FileName: "intro.md", FilePath: upload.ObjectKey}
+	if err := repo.SaveCourseFile(first, true, upload.ID); err != nil {
+		t.Fatalf("first confirm: %v", err)
+	}
+	second := &model.CourseFile{CourseID: course.ID, FileType: "intro", FileName: "intro.md", FilePath: upload.ObjectKey}
+	if err := repo.SaveCourseFile(second, true, upload.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("second confirm err = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	files, err := repo.GetCourseFiles(course.ID)
+	if err != nil || len(files) != 1 || files[0].Sort != 1 {
+		t.Errorf("files = %+v, err = %v; want one file with sort 1", files, err)
+	}
+	if exists, _ := repo.CourseFileExistsByPath(upload.ObjectKey); !exists {
+		t.Error("confirmed object should have a course file row")
+	}
+	if got, _ := repo.GetCourseByID(course.ID); got.IntroPath != upload.ObjectKey {
+		t.Errorf("intro path = %q, want %q", got.IntroPath, upload.ObjectKey)
+	}
+}
+
+func TestImageReferenceTextsIncludeSoftDeleted(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewCourseRepository(db)
+
+	course := model.Course{Title: "a", Slug: "a", CoverImage: "/api/v1/hpa/images/ab/cover.png"}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	if err := db.Delete(&course).Error; err != nil {
+		t.Fatalf("delete course: %v", err)
+	}
+	if err := db.Create(&model.User{Username: "u", Phone: "u", Avatar: "https://example.com/a.png"}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	texts, err := repo.GetImageReferenceTexts("/api/v1/hpa/images/")
+	if err != nil {
+		t.Fatalf("get texts: %v", err)
+	}
+	if len(texts) != 1 || texts[0] != course.CoverImage {
+		t.Errorf("texts = %v, want [%s]", texts, course.CoverImage)
+	}
+}
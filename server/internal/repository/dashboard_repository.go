@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DailyAmount 每日金额与订单数
+type DailyAmount struct {
+	Day    string  `json:"day"`
+	Amount float64 `json:"amount"`
+	Orders int64   `json:"orders"`
+}
+
+// DailyCount 每日计数
+type DailyCount struct {
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+// PayMethodAmount 按支付方式汇总
+type PayMethodAmount struct {
+	PayMethod string  `json:"pay_method"`
+	Amount    float64 `json:"amount"`
+	Orders    int64   `json:"orders"`
+}
+
+// StatusCount 按订单状态汇总
+type StatusCount struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// CourseSales 课程销量
+type CourseSales struct {
+	CourseID uint    `json:"course_id"`
+	Title    string  `json:"title"`
+	Sales    int64   `json:"sales"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// InviteCodeUsage 邀请码使用情况
+type InviteCodeUsage struct {
+	Codes       int64 `json:"codes"`        // 邀请码总数
+	ActiveCodes int64 `json:"active_codes"` // 启用且未过期、未用完
+	Capacity    int64 `json:"capacity"`     // 可兑换总次数
+	Used        int64 `json:"used"`         // 已兑换总次数
+}
+
+// DownloadVolume 下载量
+type DownloadVolume struct {
+	Tokens int64 `json:"tokens"` // 申请的下载次数
+	Used   int64 `json:"used"`   // 实际使用的下载链接
+	Users  int64 `json:"users"`  // 下载用户数
+}
+
+// dayExpr 按存储的本地时间截取日期（YYYY-MM-DD）
+// 不用 date()，它会把带时区的时间换算成 UTC
+func dayExpr(column string) string {
+	return "substr(" + column + ", 1, 10)"
+}
+
+// DashboardRepository 管理后台概览统计，只读聚合查询
+type DashboardRepository struct {
+	db *gorm.DB
+}
+
+func NewDashboardRepository(db *gorm.DB) *DashboardRepository {
+	return &DashboardRepository{db: db}
+}
+
+// paidOrders 区间内支付成功的订单（按支付时间）
+func (r *DashboardRepository) paidOrders(from, to time.Time) *gorm.DB {
+	return r.db.Table("hpa_orders").
+		Where("status = ? AND pay_time >= ? AND pay_time < ?", "paid", from, to)
+}
+
+// GetDailyRevenue 每日销售额
+func (r *DashboardRepository) GetDailyRevenue(from, to time.Time) ([]DailyAmount, error) {
+	var list []DailyAmount
+	err := r.paidOrders(from, to).
+		Select(dayExpr("pay_time") + " AS day, COALESCE(SUM(amount), 0) AS amount, COUNT(*) AS orders").
+		Group("day").
+		Order("day ASC").
+		Scan(&list).Error
+	return list, err
+}
+
+// GetRevenueByPayMethod 按支付方式汇总销售额
+func (r *DashboardRepository) GetRevenueByPayMethod(from, to time.Time) ([]PayMethodAmount, error) {
+	var list []PayMethodAmount
+	err := r.paidOrders(from, to).
+		Select("pay_method, COALESCE(SUM(amount), 0) AS amount, COUNT(*) AS orders").
+		Group("pay_method").
+		Order("amount DESC").
+		Scan(&list).Error
+	return list, err
+}
+
+// GetOrdersByStatus 区间内创建的订单按状态计数
+func (r *DashboardRepository) GetOrdersByStatus(from, to time.Time) ([]StatusCount, error) {
+	var list []StatusCount
+	err := r.db.Table("hpa_orders").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Order("count DESC").
+		Scan(&list).Error
+	return list, err
+}
+
+// GetDailyNewUsers 每日新注册用户数
+func (r *DashboardRepository) GetDailyNewUsers(from, to time.Time) ([]DailyCount, error) {
+	var list []DailyCount
+	err := r.db.Table("users").
+		Where("deleted_at IS NULL AND created_at >= ? AND created_at < ?", from, to).
+		Select(dayExpr("created_at") + " AS day, COUNT(*) AS count").
+		Group("day").
+		Order("day ASC").
+		Scan(&list).Error
+	return list, err
+}
+
+// CountNewVIPs 区间内成为会员的用户数（续期不计）
+func (r *DashboardRepository) CountNewVIPs(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.Table("users").
+		Where("deleted_at IS NULL AND vip_since >= ? AND vip_since < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// GetTopCourseSales 区间内销量最高的课程
+// 多课程和套餐订单按明细计入各课程；没有明细的历史订单按订单上的课程计入
+func (r *DashboardRepository) GetTopCourseSales(from, to time.Time, limit int) ([]CourseSales, error) {
+	var list []CourseSales
+	err := r.db.Raw(`
+		SELECT s.course_id, COALESCE(c.title, '') AS title, COUNT(*) AS sales, COALESCE(SUM(s.amount), 0) AS revenue
+		FROM (
+			SELECT oi.course_id, oi.amount FROM hpa_order_items oi
+			JOIN hpa_orders o ON o.id = oi.order_id
+			WHERE o.status = 'paid' AND o.pay_time >= ? AND o.pay_time < ?
+			UNION ALL
+			SELECT o.course_id, o.amount FROM hpa_orders o
+			WHERE o.status = 'paid' AND o.pay_time >= ? AND o.pay_time < ? AND o.course_id > 0
+				AND NOT EXISTS (SELECT 1 FROM hpa_order_items oi WHERE oi.order_id = o.id)
+		) AS s
+		LEFT JOIN hpa_courses c ON c.id = s.course_id
+		GROUP BY s.course_id
+		ORDER BY sales DESC, revenue DESC
+		LIMIT ?`, from, to, from, to, limit).
+		Scan(&list).Error
+	return list, err
+}
+
+// CountInviteRedemptions 区间内通过邀请码兑换的订单数
+func (r *DashboardRepository) CountInviteRedemptions(from, to time.Time) (int64, error) {
+	var count int64
+	err := r.paidOrders(from, to).Where("pay_method = ?", "invite_code").Count(&count).Error
+	return count, err
+}
+
+// GetInviteCodeUsage 邀请码总体使用情况
+func (r *DashboardRepository) GetInviteCodeUsage(now time.Time) (*InviteCodeUsage, error) {
+	var usage InviteCodeUsage
+	err := r.db.Table("hpa_invite_codes").
+		Select(`COUNT(*) AS codes,
+			COALESCE(SUM(CASE WHEN is_active AND used_count < max_uses AND (expire_at IS NULL OR expire_at > ?) THEN 1 ELSE 0 END), 0) AS active_codes,
+			COALESCE(SUM(max_uses), 0) AS capacity,
+			COALESCE(SUM(used_count), 0) AS used`, now).
+		Scan(&usage).Error
+	return &usage, err
+}
+
+// GetDownloadVolume 区间内的下载申请
+func (r *DashboardRepository) GetDownloadVolume(from, to time.Time) (*DownloadVolume, error) {
+	var volume DownloadVolume
+	err := r.db.Table("hpa_downloads").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Select("COUNT(*) AS tokens, COALESCE(SUM(CASE WHEN used THEN 1 ELSE 0 END), 0) AS used, COUNT(DISTINCT user_id) AS users").
+		Scan(&volume).Error
+	return &volume, err
+}
+
+// GetDailyDownloads 每日下载申请数
+func (r *DashboardRepository) GetDailyDownloads(from, to time.Time) ([]DailyCount, error) {
+	var list []DailyCount
+	err := r.db.Table("hpa_downloads").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Select(dayExpr("created_at") + " AS day, COUNT(*) AS count").
+		Group("day").
+		Order("day ASC").
+		Scan(&list).Error
+	return list, err
+}
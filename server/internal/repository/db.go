@@ -3,6 +3,7 @@ package repository
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"car4race/internal/model"
 
@@ -20,7 +21,8 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 
 	// 连接数据库
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true, // 唯一约束冲突返回 gorm.ErrDuplicatedKey
 	})
 	if err != nil {
 		return nil, err
@@ -34,19 +36,118 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 		return nil, err
 	}
 
+	// 发布状态字段上线前的旧库需要在迁移后回填一次状态
+	statusBackfill := statusBackfillModels(db)
+
+	// 浏览记录新增合并窗口唯一索引前，为旧记录分配互不冲突的窗口编号
+	if err := prepareBrowseHistoryWindow(db); err != nil {
+		return nil, err
+	}
+
 	// 自动迁移 - 私域视频网站表
 	if err := db.AutoMigrate(
 		&model.Category{},
+		&model.Tag{},
 		&model.Note{},
+		&model.NoteRevision{},
+		&model.SearchDoc{},
 		&model.BrowseHistory{},
 		&model.Course{},
 		&model.CourseFile{},
+		&model.Chapter{},
+		&model.Lesson{},
+		&model.LearningProgress{},
+		&model.PendingUpload{},
+		&model.VideoAsset{},
+		&model.VideoKey{},
+		&model.VideoKeyAccess{},
 		&model.Order{},
+		&model.OrderItem{},
+		&model.Bundle{},
+		&model.Review{},
+		&model.Certificate{},
 		&model.InviteCode{},
 		&model.Download{},
+		&model.AnalyticsVisitor{},
+		&model.AnalyticsDaily{},
+		&model.Announcement{},
 	); err != nil {
 		return nil, err
 	}
 
+	// 兼容旧数据：浏览记录原先只有笔记（note_id），迁移为通用的目标类型和 ID
+	if err := migrateBrowseHistory(db); err != nil {
+		return nil, err
+	}
+
+	// 兼容旧数据：发布状态字段上线前未公开的内容视为草稿，仅在新增 status 列时执行
+	for _, m := range statusBackfill {
+		if err := db.Model(m).
+			Where("is_public = ? AND status = ?", false, model.StatusPublished).
+			Update("status", model.StatusDraft).Error; err != nil {
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
+
+// statusBackfillModels 返回表已存在但尚无 status 列的模型（笔记、课程）
+func statusBackfillModels(db *gorm.DB) []interface{} {
+	var models []interface{}
+	for _, m := range []interface{}{&model.Note{}, &model.Course{}} {
+		if db.Migrator().HasTable(m) && !db.Migrator().HasColumn(m, "status") {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// prepareBrowseHistoryWindow 旧浏览记录没有 view_window 列时补齐：
+// 按 -id 填充，保证唯一索引可以建立且不会与按时间计算的新窗口（正数）合并
+func prepareBrowseHistoryWindow(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&model.BrowseHistory{}) || migrator.HasColumn(&model.BrowseHistory{}, "view_window") {
+		return nil
+	}
+	if err := migrator.AddColumn(&model.BrowseHistory{}, "ViewWindow"); err != nil {
+		return err
+	}
+	if err := db.Exec("UPDATE hpa_browse_history SET view_window = -id").Error; err != nil {
+		return err
+	}
+	// 被唯一索引取代的旧索引
+	if migrator.HasIndex(&model.BrowseHistory{}, "idx_history_user_target") {
+		return migrator.DropIndex(&model.BrowseHistory{}, "idx_history_user_target")
+	}
+	return nil
+}
+
+// migrateBrowseHistory 把旧浏览记录的 note_id 迁移到 target_type/target_id 后删除旧列
+func migrateBrowseHistory(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&model.BrowseHistory{}, "note_id") {
+		return nil
+	}
+	if err := db.Exec("UPDATE hpa_browse_history SET target_type = ?, target_id = note_id, updated_at = COALESCE(updated_at, created_at) WHERE target_id = 0",
+		model.HistoryNote).Error; err != nil {
+		return err
+	}
+	if err := migrator.DropColumn(&model.BrowseHistory{}, "note_id"); err != nil {
+		return err
+	}
+	// SQLite 删除列会重建表，索引需要重新创建
+	return db.AutoMigrate(&model.BrowseHistory{})
+}
+
+// listedScope 公开列表只返回已发布或定时发布时间已到的内容（笔记、课程通用）
+func listedScope(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? OR (status = ? AND publish_at <= ?)",
+		model.StatusPublished, model.StatusScheduled, time.Now())
+}
+
+// reachableScope 可通过链接访问的内容：可列出或已归档，与 model.IsReachable 一致
+func reachableScope(db *gorm.DB) *gorm.DB {
+	return db.Where("status IN ? OR (status = ? AND publish_at <= ?)",
+		[]string{model.StatusPublished, model.StatusArchived}, model.StatusScheduled, time.Now())
+}
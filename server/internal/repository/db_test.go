@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"car4race/internal/model"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// reopenDB 关闭后重新初始化同一数据库，模拟服务重启
+func reopenDB(t *testing.T, db *gorm.DB, path string) *gorm.DB {
+	t.Helper()
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	db, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	db.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestStatusBackfillOnlyWhenColumnAdded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// 发布状态字段上线前的旧表
+	legacy, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := legacy.Exec("CREATE TABLE hpa_courses (id integer PRIMARY KEY AUTOINCREMENT, title text NOT NULL, slug text NOT NULL, price real NOT NULL, is_public numeric DEFAULT true)").Error; err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if err := legacy.Exec("INSERT INTO hpa_courses (title, slug, price, is_public) VALUES ('hidden', 'hidden', 1, false), ('shown', 'shown', 1, true)").Error; err != nil {
+		t.Fatalf("insert legacy rows: %v", err)
+	}
+
+	db := reopenDB(t, legacy, path)
+	repo := NewCourseRepository(db)
+	for slug, want := range map[string]string{"hidden": model.StatusDraft, "shown": model.StatusPublished} {
+		var course model.Course
+		if err := db.Where("slug = ?", slug).First(&course).Error; err != nil {
+			t.Fatalf("load %s: %v", slug, err)
+		}
+		if course.Status != want {
+			t.Errorf("%s status = %q, want %q", slug, course.Status, want)
+		}
+	}
+
+	// 之后的启动不再回填：状态与 is_public 不一致的记录保持原状
+	if err := db.Model(&model.Course{}).Where("slug = ?", "hidden").Update("status", model.StatusPublished).Error; err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	created := model.Course{Title: "new", Slug: "new", Price: 1, Status: model.StatusPublished}
+	if err := repo.CreateCourse(&created); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	db = reopenDB(t, db, path)
+	var count int64
+	db.Model(&model.Course{}).Where("status = ?", model.StatusDraft).Count(&count)
+	if count != 0 {
+		t.Errorf("%d courses downgraded to draft on restart, want 0", count)
+	}
+}
+
+func TestIsPublicFalsePersists(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewCourseRepository(db)
+
+	course := model.Course{Title: "draft", Slug: "draft", Price: 1, Status: model.StatusDraft, IsPublic: false}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	loaded, err := repo.GetCourseByID(course.ID)
+	if err != nil {
+		t.Fatalf("load course: %v", err)
+	}
+	if loaded.IsPublic {
+		t.Error("course created with is_public=false was stored as public")
+	}
+}
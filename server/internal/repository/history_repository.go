@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HistoryRepository 浏览记录
+type HistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewHistoryRepository(db *gorm.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+// Touch 记录一次浏览：同一合并窗口内浏览过同一目标时合并到该记录，否则新增一条
+// 依赖 (user_id, target_type, target_id, view_window) 唯一索引，并发浏览也只产生一条记录
+func (r *HistoryRepository) Touch(userID uint, targetType string, targetID uint, window int64) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "target_type"}, {Name: "target_id"}, {Name: "view_window"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"view_count": gorm.Expr("view_count + 1"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&model.BrowseHistory{
+		UserID:     userID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		ViewWindow: window,
+		ViewCount:  1,
+	}).Error
+}
+
+// List 获取用户的浏览记录（按最近浏览时间倒序），targetType 为空表示全部类型
+func (r *HistoryRepository) List(userID uint, targetType string, page, pageSize int) ([]model.BrowseHistory, int64, error) {
+	var history []model.BrowseHistory
+	var total int64
+
+	query := r.db.Model(&model.BrowseHistory{}).Where("user_id = ?", userID)
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	query.Count(&total)
+
+	err := query.
+		Order("updated_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&history).Error
+
+	return history, total, err
+}
+
+// GetNotes 批量获取浏览记录中仍可访问的笔记（含已归档），不含正文
+func (r *HistoryRepository) GetNotes(ids []uint) ([]model.Note, error) {
+	var notes []model.Note
+	err := r.db.Scopes(reachableScope).Omit("content").Preload("Category").Where("id IN ?", ids).Find(&notes).Error
+	return notes, err
+}
+
+// GetCourses 批量获取浏览记录中仍可访问的课程（含已归档），不含介绍
+func (r *HistoryRepository) GetCourses(ids []uint) ([]model.Course, error) {
+	var courses []model.Course
+	err := r.db.Scopes(reachableScope).Omit("description").Where("id IN ?", ids).Find(&courses).Error
+	return courses, err
+}
+
+// Delete 删除用户的一条浏览记录，返回是否存在
+func (r *HistoryRepository) Delete(userID, id uint) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.BrowseHistory{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Clear 清空用户的浏览记录，targetType 为空表示全部类型
+func (r *HistoryRepository) Clear(userID uint, targetType string) (int64, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	result := query.Delete(&model.BrowseHistory{})
+	return result.RowsAffected, result.Error
+}
+
+// Prune 删除最近浏览时间早于 before 的记录
+func (r *HistoryRepository) Prune(before time.Time) (int64, error) {
+	result := r.db.Where("updated_at < ?", before).Delete(&model.BrowseHistory{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"car4race/internal/model"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTouchMergesConcurrentViews(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHistoryRepository(db)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Touch(1, model.HistoryNote, 7, 100)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("touch: %v", err)
+		}
+	}
+
+	history, total, err := repo.List(1, "", 1, 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 1 || history[0].ViewCount != 8 {
+		t.Fatalf("total = %d, history = %+v; want one record with 8 views", total, history)
+	}
+
+	// 下一个窗口新增记录
+	if err := repo.Touch(1, model.HistoryNote, 7, 101); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if _, total, _ := repo.List(1, "", 1, 10); total != 2 {
+		t.Errorf("total = %d after next window, want 2", total)
+	}
+}
+
+func TestHistoryIncludesArchivedContent(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHistoryRepository(db)
+
+	notes := []model.Note{
+		{Title: "archived", Slug: "archived", Status: model.StatusArchived},
+		{Title: "draft", Slug: "draft", Status: model.StatusDraft},
+	}
+	if err := db.Create(&notes).Error; err != nil {
+		t.Fatalf("create notes: %v", err)
+	}
+	courses := []model.Course{
+		{Title: "archived", Slug: "archived", Status: model.StatusArchived},
+		{Title: "draft", Slug: "draft", Status: model.StatusDraft},
+	}
+	if err := db.Create(&courses).Error; err != nil {
+		t.Fatalf("create courses: %v", err)
+	}
+
+	gotNotes, err := repo.GetNotes([]uint{notes[0].ID, notes[1].ID})
+	if err != nil || len(gotNotes) != 1 || gotNotes[0].ID != notes[0].ID {
+		t.Errorf("notes = %+v, err = %v; want only the archived note", gotNotes, err)
+	}
+	gotCourses, err := repo.GetCourses([]uint{courses[0].ID, courses[1].ID})
+	if err != nil || len(gotCourses) != 1 || gotCourses[0].ID != courses[0].ID {
+		t.Errorf("courses = %+v, err = %v; want only the archived course", gotCourses, err)
+	}
+}
+
+func TestBrowseHistoryWindowMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// 合并窗口上线前的旧表：同一目标可能已有多条记录
+	legacy, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := legacy.Exec("CREATE TABLE hpa_browse_history (id integer PRIMARY KEY AUTOINCREMENT, user_id integer NOT NULL, target_type text NOT NULL DEFAULT 'note', target_id integer NOT NULL DEFAULT 0, view_count integer DEFAULT 1, created_at datetime, updated_at datetime)").Error; err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if err := legacy.Exec("CREATE INDEX idx_history_user_target ON hpa_browse_history (user_id, target_type, target_id)").Error; err != nil {
+		t.Fatalf("create legacy index: %v", err)
+	}
+	if err := legacy.Exec("INSERT INTO hpa_browse_history (user_id, target_type, target_id) VALUES (1, 'note', 7), (1, 'note', 7)").Error; err != nil {
+		t.Fatalf("insert legacy rows: %v", err)
+	}
+
+	db := reopenDB(t, legacy, path)
+	if db.Migrator().HasIndex(&model.BrowseHistory{}, "idx_history_user_target") {
+		t.Error("legacy index still exists")
+	}
+	var count int64
+	db.Model(&model.BrowseHistory{}).Where("view_window < 0").Count(&count)
+	if count != 2 {
+		t.Errorf("%d legacy rows with a negative window, want 2", count)
+	}
+}
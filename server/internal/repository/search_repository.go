@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// searchFTSTable FTS5 虚拟表，外部内容表为 hpa_search_docs，由触发器保持同步
+const searchFTSTable = "hpa_search_fts"
+
+var searchFTSSchema = []string{
+	`CREATE VIRTUAL TABLE hpa_search_fts USING fts5(
+		title_tokens, body_tokens,
+		content='hpa_search_docs', content_rowid='id', tokenize='unicode61'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS hpa_search_docs_ai AFTER INSERT ON hpa_search_docs BEGIN
+		INSERT INTO hpa_search_fts(rowid, title_tokens, body_tokens) VALUES (new.id, new.title_tokens, new.body_tokens);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS hpa_search_docs_ad AFTER DELETE ON hpa_search_docs BEGIN
+		INSERT INTO hpa_search_fts(hpa_search_fts, rowid, title_tokens, body_tokens) VALUES ('delete', old.id, old.title_tokens, old.body_tokens);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS hpa_search_docs_au AFTER UPDATE ON hpa_search_docs BEGIN
+		INSERT INTO hpa_search_fts(hpa_search_fts, rowid, title_tokens, body_tokens) VALUES ('delete', old.id, old.title_tokens, old.body_tokens);
+		INSERT INTO hpa_search_fts(rowid, title_tokens, body_tokens) VALUES (new.id, new.title_tokens, new.body_tokens);
+	END`,
+	// 表已有数据时（如先以 LIKE 模式运行过）补建索引
+	`INSERT INTO hpa_search_fts(hpa_search_fts) VALUES ('rebuild')`,
+}
+
+// SearchHit 搜索命中的文档及其来源内容
+type SearchHit struct {
+	DocType    string
+	RefID      uint
+	Title      string
+	Slug       string
+	CoverImage string
+	Body       string
+}
+
+type SearchRepository struct {
+	db  *gorm.DB
+	fts bool // FTS5 是否可用
+}
+
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// EnableFTS 创建 FTS5 索引表与同步触发器
+// go-sqlite3 需以 -tags sqlite_fts5 编译，否则返回错误，搜索降级为 LIKE 匹配
+func (r *SearchRepository) EnableFTS() error {
+	var count int64
+	if err := r.db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", searchFTSTable).
+		Scan(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range searchFTSSchema {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	r.fts = true
+	return nil
+}
+
+// FTSEnabled 是否使用 FTS5 索引
+func (r *SearchRepository) FTSEnabled() bool {
+	return r.fts
+}
+
+// SaveSearchDoc 写入或更新搜索文档
+func (r *SearchRepository) SaveSearchDoc(doc *model.SearchDoc) error {
+	var existing model.SearchDoc
+	err := r.db.Where("doc_type = ? AND ref_id = ?", doc.DocType, doc.RefID).First(&existing).Error
+	if err == nil {
+		doc.ID = existing.ID
+		return r.db.Save(doc).Error
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return r.db.Create(doc).Error
+}
+
+// DeleteSearchDoc 删除搜索文档
+func (r *SearchRepository) DeleteSearchDoc(docType string, refID uint) error {
+	return r.db.Where("doc_type = ? AND ref_id = ?", docType, refID).Delete(&model.SearchDoc{}).Error
+}
+
+// CountSearchDocs 统计搜索文档数
+func (r *SearchRepository) CountSearchDocs() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.SearchDoc{}).Count(&count).Error
+	return count, err
+}
+
+// ClearSearchDocs 清空搜索文档（重建索引前调用）
+func (r *SearchRepository) ClearSearchDocs() error {
+	return r.db.Where("1 = 1").Delete(&model.SearchDoc{}).Error
+}
+
+// Search 按分词结果检索，仅返回公开列表可见的笔记和课程
+// tokens 之间为 AND 关系；types 为空表示不限类型
+func (r *SearchRepository) Search(tokens []string, types []string, page, pageSize int) ([]SearchHit, int64, error) {
+	query := r.searchQuery(tokens, types)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []SearchHit
+	err := r.searchQuery(tokens, types).
+		Select(`d.doc_type, d.ref_id, d.body,
+			COALESCE(n.title, c.title) AS title,
+			COALESCE(n.slug, c.slug) AS slug,
+			COALESCE(n.cover_image, c.cover_image) AS cover_image`).
+		Order(r.searchOrder(tokens)).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&hits).Error
+
+	return hits, total, err
+}
+
+// searchQuery 构建检索条件（不含排序与分页）
+func (r *SearchRepository) searchQuery(tokens []string, types []string) *gorm.DB {
+	now := time.Now()
+	query := r.db.Table("hpa_search_docs AS d").
+		Joins(fmt.Sprintf("LEFT JOIN hpa_notes n ON d.doc_type = '%s' AND n.id = d.ref_id AND n.deleted_at IS NULL AND %s",
+			model.SearchTypeNote, listedCondition("n")), model.StatusPublished, model.StatusScheduled, now).
+		Joins(fmt.Sprintf("LEFT JOIN hpa_courses c ON d.doc_type = '%s' AND c.id = d.ref_id AND c.deleted_at IS NULL AND %s",
+			model.SearchTypeCourse, listedCondition("c")), model.StatusPublished, model.StatusScheduled, now).
+		Where("n.id IS NOT NULL OR c.id IS NOT NULL")
+
+	if r.fts {
+		query = query.Joins("JOIN hpa_search_fts ON hpa_search_fts.rowid = d.id").
+			Where("hpa_search_fts MATCH ?", ftsMatchExpr(tokens))
+	} else {
+		for _, token := range tokens {
+			like := "%" + token + "%"
+			query = query.Where("(d.title_tokens LIKE ? OR d.body_tokens LIKE ?)", like, like)
+		}
+	}
+
+	if len(types) > 0 {
+		query = query.Where("d.doc_type IN ?", types)
+	}
+	return query
+}
+
+// searchOrder FTS5 按 BM25 排序（标题权重更高），LIKE 模式下标题命中优先
+func (r *SearchRepository) searchOrder(tokens []string) interface{} {
+	if r.fts {
+		return "bm25(hpa_search_fts, 10.0, 1.0)"
+	}
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "(d.title_tokens LIKE ?) DESC, d.updated_at DESC",
+		Vars:               []interface{}{"%" + tokens[0] + "%"},
+		WithoutParentheses: true,
+	}}
+}
+
+// listedCondition 与 listedScope 相同的可见性条件，用于联表时指定表别名
+func listedCondition(alias string) string {
+	return fmt.Sprintf("(%[1]s.status = ? OR (%[1]s.status = ? AND %[1]s.publish_at <= ?))", alias)
+}
+
+// ftsMatchExpr 将分词结果拼为 FTS5 查询表达式
+// 每个词作为短语加引号避免语法注入；单个汉字无法命中二元词，按前缀匹配
+func ftsMatchExpr(tokens []string) string {
+	parts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		phrase := `"` + strings.ReplaceAll(token, `"`, `""`) + `"`
+		if len([]rune(token)) == 1 && token[0] >= 0x80 {
+			phrase += "*"
+		}
+		parts = append(parts, phrase)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// TagWithCount 标签及其关联的公开内容数
+type TagWithCount struct {
+	model.Tag
+	NoteCount   int64 `json:"note_count"`
+	CourseCount int64 `json:"course_count"`
+	Count       int64 `json:"count"`
+}
+
+type TagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// GetTagsWithCount 获取所有标签及公开笔记/课程数
+// onlyUsed 为 true 时只返回有公开内容的标签（标签云），按内容数降序
+func (r *TagRepository) GetTagsWithCount(onlyUsed bool) ([]TagWithCount, error) {
+	now := time.Now()
+	noteCount := fmt.Sprintf(`(SELECT COUNT(*) FROM hpa_note_tags nt
+		JOIN hpa_notes n ON n.id = nt.note_id AND n.deleted_at IS NULL AND %s
+		WHERE nt.tag_id = hpa_tags.id)`, listedCondition("n"))
+	courseCount := fmt.Sprintf(`(SELECT COUNT(*) FROM hpa_course_tags ct
+		JOIN hpa_courses c ON c.id = ct.course_id AND c.deleted_at IS NULL AND %s
+		WHERE ct.tag_id = hpa_tags.id)`, listedCondition("c"))
+
+	query := r.db.Table("(?) AS t", r.db.Model(&model.Tag{}).
+		Select("hpa_tags.*, "+noteCount+" AS note_count, "+courseCount+" AS course_count",
+			model.StatusPublished, model.StatusScheduled, now,
+			model.StatusPublished, model.StatusScheduled, now)).
+		Select("t.*, t.note_count + t.course_count AS count")
+
+	if onlyUsed {
+		query = query.Where("t.note_count + t.course_count > 0").Order("count DESC, t.sort ASC, t.id ASC")
+	} else {
+		query = query.Order("t.sort ASC, t.id ASC")
+	}
+
+	var tags []TagWithCount
+	err := query.Scan(&tags).Error
+	return tags, err
+}
+
+// GetTagBySlug 根据 slug 获取标签
+func (r *TagRepository) GetTagBySlug(slug string) (*model.Tag, error) {
+	var tag model.Tag
+	err := r.db.Where("slug = ?", slug).First(&tag).Error
+	return &tag, err
+}
+
+// GetTagByID 根据 ID 获取标签
+func (r *TagRepository) GetTagByID(id uint) (*model.Tag, error) {
+	var tag model.Tag
+	err := r.db.First(&tag, id).Error
+	return &tag, err
+}
+
+// GetTagByNameOrSlug 获取名称或 slug 匹配的标签
+func (r *TagRepository) GetTagByNameOrSlug(name, slug string) (*model.Tag, error) {
+	var tag model.Tag
+	err := r.db.Where("name = ? OR slug = ? OR slug = ?", name, slug, name).First(&tag).Error
+	return &tag, err
+}
+
+// GetTagsByIDs 根据 ID 批量获取标签
+func (r *TagRepository) GetTagsByIDs(ids []uint) ([]model.Tag, error) {
+	var tags []model.Tag
+	err := r.db.Where("id IN ?", ids).Find(&tags).Error
+	return tags, err
+}
+
+// TagExists 名称或 slug 是否已被其他标签使用
+func (r *TagRepository) TagExists(name, slug string, excludeID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Tag{}).
+		Where("(name = ? OR slug = ?) AND id <> ?", name, slug, excludeID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateTag 创建标签
+func (r *TagRepository) CreateTag(tag *model.Tag) error {
+	return r.db.Create(tag).Error
+}
+
+// UpdateTag 更新标签
+func (r *TagRepository) UpdateTag(tag *model.Tag) error {
+	return r.db.Save(tag).Error
+}
+
+// DeleteTag 删除标签及其与笔记、课程的关联
+func (r *TagRepository) DeleteTag(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM hpa_note_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM hpa_course_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Tag{}, id).Error
+	})
+}
+
+// ReplaceNoteTags 替换笔记的标签
+func (r *TagRepository) ReplaceNoteTags(noteID uint, tags []model.Tag) error {
+	return r.db.Model(&model.Note{ID: noteID}).Association("Tags").Replace(tags)
+}
+
+// ReplaceCourseTags 替换课程的标签
+func (r *TagRepository) ReplaceCourseTags(courseID uint, tags []model.Tag) error {
+	return r.db.Model(&model.Course{ID: courseID}).Association("Tags").Replace(tags)
+}
+
+// taggedScope 按标签 slug 过滤笔记或课程
+// joinTable 为关联表名，column 为关联表中指向内容的列
+func taggedScope(joinTable, column, slug string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+			Table(joinTable+" AS jt").
+			Select("jt."+column).
+			Joins("JOIN hpa_tags ON hpa_tags.id = jt.tag_id").
+			Where("hpa_tags.slug = ?", slug))
+	}
+}
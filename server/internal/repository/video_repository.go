@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"time"
+
+	"car4race/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type VideoRepository struct {
+	db *gorm.DB
+}
+
+func NewVideoRepository(db *gorm.DB) *VideoRepository {
+	return &VideoRepository{db: db}
+}
+
+// ========== VideoAsset ==========
+
+// GetAssetByID 根据 ID 获取视频资源
+func (r *VideoRepository) GetAssetByID(id uint) (*model.VideoAsset, error) {
+	var asset model.VideoAsset
+	err := r.db.First(&asset, id).Error
+	return &asset, err
+}
+
+// GetAssetByFileID 根据课程文件 ID 获取视频资源
+func (r *VideoRepository) GetAssetByFileID(fileID uint) (*model.VideoAsset, error) {
+	var asset model.VideoAsset
+	err := r.db.Where("file_id = ?", fileID).First(&asset).Error
+	return &asset, err
+}
+
+// GetCourseAssets 获取课程的所有视频资源
+func (r *VideoRepository) GetCourseAssets(courseID uint) ([]model.VideoAsset, error) {
+	var assets []model.VideoAsset
+	err := r.db.Where("course_id = ?", courseID).Order("id ASC").Find(&assets).Error
+	return assets, err
+}
+
+// GetUnfinishedAssets 获取未完成转码或密钥轮换的视频资源（服务重启后恢复任务）
+func (r *VideoRepository) GetUnfinishedAssets() ([]model.VideoAsset, error) {
+	var assets []model.VideoAsset
+	err := r.db.Where("status IN ? OR pending_key <> 0", []string{"pending", "processing"}).Order("id ASC").Find(&assets).Error
+	return assets, err
+}
+
+// GetAssetPrefixes 获取所有视频资源的 HLS 目录（存储一致性检查用）
+func (r *VideoRepository) GetAssetPrefixes() ([]string, error) {
+	var prefixes []string
+	err := r.db.Model(&model.VideoAsset{}).Where("prefix <> ''").Pluck("prefix", &prefixes).Error
+	return prefixes, err
+}
+
+// SaveAsset 创建或更新视频资源
+func (r *VideoRepository) SaveAsset(asset *model.VideoAsset) error {
+	return r.db.Save(asset).Error
+}
+
+// UpdateAssetStatus 更新转码状态
+func (r *VideoRepository) UpdateAssetStatus(id uint, status, errMsg string) error {
+	return r.db.Model(&model.VideoAsset{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "error": errMsg}).Error
+}
+
+// DeleteAsset 删除视频资源
+func (r *VideoRepository) DeleteAsset(id uint) error {
+	return r.db.Delete(&model.VideoAsset{}, id).Error
+}
+
+// ========== VideoKey ==========
+
+// GetLatestCourseKey 获取课程当前（最新）的加密密钥
+func (r *VideoRepository) GetLatestCourseKey(courseID uint) (*model.VideoKey, error) {
+	var key model.VideoKey
+	err := r.db.Where("course_id = ?", courseID).Order("id DESC").First(&key).Error
+	return &key, err
+}
+
+// GetKeyByID 根据 ID 获取加密密钥
+func (r *VideoRepository) GetKeyByID(id uint) (*model.VideoKey, error) {
+	var key model.VideoKey
+	err := r.db.First(&key, id).Error
+	return &key, err
+}
+
+// CreateKey 创建加密密钥
+func (r *VideoRepository) CreateKey(key *model.VideoKey) error {
+	return r.db.Create(key).Error
+}
+
+// ========== VideoKeyAccess ==========
+
+// CreateKeyAccess 记录密钥请求
+func (r *VideoRepository) CreateKeyAccess(access *model.VideoKeyAccess) error {
+	return r.db.Create(access).Error
+}
+
+// KeyAccessStat 用户密钥请求统计
+type KeyAccessStat struct {
+	UserID   uint  `json:"user_id"`
+	Requests int64 `json:"requests"`
+	Sessions int64 `json:"sessions"`
+	Assets   int64 `json:"assets"`
+	Courses  int64 `json:"courses"`
+	IPs      int64 `json:"ips"`
+	Blocked  int64 `json:"blocked"`
+}
+
+// GetKeyAccessStats 按用户统计指定时间之后的密钥请求
+func (r *VideoRepository) GetKeyAccessStats(since time.Time, limit int) ([]KeyAccessStat, error) {
+	var stats []KeyAccessStat
+	err := r.db.Model(&model.VideoKeyAccess{}).
+		Select(`user_id,
+			COUNT(*) AS requests,
+			COUNT(DISTINCT session) AS sessions,
+			COUNT(DISTINCT asset_id) AS assets,
+			COUNT(DISTINCT course_id) AS courses,
+			COUNT(DISTINCT ip) AS ips,
+			SUM(CASE WHEN blocked THEN 1 ELSE 0 END) AS blocked`).
+		Where("created_at >= ?", since).
+		Group("user_id").
+		Order("requests DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
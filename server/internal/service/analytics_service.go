@@ -0,0 +1,257 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+)
+
+const (
+	analyticsQueueLen      = 4096             // 事件缓冲队列长度，满时丢弃新事件，不阻塞请求
+	analyticsBatchSize     = 500              // 攒够该数量立即写库
+	analyticsFlushInterval = 10 * time.Second // 不足一批时的定时写库间隔
+	analyticsRefreshWindow = 30 * time.Minute // 同一访客在窗口内重复浏览同一目标只计一次 PV
+	analyticsVisitorDays   = 2                // 访客去重记录保留天数
+	analyticsMaxRangeDays  = 366
+	analyticsMaxPathLength = 200
+	analyticsDayLayout     = "2006-01-02"
+)
+
+// analyticsBotPattern 常见爬虫、监控和命令行工具的 User-Agent
+var analyticsBotPattern = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|archiver|facebookexternalhit|embedly|preview|` +
+	`headless|phantomjs|puppeteer|playwright|selenium|lighthouse|pingdom|uptime|monitor|` +
+	`curl|wget|python-requests|python-urllib|aiohttp|httpclient|okhttp|go-http-client|java/|libwww|scrapy|postman`)
+
+var analyticsEventTypes = map[string]bool{
+	model.EventPage:     true,
+	model.EventCourse:   true,
+	model.EventNote:     true,
+	model.EventDownload: true,
+}
+
+// AnalyticsEvent 一次浏览事件
+type AnalyticsEvent struct {
+	Type      string
+	Target    string
+	UserID    uint
+	IP        string
+	UserAgent string
+	Time      time.Time
+}
+
+// AnalyticsPoint 时间序列中的一天
+type AnalyticsPoint struct {
+	Day string `json:"day"`
+	PV  int64  `json:"pv"`
+	UV  int64  `json:"uv"`
+}
+
+// AnalyticsSeries PV/UV 时间序列
+type AnalyticsSeries struct {
+	Type    string           `json:"type"`
+	Target  string           `json:"target"` // 为空表示该类型的全站汇总
+	From    string           `json:"from"`
+	To      string           `json:"to"`
+	TotalPV int64            `json:"total_pv"`
+	Points  []AnalyticsPoint `json:"points"`
+}
+
+// AnalyticsService 访问统计：事件先进入内存队列，由后台协程过滤、去重后批量写入每日汇总
+type AnalyticsService struct {
+	repo    *repository.AnalyticsRepository
+	salt    string
+	events  chan AnalyticsEvent
+	dropped atomic.Int64
+}
+
+func NewAnalyticsService(repo *repository.AnalyticsRepository, cfg *config.Config) *AnalyticsService {
+	return &AnalyticsService{
+		repo:   repo,
+		salt:   cfg.JWTSecret,
+		events: make(chan AnalyticsEvent, analyticsQueueLen),
+	}
+}
+
+// Track 记录一次浏览（非阻塞），爬虫请求直接忽略
+func (s *AnalyticsService) Track(ev AnalyticsEvent) {
+	if !analyticsEventTypes[ev.Type] || ev.Target == "" || IsBotUserAgent(ev.UserAgent) {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	select {
+	case s.events <- ev:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// NormalizePagePath 规范化前端上报的页面路径：去掉查询参数和锚点，限制长度
+func NormalizePagePath(path string) (string, error) {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || len(path) > analyticsMaxPathLength {
+		return "", errcode.NewWithMessage(errcode.CodeInvalidParam, "无效的页面路径")
+	}
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return path, nil
+}
+
+// IsBotUserAgent 是否为爬虫或脚本请求；没有 User-Agent 的请求同样视为爬虫
+func IsBotUserAgent(ua string) bool {
+	return strings.TrimSpace(ua) == "" || analyticsBotPattern.MatchString(ua)
+}
+
+// StartCollector 启动后台协程：批量写入统计并定期清理过期的访客记录
+func (s *AnalyticsService) StartCollector() {
+	go func() {
+		ticker := time.NewTicker(analyticsFlushInterval)
+		defer ticker.Stop()
+
+		recent := make(map[string]time.Time) // 访客+目标 → 最近一次计入 PV 的时间
+		var batch []repository.AnalyticsHit
+		pruneDay := ""
+
+		flush := func(now time.Time) {
+			if len(batch) > 0 {
+				if err := s.repo.SaveHits(batch); err != nil {
+					log.Printf("save analytics failed (%d hits dropped): %v", len(batch), err)
+				}
+				batch = batch[:0]
+			}
+			if n := s.dropped.Swap(0); n > 0 {
+				log.Printf("analytics queue full, %d events dropped", n)
+			}
+			for key, t := range recent {
+				if now.Sub(t) >= analyticsRefreshWindow {
+					delete(recent, key)
+				}
+			}
+			if today := now.Format(analyticsDayLayout); today != pruneDay {
+				pruneDay = today
+				before := now.AddDate(0, 0, -analyticsVisitorDays).Format(analyticsDayLayout)
+				if _, err := s.repo.PruneVisitors(before); err != nil {
+					log.Printf("prune analytics visitors failed: %v", err)
+				}
+			}
+		}
+
+		for {
+			select {
+			case ev := <-s.events:
+				day := ev.Time.Format(analyticsDayLayout)
+				visitor := s.visitorID(ev, day)
+				key := visitor + "|" + ev.Type + "|" + ev.Target
+				if last, ok := recent[key]; ok && ev.Time.Sub(last) < analyticsRefreshWindow {
+					continue
+				}
+				recent[key] = ev.Time
+				batch = append(batch, repository.AnalyticsHit{Day: day, EventType: ev.Type, Target: ev.Target, Visitor: visitor})
+				if len(batch) >= analyticsBatchSize {
+					flush(time.Now())
+				}
+			case now := <-ticker.C:
+				flush(now)
+			}
+		}
+	}()
+}
+
+// visitorID 访客标识：登录用户用用户 ID；匿名访客用 IP+UA 的哈希，按天加盐，跨天无法关联
+func (s *AnalyticsService) visitorID(ev AnalyticsEvent, day string) string {
+	if ev.UserID > 0 {
+		return fmt.Sprintf("u:%d", ev.UserID)
+	}
+	sum := sha256.Sum256([]byte(s.salt + "|" + day + "|" + ev.IP + "|" + ev.UserAgent))
+	return "a:" + hex.EncodeToString(sum[:16])
+}
+
+// ========== 查询 ==========
+
+// GetSeries 获取每日 PV/UV，target 为空时返回该类型的全站汇总；没有数据的日期补 0
+// from、to 为空时默认最近 30 天
+func (s *AnalyticsService) GetSeries(eventType, target, from, to string) (*AnalyticsSeries, error) {
+	if !analyticsEventTypes[eventType] {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的统计类型")
+	}
+	start, end, err := parseDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	from, to = start.Format(analyticsDayLayout), end.Format(analyticsDayLayout)
+
+	rows, err := s.repo.GetDailySeries(eventType, target, from, to)
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]model.AnalyticsDaily, len(rows))
+	for _, row := range rows {
+		byDay[row.Day] = row
+	}
+
+	series := &AnalyticsSeries{Type: eventType, Target: target, From: from, To: to}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		day := d.Format(analyticsDayLayout)
+		row := byDay[day]
+		series.Points = append(series.Points, AnalyticsPoint{Day: day, PV: row.PV, UV: row.UV})
+		series.TotalPV += row.PV
+	}
+	return series, nil
+}
+
+// GetTop 获取日期区间内 PV 最高的页面、课程、笔记或下载文件
+func (s *AnalyticsService) GetTop(eventType, from, to string, limit int) ([]repository.AnalyticsTop, error) {
+	if !analyticsEventTypes[eventType] {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的统计类型")
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	start, end, err := parseDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetTopTargets(eventType, start.Format(analyticsDayLayout), end.Format(analyticsDayLayout), limit)
+}
+
+// parseDayRange 解析日期区间（YYYY-MM-DD，含首尾两天），默认最近 30 天
+func parseDayRange(from, to string) (time.Time, time.Time, error) {
+	today, _ := time.ParseInLocation(analyticsDayLayout, time.Now().Format(analyticsDayLayout), time.Local)
+	end, start := today, today.AddDate(0, 0, -29)
+
+	var err error
+	if to != "" {
+		if end, err = time.ParseInLocation(analyticsDayLayout, to, time.Local); err != nil {
+			return start, end, errcode.NewWithMessage(errcode.CodeInvalidParam, "日期格式应为 YYYY-MM-DD")
+		}
+		if from == "" {
+			start = end.AddDate(0, 0, -29)
+		}
+	}
+	if from != "" {
+		if start, err = time.ParseInLocation(analyticsDayLayout, from, time.Local); err != nil {
+			return start, end, errcode.NewWithMessage(errcode.CodeInvalidParam, "日期格式应为 YYYY-MM-DD")
+		}
+	}
+	if start.After(end) {
+		return start, end, errcode.NewWithMessage(errcode.CodeInvalidParam, "开始日期不能晚于结束日期")
+	}
+	if end.Sub(start) > analyticsMaxRangeDays*24*time.Hour {
+		return start, end, errcode.NewWithMessage(errcode.CodeInvalidParam, fmt.Sprintf("查询区间不能超过 %d 天", analyticsMaxRangeDays))
+	}
+	return start, end, nil
+}
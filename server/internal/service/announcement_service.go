@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	announcementMaxLength   = 500
+	announcementActiveLimit = 20 // 前台最多返回的公告数
+)
+
+var announcementTypes = map[string]bool{
+	model.AnnouncementSystem: true,
+	model.AnnouncementVIP:    true,
+	model.AnnouncementPromo:  true,
+}
+
+var announcementAudiences = map[string]bool{
+	model.AudienceAll:   true,
+	model.AudienceLogin: true,
+	model.AudienceVIP:   true,
+}
+
+// AnnouncementInput 创建/更新公告的参数
+type AnnouncementInput struct {
+	Content  string
+	Link     string
+	Type     string
+	Audience string
+	Pinned   bool
+	StartAt  *time.Time // 为空表示立即开始
+	EndAt    *time.Time // 为空表示长期有效
+}
+
+type AnnouncementService struct {
+	repo     *repository.AnnouncementRepository
+	userRepo *repository.UserRepository
+	vipDays  int
+}
+
+func NewAnnouncementService(repo *repository.AnnouncementRepository, userRepo *repository.UserRepository, cfg *config.Config) *AnnouncementService {
+	vipDays := cfg.VIPAnnounceDays
+	if vipDays <= 0 {
+		vipDays = 3
+	}
+	return &AnnouncementService{repo: repo, userRepo: userRepo, vipDays: vipDays}
+}
+
+// ========== 管理 ==========
+
+// List 获取公告列表（含未开始和已结束的）
+func (s *AnnouncementService) List(annType string, page, pageSize int) ([]model.Announcement, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.repo.List(annType, page, pageSize)
+}
+
+// Create 创建公告
+func (s *AnnouncementService) Create(input AnnouncementInput) (*model.Announcement, error) {
+	a := &model.Announcement{}
+	if err := applyAnnouncementInput(a, input); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Update 更新公告
+func (s *AnnouncementService) Update(id uint, input AnnouncementInput) (*model.Announcement, error) {
+	a, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.NewWithMessage(errcode.CodeNotFound, "公告不存在")
+		}
+		return nil, err
+	}
+	startAt := a.StartAt
+	if err := applyAnnouncementInput(a, input); err != nil {
+		return nil, err
+	}
+	// 未指定开始时间时保留原值，避免编辑后重新从当前时间开始
+	if input.StartAt == nil {
+		a.StartAt = startAt
+		if a.EndAt != nil && !a.EndAt.After(a.StartAt) {
+			return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "结束时间必须晚于开始时间")
+		}
+	}
+	if err := s.repo.Update(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete 删除公告
+func (s *AnnouncementService) Delete(id uint) error {
+	if _, err := s.repo.GetByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errcode.NewWithMessage(errcode.CodeNotFound, "公告不存在")
+		}
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// applyAnnouncementInput 校验参数并写入公告
+func applyAnnouncementInput(a *model.Announcement, input AnnouncementInput) error {
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "公告内容不能为空")
+	}
+	if utf8.RuneCountInString(content) > announcementMaxLength {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, fmt.Sprintf("公告内容不能超过 %d 字", announcementMaxLength))
+	}
+	if input.Type == "" {
+		input.Type = model.AnnouncementSystem
+	}
+	if !announcementTypes[input.Type] {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的公告类型")
+	}
+	if input.Audience == "" {
+		input.Audience = model.AudienceAll
+	}
+	if !announcementAudiences[input.Audience] {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的可见范围")
+	}
+
+	startAt := time.Now()
+	if input.StartAt != nil {
+		startAt = *input.StartAt
+	}
+	if input.EndAt != nil && !input.EndAt.After(startAt) {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "结束时间必须晚于开始时间")
+	}
+
+	a.Content = content
+	a.Link = strings.TrimSpace(input.Link)
+	a.Type = input.Type
+	a.Audience = input.Audience
+	a.Pinned = input.Pinned
+	a.StartAt = startAt
+	a.EndAt = input.EndAt
+	return nil
+}
+
+// ========== 前台 ==========
+
+// GetActive 获取当前用户可见的展示中公告，userID 为 0 表示未登录
+func (s *AnnouncementService) GetActive(userID uint) ([]model.Announcement, error) {
+	return s.GetActiveFor(s.Audience(userID))
+}
+
+// Audience 用户能看到的最大可见范围：未登录为 all，登录为 login，会员和管理员为 vip
+func (s *AnnouncementService) Audience(userID uint) string {
+	if userID == 0 {
+		return model.AudienceAll
+	}
+	if user, err := s.userRepo.FindByID(userID); err == nil && (user.Role == "admin" || user.IsVIP(time.Now())) {
+		return model.AudienceVIP
+	}
+	return model.AudienceLogin
+}
+
+// GetActiveFor 获取指定可见范围下展示中的公告（包含更宽范围的公告）
+func (s *AnnouncementService) GetActiveFor(audience string) ([]model.Announcement, error) {
+	audiences := []string{model.AudienceAll}
+	switch audience {
+	case model.AudienceLogin:
+		audiences = append(audiences, model.AudienceLogin)
+	case model.AudienceVIP:
+		audiences = append(audiences, model.AudienceLogin, model.AudienceVIP)
+	}
+	list, err := s.repo.GetActive(time.Now(), audiences, announcementActiveLimit)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []model.Announcement{}
+	}
+	return list, nil
+}
+
+// ========== 会员播报 ==========
+
+// AnnounceNewVIP 用户成为会员时在首页播报，昵称脱敏，展示 vipDays 天
+func (s *AnnouncementService) AnnounceNewVIP(user *model.User) error {
+	name := user.Nickname
+	if strings.TrimSpace(name) == "" {
+		name = user.Username
+	}
+	now := time.Now()
+	endAt := now.AddDate(0, 0, s.vipDays)
+	return s.repo.Create(&model.Announcement{
+		Content:  fmt.Sprintf("恭喜用户 %s 成为会员！", MaskName(name)),
+		Type:     model.AnnouncementVIP,
+		Audience: model.AudienceAll,
+		StartAt:  now,
+		EndAt:    &endAt,
+		UserID:   user.ID,
+	})
+}
+
+// MaskName 昵称脱敏：保留首尾字符，中间用 * 代替
+// 单字替换为 *；两个字保留首字；较长的（如手机号式的用户名）保留前 3 位和后 2 位
+func MaskName(name string) string {
+	runes := []rune(strings.TrimSpace(name))
+	switch n := len(runes); {
+	case n == 0:
+		return "***"
+	case n == 1:
+		return "*"
+	case n == 2:
+		return string(runes[0]) + "*"
+	case n <= 6:
+		return string(runes[0]) + strings.Repeat("*", n-2) + string(runes[n-1])
+	default:
+		return string(runes[:3]) + "****" + string(runes[n-2:])
+	}
+}
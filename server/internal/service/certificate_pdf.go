@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"car4race/internal/model"
+)
+
+// 证书使用 A4 横版，单位为 PDF 点（1/72 英寸）
+const (
+	certPageWidth  = 842.0
+	certPageHeight = 595.0
+)
+
+// certLine 证书上的一行居中文字
+type certLine struct {
+	text string
+	size float64
+	y    float64
+}
+
+// renderCertificatePDF 生成证书 PDF
+// 文字使用 PDF 阅读器内置的 STSong-Light 中文字体，无需嵌入字体文件
+func renderCertificatePDF(cert *model.Certificate, verifyURL string) []byte {
+	lines := []certLine{
+		{"结业证书", 40, 455},
+		{"CERTIFICATE OF COMPLETION", 14, 425},
+		{"兹证明", 16, 365},
+		{cert.Name, 30, 320},
+		{"已完成 Car4Race 课程", 16, 275},
+		{"《" + cert.CourseTitle + "》", 22, 235},
+		{"颁发日期：" + cert.IssuedAt.Format("2006-01-02"), 12, 150},
+		{"证书编号：" + cert.Serial, 12, 128},
+		{"验证地址：" + verifyURL, 10, 106},
+	}
+
+	var content bytes.Buffer
+	// 双线边框
+	content.WriteString("q 0.55 0.1 0.1 RG 3 w 28 28 786 539 re S 1 w 38 38 766 519 re S Q\n")
+	content.WriteString("0.1 0.1 0.1 rg\n")
+	for _, l := range lines {
+		x := (certPageWidth - certTextWidth(l.text, l.size)) / 2
+		fmt.Fprintf(&content, "BT /F1 %.0f Tf %.2f %.2f Td <%s> Tj ET\n", l.size, x, l.y, certHexString(l.text))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>", certPageWidth, certPageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 7 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+		fmt.Sprintf("<< /Title <%s> /Subject (%s) /Producer (Car4Race) >>", certHexString("结业证书 - "+cert.CourseTitle), cert.Serial),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return buf.Bytes()
+}
+
+// certHexString 将文字编码为 UCS-2 大端十六进制串（超出 BMP 的字符替换为 ?）
+func certHexString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// certTextWidth 估算文字宽度：ASCII 半角，其余全角
+func certTextWidth(s string, size float64) float64 {
+	var units float64
+	for _, r := range s {
+		if r < 0x80 {
+			units += 0.5
+		} else {
+			units++
+		}
+	}
+	return units * size
+}
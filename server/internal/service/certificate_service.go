@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+// 证书编号字符集（去除易混淆的 0/O/1/I）
+const certSerialAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// 证书文件链接有效期
+const certificateURLExpiry = time.Hour
+
+type CertificateService struct {
+	repo        *repository.CourseRepository
+	userRepo    *repository.UserRepository
+	fileService *FileService
+	siteURL     string
+	percent     int
+}
+
+func NewCertificateService(repo *repository.CourseRepository, userRepo *repository.UserRepository, fileService *FileService, cfg *config.Config) *CertificateService {
+	percent := cfg.CertificatePercent
+	if percent < 1 || percent > 100 {
+		percent = 100
+	}
+	return &CertificateService{
+		repo:        repo,
+		userRepo:    userRepo,
+		fileService: fileService,
+		siteURL:     cfg.SiteURL,
+		percent:     percent,
+	}
+}
+
+// CertificateView 证书公开信息（供合作方验证真伪）
+type CertificateView struct {
+	Serial      string     `json:"serial"`
+	Name        string     `json:"name"`
+	CourseID    uint       `json:"course_id"`
+	CourseTitle string     `json:"course_title"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	Valid       bool       `json:"valid"` // 未被吊销
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	VerifyURL   string     `json:"verify_url"`
+	FileURL     string     `json:"file_url,omitempty"` // PDF 临时下载链接
+}
+
+// IssueOnCompletion 本次上报使课程进度越过阈值时自动颁发证书，仅限已购买用户，已颁发则忽略
+// before 与 after 为上报前后的完成比例，未越过阈值时不做任何查询
+func (s *CertificateService) IssueOnCompletion(userID, courseID uint, before, after int) {
+	if before >= s.percent || after < s.percent {
+		return
+	}
+	if purchased, _ := s.repo.CheckUserPurchased(userID, courseID); !purchased {
+		return
+	}
+	if _, err := s.Issue(userID, courseID, "", "progress"); err != nil {
+		log.Printf("issue certificate failed: user=%d course=%d err=%v", userID, courseID, err)
+	}
+}
+
+// Issue 颁发证书，name 为空时使用用户昵称；同一用户同一课程只颁发一次
+func (s *CertificateService) Issue(userID, courseID uint, name, issuedBy string) (*model.Certificate, error) {
+	if cert, err := s.repo.GetUserCertificate(userID, courseID); err == nil {
+		return cert, nil
+	}
+
+	course, err := s.repo.GetCourseByID(courseID)
+	if err != nil {
+		return nil, errcode.New(errcode.CodeCourseNotFound)
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errcode.New(errcode.CodeUserNotFound)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = user.Nickname
+	}
+	if name == "" {
+		name = user.Username
+	}
+	if utf8.RuneCountInString(name) > 50 {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "姓名不能超过 50 字")
+	}
+
+	cert := &model.Certificate{
+		UserID:      userID,
+		CourseID:    courseID,
+		Name:        name,
+		CourseTitle: course.Title,
+		IssuedBy:    issuedBy,
+		IssuedAt:    time.Now(),
+	}
+	// 唯一约束冲突：同一用户课程已有证书视为已颁发，否则为编号冲突，重试
+	for i := 0; i < 3; i++ {
+		cert.Serial, err = generateCertSerial(cert.IssuedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.repo.CreateCertificate(cert); err == nil || !errors.Is(err, gorm.ErrDuplicatedKey) {
+			break
+		}
+		if existing, e := s.repo.GetUserCertificate(userID, courseID); e == nil {
+			return existing, nil // 并发颁发
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存证书失败: %v", err)
+	}
+
+	// 文件生成失败不影响证书有效性，下载时会重新生成
+	if err := s.ensureFile(cert); err != nil {
+		log.Printf("generate certificate file failed: serial=%s err=%v", cert.Serial, err)
+	}
+	return cert, nil
+}
+
+// Verify 根据编号验证证书
+func (s *CertificateService) Verify(serial string) (*CertificateView, error) {
+	cert, err := s.repo.GetCertificateBySerial(strings.ToUpper(strings.TrimSpace(serial)))
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "证书不存在")
+	}
+	return s.view(cert), nil
+}
+
+// GetUserCertificates 获取用户的全部证书
+func (s *CertificateService) GetUserCertificates(userID uint) ([]CertificateView, error) {
+	certs, err := s.repo.GetUserCertificates(userID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]CertificateView, 0, len(certs))
+	for i := range certs {
+		views = append(views, *s.view(&certs[i]))
+	}
+	return views, nil
+}
+
+// GetAllCertificates 获取证书列表（管理后台）
+func (s *CertificateService) GetAllCertificates(courseID uint, page, pageSize int) ([]model.Certificate, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+	return s.repo.GetAllCertificates(courseID, page, pageSize)
+}
+
+// Revoke 吊销证书，验证接口将显示为无效
+func (s *CertificateService) Revoke(id uint) error {
+	cert, err := s.repo.GetCertificateByID(id)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "证书不存在")
+	}
+	if cert.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeCertificate(cert.ID)
+}
+
+// view 组装公开信息，吊销的证书不返回文件
+func (s *CertificateService) view(cert *model.Certificate) *CertificateView {
+	v := &CertificateView{
+		Serial:      cert.Serial,
+		Name:        cert.Name,
+		CourseID:    cert.CourseID,
+		CourseTitle: cert.CourseTitle,
+		IssuedAt:    cert.IssuedAt,
+		Valid:       cert.RevokedAt == nil,
+		RevokedAt:   cert.RevokedAt,
+		VerifyURL:   s.verifyURL(cert.Serial),
+	}
+	if !v.Valid {
+		return v
+	}
+	if err := s.ensureFile(cert); err != nil {
+		log.Printf("generate certificate file failed: serial=%s err=%v", cert.Serial, err)
+		return v
+	}
+	if u, err := s.fileService.PresignObject(cert.FilePath, certificateURLExpiry); err == nil {
+		v.FileURL = u
+	}
+	return v
+}
+
+// ensureFile 生成证书 PDF 并上传到对象存储（已存在则跳过）
+func (s *CertificateService) ensureFile(cert *model.Certificate) error {
+	if cert.FilePath != "" {
+		return nil
+	}
+	objectName := fmt.Sprintf("certificates/%s.pdf", cert.Serial)
+	data := renderCertificatePDF(cert, s.verifyURL(cert.Serial))
+	if err := s.fileService.PutObject(objectName, data, "application/pdf"); err != nil {
+		return fmt.Errorf("上传证书失败: %v", err)
+	}
+	if err := s.repo.UpdateCertificateFile(cert.ID, objectName); err != nil {
+		return err
+	}
+	cert.FilePath = objectName
+	return nil
+}
+
+// verifyURL 证书验证地址
+func (s *CertificateService) verifyURL(serial string) string {
+	return s.siteURL + "/api/v1/hpa/certificates/" + serial
+}
+
+// generateCertSerial 生成证书编号：C4R-年份-8位随机字符
+func generateCertSerial(at time.Time) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成证书编号失败: %v", err)
+	}
+	for i, b := range buf {
+		buf[i] = certSerialAlphabet[int(b)%len(certSerialAlphabet)]
+	}
+	return fmt.Sprintf("C4R-%d-%s", at.Year(), buf), nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+// newTestCertificateService 创建证书服务，对象存储指向不可达地址，PDF 上传失败不影响颁发
+func newTestCertificateService(t *testing.T) (*CertificateService, *repository.CourseRepository, *repository.UserRepository) {
+	t.Helper()
+	db := newTestDB(t)
+	repo := repository.NewCourseRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	client, err := minio.New("127.0.0.1:1", &minio.Options{MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("minio client: %v", err)
+	}
+	files := &FileService{courseRepo: repo, videoRepo: repository.NewVideoRepository(db), minioClient: client, bucket: "test"}
+	return NewCertificateService(repo, userRepo, files, &config.Config{CertificatePercent: 80}), repo, userRepo
+}
+
+func TestIssueOnCompletionOnlyWhenCrossingThreshold(t *testing.T) {
+	svc, repo, userRepo := newTestCertificateService(t)
+	for _, name := range []string{"u1", "u2"} {
+		if err := userRepo.Create(&model.User{Username: name, Phone: name, Nickname: name}); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	course := model.Course{Title: "a", Slug: "a", Price: 10}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	payCourse(t, repo, 1, course.ID)
+	payCourse(t, repo, 2, course.ID)
+
+	cases := []struct {
+		userID        uint
+		before, after int
+		want          bool
+	}{
+		{1, 50, 70, false},  // 未达到阈值
+		{2, 80, 100, false}, // 此前已越过阈值
+		{1, 70, 80, true},
+	}
+	for _, tc := range cases {
+		svc.IssueOnCompletion(tc.userID, course.ID, tc.before, tc.after)
+		_, err := repo.GetUserCertificate(tc.userID, course.ID)
+		if got := err == nil; got != tc.want {
+			t.Errorf("user %d %d→%d: issued = %v, want %v", tc.userID, tc.before, tc.after, got, tc.want)
+		}
+	}
+}
+
+func TestIssueNotPurchased(t *testing.T) {
+	svc, repo, userRepo := newTestCertificateService(t)
+	if err := userRepo.Create(&model.User{Username: "u1", Phone: "u1"}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	course := model.Course{Title: "a", Slug: "a", Price: 10}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	svc.IssueOnCompletion(1, course.ID, 0, 100)
+	if _, err := repo.GetUserCertificate(1, course.ID); err == nil {
+		t.Error("certificate issued without purchase")
+	}
+}
+
+func TestCreateCertificateConflictIsDuplicatedKey(t *testing.T) {
+	svc, repo, userRepo := newTestCertificateService(t)
+	if err := userRepo.Create(&model.User{Username: "u1", Phone: "u1"}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	course := model.Course{Title: "a", Slug: "a", Price: 10}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	first, err := svc.Issue(1, course.ID, "", "admin")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	dup := &model.Certificate{Serial: "C4R-DUP", UserID: 1, CourseID: course.ID, Name: "u1", CourseTitle: "a", IssuedBy: "progress"}
+	if err := repo.CreateCertificate(dup); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("duplicate create err = %v, want gorm.ErrDuplicatedKey", err)
+	}
+
+	again, err := svc.Issue(1, course.ID, "", "progress")
+	if err != nil || again.Serial != first.Serial {
+		t.Errorf("second issue = %v, %v; want existing %s", again, err, first.Serial)
+	}
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"fmt"
+
+	"car4race/internal/model"
+	"car4race/pkg/errcode"
+)
+
+// CategoryNode 分类树节点
+type CategoryNode struct {
+	model.Category
+	NoteCount  int64           `json:"note_count"`  // 本分类的公开笔记数
+	TotalCount int64           `json:"total_count"` // 含所有子孙分类的公开笔记数
+	Children   []*CategoryNode `json:"children"`
+}
+
+// GetCategoryTree 获取完整分类树（任意层级）及公开笔记数
+func (s *ContentService) GetCategoryTree() ([]*CategoryNode, error) {
+	categories, err := s.repo.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.CountListedNotesByCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			Category:  category,
+			NoteCount: counts[category.ID],
+			Children:  []*CategoryNode{},
+		}
+	}
+
+	// categories 已按排序返回，依次挂到父节点下即保持同级顺序
+	// 父分类已删除的节点提升为根节点，避免整棵子树丢失
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok && !isAncestor(nodes, node.ID, parent.ID) {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	for _, root := range roots {
+		sumCategoryCounts(root)
+	}
+	return roots, nil
+}
+
+// GetCategoryBySlug 根据 slug 获取分类
+func (s *ContentService) GetCategoryBySlug(slug string) (*model.Category, error) {
+	return s.repo.GetCategoryBySlug(slug)
+}
+
+// CreateCategory 创建分类
+func (s *ContentService) CreateCategory(category *model.Category) error {
+	if category.ParentID != nil {
+		if _, err := s.repo.GetCategoryByID(*category.ParentID); err != nil {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "父分类不存在")
+		}
+	}
+	return s.repo.CreateCategory(category)
+}
+
+// UpdateCategory 更新分类名称、slug、父分类和排序，保留创建时间等其他字段
+func (s *ContentService) UpdateCategory(id uint, name, slug string, parentID *uint, sort int) (*model.Category, error) {
+	category, err := s.repo.GetCategoryByID(id)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "分类不存在")
+	}
+	if err := s.checkCategoryParent(id, parentID); err != nil {
+		return nil, err
+	}
+
+	category.Name = name
+	category.Slug = slug
+	category.ParentID = parentID
+	category.Sort = sort
+	if err := s.repo.UpdateCategory(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// MoveCategory 将分类移动到新的父分类下（parentID 为 nil 表示移到顶级）
+// sort 为 nil 时排到同级末尾
+func (s *ContentService) MoveCategory(id uint, parentID *uint, sort *int) (*model.Category, error) {
+	if _, err := s.repo.GetCategoryByID(id); err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "分类不存在")
+	}
+	if err := s.checkCategoryParent(id, parentID); err != nil {
+		return nil, err
+	}
+
+	position := 0
+	if sort != nil {
+		position = *sort
+	} else {
+		maxSort, err := s.repo.GetMaxCategorySort(parentID)
+		if err != nil {
+			return nil, err
+		}
+		position = maxSort + 1
+	}
+
+	if err := s.repo.MoveCategory(id, parentID, position); err != nil {
+		return nil, err
+	}
+	return s.repo.GetCategoryByID(id)
+}
+
+// ReorderCategories 拖拽排序，ids 为 parentID 下排序后的完整顺序，列表中的分类都归入该父分类
+func (s *ContentService) ReorderCategories(parentID *uint, ids []uint) error {
+	if len(ids) == 0 {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "排序列表不能为空")
+	}
+	for _, id := range ids {
+		if err := s.checkCategoryParent(id, parentID); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.ReorderCategories(parentID, uniqueIDs(ids)); err != nil {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "分类不存在")
+	}
+	return nil
+}
+
+// DeleteCategory 删除分类
+// 分类下仍有子分类或笔记时拒绝删除；指定 reassignTo 时先将笔记和子分类移到目标分类再删除
+func (s *ContentService) DeleteCategory(id uint, reassignTo *uint) error {
+	if _, err := s.repo.GetCategoryByID(id); err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "分类不存在")
+	}
+
+	if reassignTo == nil {
+		children, notes, err := s.repo.CountCategoryContents(id)
+		if err != nil {
+			return err
+		}
+		if children > 0 || notes > 0 {
+			return errcode.NewWithMessage(errcode.CodeCategoryNotEmpty,
+				fmt.Sprintf("分类下还有 %d 个子分类、%d 篇笔记，请先移走或指定接收分类", children, notes))
+		}
+		return s.repo.DeleteCategory(id, nil)
+	}
+
+	if _, err := s.repo.GetCategoryByID(*reassignTo); err != nil {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "接收分类不存在")
+	}
+	descendants, err := s.categoryWithDescendants(id)
+	if err != nil {
+		return err
+	}
+	for _, d := range descendants {
+		if d == *reassignTo {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "接收分类不能是该分类或其子分类")
+		}
+	}
+	return s.repo.DeleteCategory(id, reassignTo)
+}
+
+// categoryWithDescendants 返回分类自身及全部子孙分类的 ID
+func (s *ContentService) categoryWithDescendants(id uint) ([]uint, error) {
+	categories, err := s.repo.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	return descendantCategoryIDs(categories, id), nil
+}
+
+// descendantCategoryIDs 在分类列表中查找分类自身及全部子孙分类的 ID
+func descendantCategoryIDs(categories []model.Category, id uint) []uint {
+	children := make(map[uint][]uint)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// checkCategoryParent 校验父分类存在，且不是分类自身或其子孙（避免形成环）
+func (s *ContentService) checkCategoryParent(id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	if *parentID == id {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "不能移动到自身下")
+	}
+	if _, err := s.repo.GetCategoryByID(*parentID); err != nil {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "父分类不存在")
+	}
+
+	descendants, err := s.categoryWithDescendants(id)
+	if err != nil {
+		return err
+	}
+	for _, d := range descendants {
+		if d == *parentID {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "不能移动到自己的子分类下")
+		}
+	}
+	return nil
+}
+
+// isAncestor 判断 ancestorID 是否为 id 的祖先（沿现有父链向上查找）
+// 用于组装分类树时跳过数据中已存在的环
+func isAncestor(nodes map[uint]*CategoryNode, ancestorID, id uint) bool {
+	seen := make(map[uint]bool)
+	for current, ok := nodes[id]; ok && current.ParentID != nil; current, ok = nodes[*current.ParentID] {
+		if *current.ParentID == ancestorID {
+			return true
+		}
+		if seen[current.ID] {
+			return false
+		}
+		seen[current.ID] = true
+	}
+	return false
+}
+
+// sumCategoryCounts 累加子孙分类的笔记数
+func sumCategoryCounts(node *CategoryNode) int64 {
+	node.TotalCount = node.NoteCount
+	for _, child := range node.Children {
+		node.TotalCount += sumCategoryCounts(child)
+	}
+	return node.TotalCount
+}
@@ -1,71 +1,78 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
+	"car4race/internal/config"
 	"car4race/internal/model"
 	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
 )
 
 type ContentService struct {
-	repo *repository.ContentRepository
-}
-
-func NewContentService(repo *repository.ContentRepository) *ContentService {
-	return &ContentService{repo: repo}
-}
-
-// ========== Category ==========
-
-// GetAllCategories 获取所有分类
-func (s *ContentService) GetAllCategories() ([]model.Category, error) {
-	return s.repo.GetAllCategories()
-}
-
-// GetCategoryBySlug 根据 slug 获取分类
-func (s *ContentService) GetCategoryBySlug(slug string) (*model.Category, error) {
-	return s.repo.GetCategoryBySlug(slug)
-}
-
-// CreateCategory 创建分类
-func (s *ContentService) CreateCategory(category *model.Category) error {
-	return s.repo.CreateCategory(category)
-}
-
-// UpdateCategory 更新分类
-func (s *ContentService) UpdateCategory(category *model.Category) error {
-	return s.repo.UpdateCategory(category)
+	repo       *repository.ContentRepository
+	userRepo   *repository.UserRepository
+	courseRepo *repository.CourseRepository
+	preview    *previewSigner
 }
 
-// DeleteCategory 删除分类
-func (s *ContentService) DeleteCategory(id uint) error {
-	return s.repo.DeleteCategory(id)
+func NewContentService(repo *repository.ContentRepository, userRepo *repository.UserRepository, courseRepo *repository.CourseRepository, cfg *config.Config) *ContentService {
+	return &ContentService{
+		repo:       repo,
+		userRepo:   userRepo,
+		courseRepo: courseRepo,
+		preview:    newPreviewSigner(cfg.JWTSecret, cfg.SiteURL),
+	}
 }
 
 // ========== Note ==========
 
-// GetNotes 获取笔记列表
-func (s *ContentService) GetNotes(categoryID uint, page, pageSize int) ([]model.Note, int64, error) {
+// GetNotes 获取笔记列表（包含子孙分类下的笔记），tag 为标签 slug
+func (s *ContentService) GetNotes(categoryID uint, tag string, page, pageSize int) ([]model.Note, int64, error) {
 	if page < 1 {
 		page = 1
 	}
 	if pageSize < 1 || pageSize > 50 {
 		pageSize = 20
 	}
-	return s.repo.GetNotes(categoryID, page, pageSize)
+
+	var categoryIDs []uint
+	if categoryID > 0 {
+		ids, err := s.categoryWithDescendants(categoryID)
+		if err != nil {
+			return nil, 0, err
+		}
+		categoryIDs = ids
+	}
+	notes, total, err := s.repo.GetNotes(categoryIDs, tag, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	redactRestrictedNotes(notes)
+	return notes, total, nil
 }
 
 // GetNoteBySlug 根据 slug 获取笔记详情
-func (s *ContentService) GetNoteBySlug(slug string, userID uint) (*model.Note, error) {
+// 未发布的笔记仅可通过有效的预览签名访问，预览不计入浏览
+func (s *ContentService) GetNoteBySlug(slug string, userID uint, preview *PreviewToken) (*model.Note, error) {
 	note, err := s.repo.GetNoteBySlug(slug)
 	if err != nil {
 		return nil, err
 	}
 
-	// 增加浏览次数
-	_ = s.repo.IncrementNoteViewCount(note.ID)
+	if s.preview.Verify("notes", note.ID, preview) {
+		return note, nil
+	}
+	if !model.IsReachable(note.Status, note.PublishAt, time.Now()) {
+		return nil, gorm.ErrRecordNotFound
+	}
 
-	// 记录浏览历史
-	if userID > 0 {
-		_ = s.repo.AddBrowseHistory(userID, note.ID)
+	// 无权查看时只返回节选
+	if reason := s.noteLockedReason(note, userID); reason != "" {
+		s.lockNote(note, reason)
 	}
 
 	return note, nil
@@ -77,13 +84,93 @@ func (s *ContentService) GetNoteByID(id uint) (*model.Note, error) {
 }
 
 // CreateNote 创建笔记
-func (s *ContentService) CreateNote(note *model.Note) error {
-	return s.repo.CreateNote(note)
+func (s *ContentService) CreateNote(note *model.Note, authorID uint) error {
+	if err := applyNotePublishState(note); err != nil {
+		return err
+	}
+	if err := s.applyNoteAccess(note); err != nil {
+		return err
+	}
+	return s.repo.CreateNote(note, authorID)
+}
+
+// UpdateNote 更新笔记，expectedVersion 为编辑者读取时的版本号
+// 期间已被他人保存时返回版本冲突，避免静默覆盖
+func (s *ContentService) UpdateNote(note *model.Note, expectedVersion int, authorID uint) error {
+	if err := applyNotePublishState(note); err != nil {
+		return err
+	}
+	if err := s.applyNoteAccess(note); err != nil {
+		return err
+	}
+	return s.saveNoteVersion(note, expectedVersion, authorID, "")
 }
 
-// UpdateNote 更新笔记
-func (s *ContentService) UpdateNote(note *model.Note) error {
-	return s.repo.UpdateNote(note)
+// ImportNote 按 slug 创建或更新笔记（批量导入），返回是否为新建
+// note.Status 为空时新建为草稿、更新时保留原发布状态；内容没有变化时不生成新版本
+func (s *ContentService) ImportNote(note *model.Note, authorID uint) (bool, error) {
+	existing, err := s.repo.GetNoteBySlug(note.Slug)
+	if err == gorm.ErrRecordNotFound {
+		if note.Status == "" {
+			note.Status = model.StatusDraft
+		}
+		return true, s.CreateNote(note, authorID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	changed := existing.CategoryID != note.CategoryID || existing.Title != note.Title ||
+		existing.Summary != note.Summary || existing.Content != note.Content || existing.CoverImage != note.CoverImage
+	if note.Status != "" && note.Status != existing.Status {
+		existing.Status = note.Status
+		existing.PublishAt = nil
+		changed = true
+	}
+	if !changed {
+		*note = *existing
+		return false, nil
+	}
+
+	existing.CategoryID = note.CategoryID
+	existing.Title = note.Title
+	existing.Summary = note.Summary
+	existing.Content = note.Content
+	existing.CoverImage = note.CoverImage
+	if err := applyNotePublishState(existing); err != nil {
+		return false, err
+	}
+	if err := s.saveNoteVersion(existing, existing.Version, authorID, noteImportComment); err != nil {
+		return false, err
+	}
+	*note = *existing
+	return false, nil
+}
+
+// CreateNotePreview 生成笔记预览链接
+func (s *ContentService) CreateNotePreview(id uint) (*PreviewLink, error) {
+	note, err := s.repo.GetNoteByID(id)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "笔记不存在")
+	}
+	return s.preview.Link("notes", note.ID, note.Slug), nil
+}
+
+// StartPublishScheduler 启动后台任务，定期发布到期的定时笔记
+func (s *ContentService) StartPublishScheduler(interval time.Duration) {
+	startPublishTicker("notes", interval, s.repo.PublishDueNotes)
+}
+
+// applyNotePublishState 计算笔记发布状态并同步 IsPublic
+func applyNotePublishState(note *model.Note) error {
+	status, publishAt, err := resolvePublishState(note.Status, note.PublishAt, note.IsPublic)
+	if err != nil {
+		return err
+	}
+	note.Status = status
+	note.PublishAt = publishAt
+	note.IsPublic = status == model.StatusPublished
+	return nil
 }
 
 // DeleteNote 删除笔记
@@ -91,15 +178,70 @@ func (s *ContentService) DeleteNote(id uint) error {
 	return s.repo.DeleteNote(id)
 }
 
-// ========== BrowseHistory ==========
+// ========== NoteRevision ==========
 
-// GetUserBrowseHistory 获取用户浏览记录
-func (s *ContentService) GetUserBrowseHistory(userID uint, page, pageSize int) ([]model.BrowseHistory, int64, error) {
-	if page < 1 {
-		page = 1
+// GetNoteRevisions 获取笔记修订记录
+func (s *ContentService) GetNoteRevisions(noteID uint) ([]model.NoteRevision, error) {
+	if _, err := s.repo.GetNoteByID(noteID); err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "笔记不存在")
 	}
-	if pageSize < 1 || pageSize > 50 {
-		pageSize = 20
+	return s.repo.GetNoteRevisions(noteID)
+}
+
+// GetNoteRevision 获取笔记指定版本的完整内容
+func (s *ContentService) GetNoteRevision(noteID uint, version int) (*model.NoteRevision, error) {
+	revision, err := s.repo.GetNoteRevision(noteID, version)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "版本不存在")
+	}
+	return revision, nil
+}
+
+// DiffNoteRevisions 生成两个版本正文之间的统一格式 diff
+func (s *ContentService) DiffNoteRevisions(noteID uint, from, to int) (*NoteDiff, error) {
+	a, err := s.GetNoteRevision(noteID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.GetNoteRevision(noteID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, added, removed := unifiedDiff(
+		fmt.Sprintf("v%d", from), fmt.Sprintf("v%d", to), a.Content, b.Content)
+	return &NoteDiff{From: from, To: to, Added: added, Removed: removed, Diff: diff}, nil
+}
+
+// RestoreNoteRevision 将笔记恢复为指定版本的内容，恢复本身作为一个新版本保存
+func (s *ContentService) RestoreNoteRevision(noteID uint, version, expectedVersion int, authorID uint) (*model.Note, error) {
+	revision, err := s.GetNoteRevision(noteID, version)
+	if err != nil {
+		return nil, err
+	}
+	note, err := s.repo.GetNoteByID(noteID)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "笔记不存在")
+	}
+
+	note.Title = revision.Title
+	note.Summary = revision.Summary
+	note.Content = revision.Content
+	comment := fmt.Sprintf("恢复自版本 %d", version)
+	if err := s.saveNoteVersion(note, expectedVersion, authorID, comment); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// saveNoteVersion 按乐观锁保存笔记，冲突时返回 CodeVersionConflict
+func (s *ContentService) saveNoteVersion(note *model.Note, expectedVersion int, authorID uint, comment string) error {
+	updated, err := s.repo.UpdateNoteWithVersion(note, expectedVersion, authorID, comment)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errcode.New(errcode.CodeVersionConflict)
 	}
-	return s.repo.GetUserBrowseHistory(userID, page, pageSize)
+	return nil
 }
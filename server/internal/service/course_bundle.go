@@ -0,0 +1,256 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"car4race/internal/model"
+	"car4race/pkg/errcode"
+)
+
+// 购物车单次最多结算的课程数
+const maxCartCourses = 20
+
+// BundleQuote 套餐报价，已购课程按原价占比抵扣套餐价
+type BundleQuote struct {
+	Price          float64 `json:"price"`    // 套餐价
+	Discount       float64 `json:"discount"` // 已购课程抵扣
+	Amount         float64 `json:"amount"`   // 应付金额
+	OwnedCourseIDs []uint  `json:"owned_course_ids"`
+}
+
+// GetBundles 获取公开的套餐列表
+func (s *CourseService) GetBundles() ([]model.Bundle, error) {
+	return s.repo.GetBundles()
+}
+
+// GetAllBundles 获取所有套餐（管理后台）
+func (s *CourseService) GetAllBundles() ([]model.Bundle, error) {
+	return s.repo.GetAllBundles()
+}
+
+// GetBundleBySlug 根据 slug 获取套餐
+func (s *CourseService) GetBundleBySlug(slug string) (*model.Bundle, error) {
+	return s.repo.GetBundleBySlug(slug)
+}
+
+// GetBundleByID 根据 ID 获取套餐
+func (s *CourseService) GetBundleByID(id uint) (*model.Bundle, error) {
+	return s.repo.GetBundleByID(id)
+}
+
+// SaveBundle 创建或更新套餐
+func (s *CourseService) SaveBundle(bundle *model.Bundle, courseIDs []uint) error {
+	courseIDs = uniqueIDs(courseIDs)
+	if len(courseIDs) == 0 {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "套餐至少包含一门课程")
+	}
+	courses, err := s.repo.GetCoursesByIDs(courseIDs)
+	if err != nil {
+		return err
+	}
+	if len(courses) != len(courseIDs) {
+		return errcode.New(errcode.CodeCourseNotFound)
+	}
+	// 公开套餐会直接售出其中的课程，不能包含草稿或未到时间的定时课程
+	if bundle.IsPublic {
+		if course := unlistedCourse(courses); course != nil {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "公开套餐不能包含未上架课程："+course.Title)
+		}
+	}
+	return s.repo.SaveBundle(bundle, courses)
+}
+
+// DeleteBundle 删除套餐，已购用户仍保留套餐内课程
+func (s *CourseService) DeleteBundle(id uint) error {
+	return s.repo.DeleteBundle(id)
+}
+
+// QuoteBundle 计算用户购买套餐的应付金额
+func (s *CourseService) QuoteBundle(userID uint, bundle *model.Bundle) (*BundleQuote, error) {
+	quote, _, err := s.quoteBundle(userID, bundle)
+	return quote, err
+}
+
+// CreateBundleOrder 创建套餐订单，只为未购买的课程生成明细
+func (s *CourseService) CreateBundleOrder(userID, bundleID uint) (*model.Order, error) {
+	bundle, err := s.repo.GetBundleByID(bundleID)
+	if err != nil || !bundle.IsPublic {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "套餐不存在")
+	}
+
+	quote, items, err := s.quoteBundle(userID, bundle)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errcode.NewWithMessage(errcode.CodeAlreadyPurchased, "您已拥有套餐内全部课程")
+	}
+
+	order := &model.Order{
+		OrderNo:  generateOrderNo(),
+		UserID:   userID,
+		BundleID: bundle.ID,
+		Amount:   quote.Amount,
+		Discount: quote.Discount,
+		Status:   "pending",
+		Items:    items,
+	}
+	if err := s.repo.CreateOrder(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CreateCartOrder 创建多课程订单
+func (s *CourseService) CreateCartOrder(userID uint, courseIDs []uint) (*model.Order, error) {
+	courseIDs = uniqueIDs(courseIDs)
+	if len(courseIDs) == 0 || len(courseIDs) > maxCartCourses {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "购物车课程数量无效")
+	}
+
+	courses, err := s.repo.GetCoursesByIDs(courseIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(courses) != len(courseIDs) {
+		return nil, errcode.New(errcode.CodeCourseNotFound)
+	}
+
+	owned, err := s.ownedSet(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &model.Order{
+		OrderNo: generateOrderNo(),
+		UserID:  userID,
+		Status:  "pending",
+	}
+	now := time.Now()
+	for _, course := range courses {
+		if !model.IsListed(course.Status, course.PublishAt, now) {
+			return nil, errcode.NewWithMessage(errcode.CodeCourseNotFound, "课程未上架："+course.Title)
+		}
+		if owned[course.ID] {
+			return nil, errcode.NewWithMessage(errcode.CodeAlreadyPurchased, "您已购买课程："+course.Title)
+		}
+		order.Amount += course.Price
+		order.Items = append(order.Items, model.OrderItem{
+			CourseID: course.ID,
+			Price:    course.Price,
+			Amount:   course.Price,
+		})
+	}
+	order.Amount = roundPrice(order.Amount)
+	if len(courses) == 1 {
+		order.CourseID = courses[0].ID
+	}
+
+	if err := s.repo.CreateOrder(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// quoteBundle 计算报价及未购课程的订单明细
+// 套餐价按课程原价占比分摊，已购课程的分摊额作为抵扣
+func (s *CourseService) quoteBundle(userID uint, bundle *model.Bundle) (*BundleQuote, []model.OrderItem, error) {
+	if len(bundle.Courses) == 0 {
+		return nil, nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "套餐未包含课程")
+	}
+	// 课程在套餐保存后可能被改回草稿，此时套餐暂停销售
+	if course := unlistedCourse(bundle.Courses); course != nil {
+		return nil, nil, errcode.NewWithMessage(errcode.CodeCourseNotFound, "课程未上架："+course.Title)
+	}
+
+	owned := map[uint]bool{}
+	if userID > 0 {
+		var err error
+		if owned, err = s.ownedSet(userID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var listTotal float64
+	for _, c := range bundle.Courses {
+		listTotal += c.Price
+	}
+	share := func(c model.Course) float64 {
+		if listTotal <= 0 {
+			return bundle.Price / float64(len(bundle.Courses))
+		}
+		return bundle.Price * c.Price / listTotal
+	}
+
+	quote := &BundleQuote{Price: bundle.Price, OwnedCourseIDs: []uint{}}
+	var items []model.OrderItem
+	for _, c := range bundle.Courses {
+		if owned[c.ID] {
+			quote.Discount += share(c)
+			quote.OwnedCourseIDs = append(quote.OwnedCourseIDs, c.ID)
+			continue
+		}
+		items = append(items, model.OrderItem{
+			CourseID: c.ID,
+			BundleID: bundle.ID,
+			Price:    c.Price,
+			Amount:   roundPrice(share(c)),
+		})
+	}
+	quote.Discount = roundPrice(quote.Discount)
+	quote.Amount = roundPrice(bundle.Price - quote.Discount)
+
+	// 分摊取整的误差计入最后一项，保证明细合计等于应付金额
+	if len(items) > 0 {
+		var sum float64
+		for _, item := range items[:len(items)-1] {
+			sum += item.Amount
+		}
+		items[len(items)-1].Amount = roundPrice(quote.Amount - sum)
+	}
+	return quote, items, nil
+}
+
+// ownedSet 用户已购课程集合
+func (s *CourseService) ownedSet(userID uint) (map[uint]bool, error) {
+	ids, err := s.repo.GetUserOwnedCourseIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		owned[id] = true
+	}
+	return owned, nil
+}
+
+// uniqueIDs 去重并去除 0，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+// roundPrice 金额保留两位小数
+func roundPrice(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// unlistedCourse 返回第一门未上架的课程，全部已上架时返回 nil
+func unlistedCourse(courses []model.Course) *model.Course {
+	now := time.Now()
+	for i := range courses {
+		if !model.IsListed(courses[i].Status, courses[i].PublishAt, now) {
+			return &courses[i]
+		}
+	}
+	return nil
+}
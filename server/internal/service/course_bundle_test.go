@@ -0,0 +1,177 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+)
+
+func createBundle(t *testing.T, repo *repository.CourseRepository, price float64, prices ...float64) *model.Bundle {
+	t.Helper()
+	var courses []model.Course
+	for i, p := range prices {
+		course := model.Course{Title: "course", Slug: string(rune('a' + i)), Price: p, Status: model.StatusPublished}
+		if err := repo.CreateCourse(&course); err != nil {
+			t.Fatalf("create course: %v", err)
+		}
+		courses = append(courses, course)
+	}
+	bundle := &model.Bundle{Title: "season", Slug: "season", Price: price, IsPublic: true}
+	if err := repo.SaveBundle(bundle, courses); err != nil {
+		t.Fatalf("save bundle: %v", err)
+	}
+	loaded, err := repo.GetBundleByID(bundle.ID)
+	if err != nil {
+		t.Fatalf("load bundle: %v", err)
+	}
+	return loaded
+}
+
+func payCourse(t *testing.T, repo *repository.CourseRepository, userID, courseID uint) {
+	t.Helper()
+	now := time.Now()
+	order := &model.Order{OrderNo: generateOrderNo(), UserID: userID, CourseID: courseID, Status: "paid", PayTime: &now}
+	if err := repo.CreateOrder(order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+}
+
+func TestQuoteBundleProratesByListPrice(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	bundle := createBundle(t, repo, 300, 100, 200, 300)
+
+	quote, items, err := svc.quoteBundle(0, bundle)
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	if quote.Amount != 300 || quote.Discount != 0 || len(quote.OwnedCourseIDs) != 0 {
+		t.Errorf("quote = %+v, want amount 300 without discount", quote)
+	}
+	want := []float64{50, 100, 150}
+	if len(items) != len(want) {
+		t.Fatalf("items = %d, want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.Amount != want[i] || item.BundleID != bundle.ID {
+			t.Errorf("item %d = %+v, want amount %v", i, item, want[i])
+		}
+	}
+}
+
+func TestQuoteBundleCreditsOwnedCourses(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	bundle := createBundle(t, repo, 300, 100, 200, 300)
+	owned := bundle.Courses[1]
+	payCourse(t, repo, 1, owned.ID)
+
+	quote, items, err := svc.quoteBundle(1, bundle)
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	if quote.Price != 300 || quote.Discount != 100 || quote.Amount != 200 {
+		t.Errorf("quote = %+v, want discount 100 and amount 200", quote)
+	}
+	if len(quote.OwnedCourseIDs) != 1 || quote.OwnedCourseIDs[0] != owned.ID {
+		t.Errorf("owned = %v, want [%d]", quote.OwnedCourseIDs, owned.ID)
+	}
+	var sum float64
+	for _, item := range items {
+		if item.CourseID == owned.ID {
+			t.Errorf("owned course %d should not be billed", owned.ID)
+		}
+		sum += item.Amount
+	}
+	if len(items) != 2 || roundPrice(sum) != quote.Amount {
+		t.Errorf("items = %+v, want 2 items summing to %v", items, quote.Amount)
+	}
+
+	// 其他用户不受影响
+	if other, _, _ := svc.quoteBundle(2, bundle); other.Discount != 0 {
+		t.Errorf("other user discount = %v, want 0", other.Discount)
+	}
+}
+
+func TestQuoteBundleRoundingGoesToLastItem(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	bundle := createBundle(t, repo, 100, 10, 10, 10)
+
+	quote, items, err := svc.quoteBundle(0, bundle)
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	want := []float64{33.33, 33.33, 33.34}
+	for i, item := range items {
+		if item.Amount != want[i] {
+			t.Errorf("item %d amount = %v, want %v", i, item.Amount, want[i])
+		}
+	}
+	if quote.Amount != 100 {
+		t.Errorf("amount = %v, want 100", quote.Amount)
+	}
+}
+
+func TestQuoteBundleFreeCoursesSplitEvenly(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	bundle := createBundle(t, repo, 90, 0, 0, 0)
+	payCourse(t, repo, 1, bundle.Courses[0].ID)
+
+	quote, items, err := svc.quoteBundle(1, bundle)
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	if quote.Discount != 30 || quote.Amount != 60 || len(items) != 2 {
+		t.Errorf("quote = %+v, items = %d; want discount 30, amount 60, 2 items", quote, len(items))
+	}
+}
+
+func TestSaveBundleKeepsHiddenFlag(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	course := model.Course{Title: "a", Slug: "a", Price: 10}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	bundle := &model.Bundle{Title: "hidden", Slug: "hidden", Price: 10, IsPublic: false}
+	if err := svc.SaveBundle(bundle, []uint{course.ID}); err != nil {
+		t.Fatalf("save bundle: %v", err)
+	}
+	loaded, err := repo.GetBundleByID(bundle.ID)
+	if err != nil {
+		t.Fatalf("load bundle: %v", err)
+	}
+	if loaded.IsPublic {
+		t.Error("bundle created as hidden is public")
+	}
+}
+
+func TestBundleRejectsUnlistedCourses(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	bundle := createBundle(t, repo, 100, 100)
+	draft := model.Course{Title: "draft", Slug: "draft", Price: 50, Status: model.StatusDraft}
+	if err := repo.CreateCourse(&draft); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+
+	// 公开套餐不能加入草稿课程，隐藏套餐可以先配置
+	ids := []uint{bundle.Courses[0].ID, draft.ID}
+	if err := svc.SaveBundle(bundle, ids); !errcode.Is(err, errcode.CodeInvalidParam) {
+		t.Errorf("save public bundle with a draft course: err = %v, want invalid param", err)
+	}
+	hidden := &model.Bundle{Title: "hidden", Slug: "hidden", Price: 100}
+	if err := svc.SaveBundle(hidden, ids); err != nil {
+		t.Errorf("save hidden bundle with a draft course: %v", err)
+	}
+
+	// 套餐公开后课程被改回草稿：报价和下单都被拒绝
+	course := bundle.Courses[0]
+	course.Status = model.StatusDraft
+	if err := repo.UpdateCourse(&course); err != nil {
+		t.Fatalf("update course: %v", err)
+	}
+	if _, err := svc.CreateBundleOrder(1, bundle.ID); !errcode.Is(err, errcode.CodeCourseNotFound) {
+		t.Errorf("order a bundle with a draft course: err = %v, want course not found", err)
+	}
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"car4race/internal/model"
+	"car4race/pkg/errcode"
+)
+
+const (
+	progressBatchLimit   = 50          // 单次心跳最多上报的文件数
+	progressCompleteRate = 0.9         // 播放/阅读到该比例视为完成
+	progressReportLimit  = 12          // 每个用户每门课程在窗口内允许的心跳次数
+	progressReportWindow = time.Minute // 心跳限流窗口
+)
+
+// ProgressItem 单个文件的进度上报
+type ProgressItem struct {
+	FileID    uint    `json:"file_id" binding:"required"`
+	Position  float64 `json:"position"` // 视频为秒，文档为页
+	Total     float64 `json:"total"`    // 总时长或总页数，仅在服务端未知时记录，不参与完成判断
+	Timestamp int64   `json:"ts"`       // 客户端时间（毫秒），相同或更早的上报会被忽略
+}
+
+// ResumePoint 继续学习的位置
+type ResumePoint struct {
+	FileID   uint    `json:"file_id"`
+	LessonID uint    `json:"lesson_id"` // 未编排大纲时为 0
+	Position float64 `json:"position"`
+}
+
+// CourseProgress 课程学习进度汇总
+type CourseProgress struct {
+	CourseID  uint                     `json:"course_id"`
+	Purchased bool                     `json:"purchased"`
+	Total     int                      `json:"total"`     // 计入进度的文件数（服务端已知时长的文件）
+	Completed int                      `json:"completed"` // 已完成的文件数
+	Percent   int                      `json:"percent"`
+	Previous  int                      `json:"-"` // 本次上报前的完成比例，用于判断是否越过证书阈值
+	Resume    *ResumePoint             `json:"resume"`
+	Files     []model.LearningProgress `json:"files"`
+}
+
+// progressUnit 课程中可学习的文件
+type progressUnit struct {
+	FileID   uint
+	LessonID uint
+	Total    float64 // 服务端已知的总时长，0 表示未知，此时不计入完成进度
+	Free     bool
+}
+
+// GetCourseProgress 获取用户的课程进度及继续学习位置
+func (s *CourseService) GetCourseProgress(userID, courseID uint) (*CourseProgress, error) {
+	purchased, _ := s.CheckUserPurchased(userID, courseID)
+	units, err := s.progressUnits(courseID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildCourseProgress(userID, courseID, purchased, units)
+}
+
+// ReportProgress 批量上报学习进度（心跳）
+// 按客户端时间去重，重复提交同一批次结果不变；已完成的文件不会回退为未完成
+// 完成状态只按服务端已知的时长判断，客户端上报的总量不能低于服务端的值
+func (s *CourseService) ReportProgress(userID, courseID uint, items []ProgressItem) (*CourseProgress, error) {
+	if len(items) == 0 || len(items) > progressBatchLimit {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, fmt.Sprintf("单次最多上报 %d 条进度", progressBatchLimit))
+	}
+	if !s.progressLimiter.Allow(fmt.Sprintf("%d:%d", userID, courseID)) {
+		return nil, errcode.New(errcode.CodeRateLimitExceed)
+	}
+
+	purchased, _ := s.CheckUserPurchased(userID, courseID)
+	units, err := s.progressUnits(courseID)
+	if err != nil {
+		return nil, err
+	}
+	unitByFile := make(map[uint]progressUnit, len(units))
+	hasFree := false
+	for _, u := range units {
+		unitByFile[u.FileID] = u
+		hasFree = hasFree || u.Free
+	}
+	if !purchased && !hasFree {
+		return nil, errcode.New(errcode.CodeNotPurchased)
+	}
+
+	existing, err := s.repo.GetUserCourseProgress(userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	old := make(map[uint]model.LearningProgress, len(existing))
+	for _, p := range existing {
+		old[p.FileID] = p
+	}
+	_, _, previous := countCompleted(units, old)
+
+	now := time.Now()
+	batch := make(map[uint]model.LearningProgress)
+	for _, item := range items {
+		unit, ok := unitByFile[item.FileID]
+		if !ok || (!purchased && !unit.Free) {
+			continue
+		}
+		ts := item.Timestamp
+		if ts <= 0 {
+			ts = now.UnixMilli()
+		}
+		if prev, ok := batch[item.FileID]; ok && prev.ClientTime >= ts {
+			continue
+		}
+		prev, hasPrev := old[item.FileID]
+		if hasPrev && prev.ClientTime >= ts {
+			continue
+		}
+
+		p := model.LearningProgress{
+			UserID:     userID,
+			CourseID:   courseID,
+			FileID:     item.FileID,
+			Position:   item.Position,
+			Total:      item.Total,
+			ClientTime: ts,
+		}
+		if p.Position < 0 {
+			p.Position = 0
+		}
+		if unit.Total > 0 {
+			p.Total = unit.Total
+			if p.Position > p.Total {
+				p.Position = p.Total
+			}
+			p.Completed = p.Position >= p.Total*progressCompleteRate
+		} else if p.Total <= 0 && hasPrev {
+			p.Total = prev.Total
+		}
+
+		// 重看已完成的文件只更新位置
+		if hasPrev && prev.Completed {
+			p.Completed = true
+			p.CompletedAt = prev.CompletedAt
+		} else if p.Completed {
+			p.CompletedAt = &now
+		}
+		batch[item.FileID] = p
+	}
+
+	list := make([]model.LearningProgress, 0, len(batch))
+	for _, p := range batch {
+		list = append(list, p)
+	}
+	if err := s.repo.UpsertProgress(list); err != nil {
+		return nil, err
+	}
+
+	result, err := s.buildCourseProgress(userID, courseID, purchased, units)
+	if err != nil {
+		return nil, err
+	}
+	result.Previous = previous
+	return result, nil
+}
+
+// progressUnits 获取可学习的文件，按大纲顺序；未编排大纲时使用全部资源文件
+// 总时长优先取课时时长，其次取已转码视频的时长
+func (s *CourseService) progressUnits(courseID uint) ([]progressUnit, error) {
+	chapters, err := s.repo.GetCourseOutline(courseID)
+	if err != nil {
+		return nil, err
+	}
+	durations, err := s.repo.GetVideoDurations(courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []progressUnit
+	seen := make(map[uint]bool)
+	for _, chapter := range chapters {
+		for _, lesson := range chapter.Lessons {
+			if lesson.FileID == nil || seen[*lesson.FileID] {
+				continue
+			}
+			seen[*lesson.FileID] = true
+			total := float64(lesson.Duration)
+			if total <= 0 {
+				total = durations[*lesson.FileID]
+			}
+			units = append(units, progressUnit{
+				FileID:   *lesson.FileID,
+				LessonID: lesson.ID,
+				Total:    total,
+				Free:     lesson.IsFreePreview,
+			})
+		}
+	}
+	if len(units) > 0 {
+		return units, nil
+	}
+
+	files, err := s.repo.GetCourseFiles(courseID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.FileType == "resource" {
+			units = append(units, progressUnit{FileID: f.ID, Total: durations[f.ID]})
+		}
+	}
+	return units, nil
+}
+
+// buildCourseProgress 汇总完成比例并计算继续学习位置
+func (s *CourseService) buildCourseProgress(userID, courseID uint, purchased bool, units []progressUnit) (*CourseProgress, error) {
+	list, err := s.repo.GetUserCourseProgress(userID, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	byFile := make(map[uint]model.LearningProgress, len(list))
+	for _, p := range list {
+		byFile[p.FileID] = p
+	}
+
+	result := &CourseProgress{
+		CourseID:  courseID,
+		Purchased: purchased,
+		Files:     list,
+	}
+	result.Total, result.Completed, result.Percent = countCompleted(units, byFile)
+	last := -1
+	for i, u := range units {
+		if len(list) > 0 && u.FileID == list[0].FileID {
+			last = i
+		}
+	}
+
+	// 最近学习的文件未完成则从该位置继续，否则从其后第一个未完成的文件开始
+	if last >= 0 && !list[0].Completed && (purchased || units[last].Free) {
+		result.Resume = &ResumePoint{
+			FileID:   units[last].FileID,
+			LessonID: units[last].LessonID,
+			Position: list[0].Position,
+		}
+		return result, nil
+	}
+	for i := range units {
+		u := units[(last+1+i)%len(units)]
+		p := byFile[u.FileID]
+		if p.Completed || (!purchased && !u.Free) {
+			continue
+		}
+		result.Resume = &ResumePoint{FileID: u.FileID, LessonID: u.LessonID, Position: p.Position}
+		break
+	}
+	return result, nil
+}
+
+// countCompleted 统计计入进度的文件数、已完成数及完成比例，未知时长的文件不计入
+func countCompleted(units []progressUnit, byFile map[uint]model.LearningProgress) (total, completed, percent int) {
+	for _, u := range units {
+		if u.Total <= 0 {
+			continue
+		}
+		total++
+		if byFile[u.FileID].Completed {
+			completed++
+		}
+	}
+	if total > 0 {
+		percent = completed * 100 / total
+	}
+	return total, completed, percent
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+)
+
+// createOutline 创建包含两个课时的已购课程：第一课时长 100 秒，第二课未知时长
+func createOutline(t *testing.T, repo *repository.CourseRepository) (model.Course, []uint) {
+	t.Helper()
+	course := model.Course{Title: "a", Slug: "a", Price: 10, Status: model.StatusPublished}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	chapter := model.Chapter{CourseID: course.ID, Title: "c"}
+	if err := repo.CreateChapter(&chapter); err != nil {
+		t.Fatalf("create chapter: %v", err)
+	}
+	var fileIDs []uint
+	for i, duration := range []int{100, 0} {
+		file := model.CourseFile{CourseID: course.ID, FileType: "resource", FileName: "f", FilePath: string(rune('a' + i))}
+		if err := repo.CreateCourseFile(&file); err != nil {
+			t.Fatalf("create file: %v", err)
+		}
+		lesson := model.Lesson{CourseID: course.ID, ChapterID: chapter.ID, Title: "l", Duration: duration, FileID: &file.ID, Sort: i}
+		if err := repo.CreateLesson(&lesson); err != nil {
+			t.Fatalf("create lesson: %v", err)
+		}
+		fileIDs = append(fileIDs, file.ID)
+	}
+	payCourse(t, repo, 1, course.ID)
+	return course, fileIDs
+}
+
+func TestReportProgressUsesServerDuration(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	course, files := createOutline(t, repo)
+
+	// 客户端上报较小的总量不能提前完成
+	progress, err := svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[0], Position: 10, Total: 10, Timestamp: 1}})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if progress.Completed != 0 {
+		t.Errorf("completed = %d after client-lowered total, want 0", progress.Completed)
+	}
+	if got := progress.Files[0].Total; got != 100 {
+		t.Errorf("stored total = %v, want server duration 100", got)
+	}
+
+	progress, err = svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[0], Position: 90, Timestamp: 2}})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if progress.Completed != 1 || progress.Percent != 100 {
+		t.Errorf("completed = %d, percent = %d; want 1 and 100", progress.Completed, progress.Percent)
+	}
+
+	// 重看不会回退
+	progress, _ = svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[0], Position: 5, Timestamp: 3}})
+	if progress.Completed != 1 {
+		t.Errorf("completed = %d after rewatch, want 1", progress.Completed)
+	}
+}
+
+func TestReportProgressUnknownDurationNotCounted(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	course, files := createOutline(t, repo)
+
+	progress, err := svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[1], Position: 50, Total: 50, Timestamp: 1}})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if progress.Total != 1 || progress.Completed != 0 {
+		t.Errorf("total = %d, completed = %d; want 1 and 0", progress.Total, progress.Completed)
+	}
+	if progress.Resume == nil || progress.Resume.FileID != files[1] || progress.Resume.Position != 50 {
+		t.Errorf("resume = %+v, want file %d at 50", progress.Resume, files[1])
+	}
+}
+
+func TestReportProgressPreviousPercent(t *testing.T) {
+	svc, repo := newTestCourseService(t)
+	course, files := createOutline(t, repo)
+
+	progress, err := svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[0], Position: 95, Timestamp: 1}})
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if progress.Previous != 0 || progress.Percent != 100 {
+		t.Errorf("previous = %d, percent = %d; want 0 and 100", progress.Previous, progress.Percent)
+	}
+
+	progress, _ = svc.ReportProgress(1, course.ID, []ProgressItem{{FileID: files[0], Position: 96, Timestamp: 2}})
+	if progress.Previous != 100 {
+		t.Errorf("previous = %d on repeat heartbeat, want 100", progress.Previous)
+	}
+}
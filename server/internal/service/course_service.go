@@ -3,34 +3,46 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
+	"unicode/utf8"
 
+	"car4race/internal/config"
 	"car4race/internal/model"
 	"car4race/internal/repository"
 	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
 )
 
 type CourseService struct {
-	repo     *repository.CourseRepository
-	userRepo *repository.UserRepository
+	repo            *repository.CourseRepository
+	userRepo        *repository.UserRepository
+	preview         *previewSigner
+	progressLimiter *sessionLimiter
 }
 
-func NewCourseService(repo *repository.CourseRepository, userRepo *repository.UserRepository) *CourseService {
-	return &CourseService{repo: repo, userRepo: userRepo}
+func NewCourseService(repo *repository.CourseRepository, userRepo *repository.UserRepository, cfg *config.Config) *CourseService {
+	return &CourseService{
+		repo:            repo,
+		userRepo:        userRepo,
+		preview:         newPreviewSigner(cfg.JWTSecret, cfg.SiteURL),
+		progressLimiter: newSessionLimiter(progressReportLimit, progressReportWindow),
+	}
 }
 
 // ========== Course ==========
 
-// GetCourses 获取课程列表
-func (s *CourseService) GetCourses(page, pageSize int, sortBy string) ([]model.Course, int64, error) {
+// GetCourses 获取课程列表，tag 为标签 slug
+func (s *CourseService) GetCourses(page, pageSize int, sortBy, tag string) ([]model.Course, int64, error) {
 	if page < 1 {
 		page = 1
 	}
 	if pageSize < 1 || pageSize > 50 {
 		pageSize = 20
 	}
-	return s.repo.GetCourses(page, pageSize, sortBy)
+	return s.repo.GetCourses(page, pageSize, sortBy, tag)
 }
 
 // GetCourseBySlug 根据 slug 获取课程
@@ -48,53 +60,212 @@ func (s *CourseService) GetCourseByID(id uint) (*model.Course, error) {
 	return s.repo.GetCourseByID(id)
 }
 
+// CanViewCourse 检查课程详情是否可访问：已发布/归档，或持有有效的预览签名
+func (s *CourseService) CanViewCourse(course *model.Course, preview *PreviewToken) bool {
+	return model.IsReachable(course.Status, course.PublishAt, time.Now()) ||
+		s.preview.Verify("courses", course.ID, preview)
+}
+
 // CreateCourse 创建课程
 func (s *CourseService) CreateCourse(course *model.Course) error {
+	if err := applyCoursePublishState(course); err != nil {
+		return err
+	}
 	return s.repo.CreateCourse(course)
 }
 
 // UpdateCourse 更新课程
 func (s *CourseService) UpdateCourse(course *model.Course) error {
+	if err := applyCoursePublishState(course); err != nil {
+		return err
+	}
 	return s.repo.UpdateCourse(course)
 }
 
+// CreateCoursePreview 生成课程预览链接
+func (s *CourseService) CreateCoursePreview(id uint) (*PreviewLink, error) {
+	course, err := s.repo.GetCourseByID(id)
+	if err != nil {
+		return nil, errcode.New(errcode.CodeCourseNotFound)
+	}
+	return s.preview.Link("courses", course.ID, course.Slug), nil
+}
+
+// StartPublishScheduler 启动后台任务，定期发布到期的定时课程
+func (s *CourseService) StartPublishScheduler(interval time.Duration) {
+	startPublishTicker("courses", interval, s.repo.PublishDueCourses)
+}
+
+// applyCoursePublishState 计算课程发布状态并同步 IsPublic
+func applyCoursePublishState(course *model.Course) error {
+	status, publishAt, err := resolvePublishState(course.Status, course.PublishAt, course.IsPublic)
+	if err != nil {
+		return err
+	}
+	course.Status = status
+	course.PublishAt = publishAt
+	course.IsPublic = status == model.StatusPublished
+	return nil
+}
+
 // DeleteCourse 删除课程
 func (s *CourseService) DeleteCourse(id uint) error {
 	return s.repo.DeleteCourse(id)
 }
 
-// ========== Order ==========
+// ========== Chapter / Lesson ==========
 
-// CreateOrder 创建订单
-func (s *CourseService) CreateOrder(userID, courseID uint) (*model.Order, error) {
-	// 检查课程是否存在
-	course, err := s.repo.GetCourseByID(courseID)
+// GetCourseOutline 获取课程大纲；unlocked 为 false 时隐藏非试看课时的文件
+func (s *CourseService) GetCourseOutline(courseID uint, unlocked bool) ([]model.Chapter, error) {
+	chapters, err := s.repo.GetCourseOutline(courseID)
 	if err != nil {
-		return nil, errcode.New(errcode.CodeCourseNotFound)
+		return nil, err
+	}
+	if unlocked {
+		return chapters, nil
 	}
 
-	// 检查是否已购买
-	purchased, _ := s.repo.CheckUserPurchased(userID, courseID)
-	if purchased {
-		return nil, errcode.New(errcode.CodeAlreadyPurchased)
+	for i := range chapters {
+		for j := range chapters[i].Lessons {
+			lesson := &chapters[i].Lessons[j]
+			if !lesson.IsFreePreview {
+				lesson.Locked = true
+				lesson.FileID = nil
+				lesson.File = nil
+			}
+		}
 	}
+	return chapters, nil
+}
 
-	// 生成订单号
-	orderNo := generateOrderNo()
+// CanAccessFile 检查用户能否访问课程文件：已购买，或文件属于试看课时
+func (s *CourseService) CanAccessFile(userID, courseID, fileID uint) bool {
+	if purchased, _ := s.CheckUserPurchased(userID, courseID); purchased {
+		return true
+	}
+	free, _ := s.repo.IsFreePreviewFile(courseID, fileID)
+	return free
+}
 
-	order := &model.Order{
-		OrderNo:  orderNo,
-		UserID:   userID,
-		CourseID: courseID,
-		Amount:   course.Price,
-		Status:   "pending",
+// IsAdmin 用户是否为管理员，供不携带登录凭证的接口（如视频密钥）按签名中的用户判断
+func (s *CourseService) IsAdmin(userID uint) bool {
+	if userID == 0 {
+		return false
 	}
+	user, err := s.userRepo.FindByID(userID)
+	return err == nil && user.Role == "admin"
+}
 
-	if err := s.repo.CreateOrder(order); err != nil {
-		return nil, err
+// CreateChapter 创建章节，未指定排序时追加到末尾
+func (s *CourseService) CreateChapter(chapter *model.Chapter) error {
+	if _, err := s.repo.GetCourseByID(chapter.CourseID); err != nil {
+		return errcode.New(errcode.CodeCourseNotFound)
+	}
+	if chapter.Sort == 0 {
+		maxSort, _ := s.repo.GetMaxChapterSort(chapter.CourseID)
+		chapter.Sort = maxSort + 1
 	}
+	return s.repo.CreateChapter(chapter)
+}
 
-	return order, nil
+// GetChapterByID 根据 ID 获取章节
+func (s *CourseService) GetChapterByID(id uint) (*model.Chapter, error) {
+	return s.repo.GetChapterByID(id)
+}
+
+// UpdateChapter 更新章节
+func (s *CourseService) UpdateChapter(chapter *model.Chapter) error {
+	return s.repo.UpdateChapter(chapter)
+}
+
+// DeleteChapter 删除章节及其课时
+func (s *CourseService) DeleteChapter(id uint) error {
+	return s.repo.DeleteChapter(id)
+}
+
+// ReorderChapters 拖拽排序章节
+func (s *CourseService) ReorderChapters(courseID uint, ids []uint) error {
+	if err := s.repo.ReorderChapters(courseID, ids); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "章节不属于该课程")
+		}
+		return err
+	}
+	return nil
+}
+
+// CreateLesson 创建课时，未指定排序时追加到章节末尾
+func (s *CourseService) CreateLesson(lesson *model.Lesson) error {
+	chapter, err := s.repo.GetChapterByID(lesson.ChapterID)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "章节不存在")
+	}
+	lesson.CourseID = chapter.CourseID
+	if err := s.checkLessonFile(lesson); err != nil {
+		return err
+	}
+	if lesson.Sort == 0 {
+		maxSort, _ := s.repo.GetMaxLessonSort(chapter.ID)
+		lesson.Sort = maxSort + 1
+	}
+	return s.repo.CreateLesson(lesson)
+}
+
+// GetLessonByID 根据 ID 获取课时
+func (s *CourseService) GetLessonByID(id uint) (*model.Lesson, error) {
+	return s.repo.GetLessonByID(id)
+}
+
+// UpdateLesson 更新课时，可移动到同课程的其他章节
+func (s *CourseService) UpdateLesson(lesson *model.Lesson) error {
+	chapter, err := s.repo.GetChapterByID(lesson.ChapterID)
+	if err != nil || chapter.CourseID != lesson.CourseID {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "章节不属于该课程")
+	}
+	if err := s.checkLessonFile(lesson); err != nil {
+		return err
+	}
+	lesson.File = nil
+	return s.repo.UpdateLesson(lesson)
+}
+
+// DeleteLesson 删除课时
+func (s *CourseService) DeleteLesson(id uint) error {
+	return s.repo.DeleteLesson(id)
+}
+
+// ReorderLessons 拖拽排序课时，列表中的课时都归入该章节
+func (s *CourseService) ReorderLessons(chapterID uint, ids []uint) error {
+	chapter, err := s.repo.GetChapterByID(chapterID)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "章节不存在")
+	}
+	if err := s.repo.ReorderLessons(chapter.CourseID, chapter.ID, ids); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errcode.NewWithMessage(errcode.CodeInvalidParam, "课时不属于该课程")
+		}
+		return err
+	}
+	return nil
+}
+
+// checkLessonFile 课时关联的文件必须属于同一课程
+func (s *CourseService) checkLessonFile(lesson *model.Lesson) error {
+	if lesson.FileID == nil {
+		return nil
+	}
+	file, err := s.repo.GetCourseFileByID(*lesson.FileID)
+	if err != nil || file.CourseID != lesson.CourseID {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "文件不属于该课程")
+	}
+	return nil
+}
+
+// ========== Order ==========
+
+// CreateOrder 创建单课程订单
+func (s *CourseService) CreateOrder(userID, courseID uint) (*model.Order, error) {
+	return s.CreateCartOrder(userID, []uint{courseID})
 }
 
 // GetUserOrders 获取用户订单列表
@@ -132,6 +303,11 @@ func (s *CourseService) RedeemInviteCode(userID uint, code string) (*model.Order
 		return nil, errcode.NewWithMessage(errcode.CodeInvalidInvite, "邀请码已过期")
 	}
 
+	course, err := s.repo.GetCourseByID(inviteCode.CourseID)
+	if err != nil {
+		return nil, errcode.New(errcode.CodeCourseNotFound)
+	}
+
 	// 检查是否已购买
 	purchased, _ := s.repo.CheckUserPurchased(userID, inviteCode.CourseID)
 	if purchased {
@@ -150,6 +326,9 @@ func (s *CourseService) RedeemInviteCode(userID uint, code string) (*model.Order
 		PayMethod:  "invite_code",
 		PayTime:    &now,
 		InviteCode: code,
+		Items: []model.OrderItem{
+			{CourseID: course.ID, Price: course.Price, Amount: 0},
+		},
 	}
 
 	if err := s.repo.CreateOrder(order); err != nil {
@@ -165,6 +344,112 @@ func (s *CourseService) RedeemInviteCode(userID uint, code string) (*model.Order
 	return order, nil
 }
 
+// ========== Review ==========
+
+// maxReviewLength 评价内容最大字数
+const maxReviewLength = 1000
+
+// GetCourseReviews 获取课程的可见评价
+func (s *CourseService) GetCourseReviews(courseID uint, page, pageSize int) ([]model.Review, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+	return s.repo.GetCourseReviews(courseID, page, pageSize)
+}
+
+// GetUserReview 获取用户对课程的评价
+func (s *CourseService) GetUserReview(userID, courseID uint) (*model.Review, error) {
+	return s.repo.GetUserReview(userID, courseID)
+}
+
+// SubmitReview 发表或修改评价，仅已购买用户可评价
+// 每人每课程只保留一条，已被隐藏的评价修改后仍保持隐藏
+func (s *CourseService) SubmitReview(userID, courseID uint, rating int, content string) (*model.Review, error) {
+	if rating < 1 || rating > 5 {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "评分需为 1-5 星")
+	}
+	if utf8.RuneCountInString(content) > maxReviewLength {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, fmt.Sprintf("评价内容不能超过 %d 字", maxReviewLength))
+	}
+
+	purchased, _ := s.repo.CheckUserPurchased(userID, courseID)
+	if !purchased {
+		return nil, errcode.New(errcode.CodeNotPurchased)
+	}
+
+	review, err := s.repo.GetUserReview(userID, courseID)
+	if err != nil {
+		review = &model.Review{UserID: userID, CourseID: courseID}
+	}
+	review.Rating = rating
+	review.Content = content
+
+	if err := s.repo.SaveReview(review); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RefreshCourseRating(courseID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// DeleteUserReview 删除自己的评价
+func (s *CourseService) DeleteUserReview(userID, courseID uint) error {
+	review, err := s.repo.GetUserReview(userID, courseID)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "评价不存在")
+	}
+	return s.DeleteReview(review.ID)
+}
+
+// GetAllReviews 获取评价列表（管理后台）
+func (s *CourseService) GetAllReviews(courseID uint, hidden *bool, page, pageSize int) ([]model.Review, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+	return s.repo.GetAllReviews(courseID, hidden, page, pageSize)
+}
+
+// ModerateReview 隐藏或置顶评价，未传的字段保持不变
+func (s *CourseService) ModerateReview(id uint, hidden, pinned *bool) (*model.Review, error) {
+	review, err := s.repo.GetReviewByID(id)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "评价不存在")
+	}
+	if hidden != nil {
+		review.IsHidden = *hidden
+	}
+	if pinned != nil {
+		review.IsPinned = *pinned
+	}
+
+	if err := s.repo.UpdateReviewModeration(review.ID, review.IsHidden, review.IsPinned); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RefreshCourseRating(review.CourseID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// DeleteReview 删除评价并刷新课程评分
+func (s *CourseService) DeleteReview(id uint) error {
+	review, err := s.repo.GetReviewByID(id)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "评价不存在")
+	}
+	if err := s.repo.DeleteReview(review.ID); err != nil {
+		return err
+	}
+	return s.repo.RefreshCourseRating(review.CourseID)
+}
+
 // ========== Download ==========
 
 // CreateDownloadToken 创建下载令牌
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+)
+
+const (
+	dashboardCacheTTL  = 5 * time.Minute // 概览数据缓存时间，期间相同区间的请求直接返回缓存
+	dashboardCacheSize = 32              // 最多缓存的区间数
+	dashboardTopLimit  = 10
+)
+
+// DashboardDay 每日趋势
+type DashboardDay struct {
+	Day       string  `json:"day"`
+	Revenue   float64 `json:"revenue"`
+	Orders    int64   `json:"orders"` // 支付成功的订单数
+	NewUsers  int64   `json:"new_users"`
+	Downloads int64   `json:"downloads"`
+}
+
+// DashboardInvites 邀请码兑换情况
+type DashboardInvites struct {
+	repository.InviteCodeUsage
+	Redemptions    int64   `json:"redemptions"`     // 区间内兑换次数
+	RedemptionRate float64 `json:"redemption_rate"` // 已兑换次数 / 可兑换总次数（全部邀请码）
+}
+
+// Dashboard 管理后台概览
+type Dashboard struct {
+	From         string                       `json:"from"`
+	To           string                       `json:"to"`
+	Revenue      float64                      `json:"revenue"`
+	PaidOrders   int64                        `json:"paid_orders"`
+	NewUsers     int64                        `json:"new_users"`
+	NewVIPs      int64                        `json:"new_vips"`
+	Daily        []DashboardDay               `json:"daily"`
+	ByPayMethod  []repository.PayMethodAmount `json:"by_pay_method"`
+	OrdersStatus []repository.StatusCount     `json:"orders_by_status"`
+	TopSales     []repository.CourseSales     `json:"top_courses_by_sales"`
+	TopViews     []repository.AnalyticsTop    `json:"top_courses_by_views"`
+	Invites      DashboardInvites             `json:"invites"`
+	Downloads    repository.DownloadVolume    `json:"downloads"`
+	GeneratedAt  time.Time                    `json:"generated_at"`
+}
+
+type dashboardCacheEntry struct {
+	data    *Dashboard
+	expires time.Time
+}
+
+// DashboardService 管理后台概览统计
+type DashboardService struct {
+	repo          *repository.DashboardRepository
+	analyticsRepo *repository.AnalyticsRepository
+
+	mu    sync.Mutex
+	cache map[string]dashboardCacheEntry
+}
+
+func NewDashboardService(repo *repository.DashboardRepository, analyticsRepo *repository.AnalyticsRepository) *DashboardService {
+	return &DashboardService{
+		repo:          repo,
+		analyticsRepo: analyticsRepo,
+		cache:         make(map[string]dashboardCacheEntry),
+	}
+}
+
+// GetDashboard 获取区间内的概览数据（from、to 为 YYYY-MM-DD，含首尾两天，默认最近 30 天）
+// 结果缓存 5 分钟；refresh 为 true 时忽略缓存重新计算
+func (s *DashboardService) GetDashboard(from, to string, refresh bool) (*Dashboard, error) {
+	start, end, err := parseDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	key := start.Format(analyticsDayLayout) + "~" + end.Format(analyticsDayLayout)
+
+	now := time.Now()
+	if !refresh {
+		s.mu.Lock()
+		entry, ok := s.cache[key]
+		s.mu.Unlock()
+		if ok && now.Before(entry.expires) {
+			return entry.data, nil
+		}
+	}
+
+	data, err := s.compute(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	for k, e := range s.cache {
+		if !now.Before(e.expires) {
+			delete(s.cache, k)
+		}
+	}
+	if len(s.cache) < dashboardCacheSize {
+		s.cache[key] = dashboardCacheEntry{data: data, expires: now.Add(dashboardCacheTTL)}
+	}
+	s.mu.Unlock()
+	return data, nil
+}
+
+// compute 查询各项指标，end 为结束日期当天 0 点
+func (s *DashboardService) compute(start, end time.Time) (*Dashboard, error) {
+	until := end.AddDate(0, 0, 1)
+	d := &Dashboard{
+		From:        start.Format(analyticsDayLayout),
+		To:          end.Format(analyticsDayLayout),
+		GeneratedAt: time.Now(),
+	}
+
+	revenue, err := s.repo.GetDailyRevenue(start, until)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.repo.GetDailyNewUsers(start, until)
+	if err != nil {
+		return nil, err
+	}
+	downloads, err := s.repo.GetDailyDownloads(start, until)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]*DashboardDay)
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		d.Daily = append(d.Daily, DashboardDay{Day: t.Format(analyticsDayLayout)})
+	}
+	for i := range d.Daily {
+		days[d.Daily[i].Day] = &d.Daily[i]
+	}
+	for _, r := range revenue {
+		if day, ok := days[r.Day]; ok {
+			day.Revenue, day.Orders = r.Amount, r.Orders
+		}
+		d.Revenue += r.Amount
+		d.PaidOrders += r.Orders
+	}
+	for _, u := range users {
+		if day, ok := days[u.Day]; ok {
+			day.NewUsers = u.Count
+		}
+		d.NewUsers += u.Count
+	}
+	for _, dl := range downloads {
+		if day, ok := days[dl.Day]; ok {
+			day.Downloads = dl.Count
+		}
+	}
+
+	if d.NewVIPs, err = s.repo.CountNewVIPs(start, until); err != nil {
+		return nil, err
+	}
+	if d.ByPayMethod, err = s.repo.GetRevenueByPayMethod(start, until); err != nil {
+		return nil, err
+	}
+	if d.OrdersStatus, err = s.repo.GetOrdersByStatus(start, until); err != nil {
+		return nil, err
+	}
+	if d.TopSales, err = s.repo.GetTopCourseSales(start, until, dashboardTopLimit); err != nil {
+		return nil, err
+	}
+	if d.TopViews, err = s.analyticsRepo.GetTopTargets(model.EventCourse, d.From, d.To, dashboardTopLimit); err != nil {
+		return nil, err
+	}
+
+	if d.Invites.Redemptions, err = s.repo.CountInviteRedemptions(start, until); err != nil {
+		return nil, err
+	}
+	usage, err := s.repo.GetInviteCodeUsage(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	d.Invites.InviteCodeUsage = *usage
+	if usage.Capacity > 0 {
+		d.Invites.RedemptionRate = float64(usage.Used) / float64(usage.Capacity)
+	}
+
+	volume, err := s.repo.GetDownloadVolume(start, until)
+	if err != nil {
+		return nil, err
+	}
+	d.Downloads = *volume
+	return d, nil
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	feedEntryLimit     = 20
+	feedSummaryRunes   = 200
+	sitemapURLLimit    = 50000 // 单个 sitemap 文件的地址上限
+	atomNamespace      = "http://www.w3.org/2005/Atom"
+	sitemapNamespace   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	sitemapDateLayout  = "2006-01-02"
+	feedDefaultTitle   = "最新笔记"
+	robotsDisallowAll  = "User-agent: *\nDisallow: /\n"
+	robotsPrivatePaths = "Disallow: /api/\nDisallow: /admin\nDisallow: /profile\nDisallow: /orders\n"
+)
+
+// FeedDocument 生成的订阅源、站点地图或 robots.txt
+type FeedDocument struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time // 内容中最新的更新时间，为零表示未知
+}
+
+// FeedService 生成 Atom 订阅源、sitemap.xml 和 robots.txt
+type FeedService struct {
+	contentRepo *repository.ContentRepository
+	courseRepo  *repository.CourseRepository
+	siteURL     string
+	siteName    string
+	production  bool
+}
+
+func NewFeedService(contentRepo *repository.ContentRepository, courseRepo *repository.CourseRepository, cfg *config.Config) *FeedService {
+	// 订阅源和 sitemap 需要绝对地址，不能信任请求的 Host 头（会被写入缓存）；
+	// 生产环境启动时已要求配置 SITE_URL，开发环境回退到本机地址
+	siteURL := cfg.SiteURL
+	if siteURL == "" {
+		siteURL = "http://localhost:" + cfg.Port
+	}
+	return &FeedService{
+		contentRepo: contentRepo,
+		courseRepo:  courseRepo,
+		siteURL:     siteURL,
+		siteName:    cfg.SiteName,
+		production:  cfg.Env == "production",
+	}
+}
+
+// ========== Atom ==========
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string         `xml:"title"`
+	ID        string         `xml:"id"`
+	Link      atomLink       `xml:"link"`
+	Published string         `xml:"published"`
+	Updated   string         `xml:"updated"`
+	Summary   string         `xml:"summary,omitempty"`
+	Category  []atomCategory `xml:"category"`
+}
+
+// NotesFeed 生成笔记的 Atom 订阅源，categorySlug 为空表示全部分类，否则包含该分类及其子分类
+// 受限笔记只输出摘要
+func (s *FeedService) NotesFeed(categorySlug string) (*FeedDocument, error) {
+	base := s.siteURL
+	title := s.siteName + " · " + feedDefaultTitle
+	self := base + "/feed/notes.xml"
+	alternate := base + "/notes"
+
+	var categoryIDs []uint
+	if categorySlug != "" {
+		category, err := s.contentRepo.GetCategoryBySlug(categorySlug)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errcode.NewWithMessage(errcode.CodeNotFound, "分类不存在")
+			}
+			return nil, err
+		}
+		categories, err := s.contentRepo.GetAllCategories()
+		if err != nil {
+			return nil, err
+		}
+		categoryIDs = descendantCategoryIDs(categories, category.ID)
+		title = s.siteName + " · " + category.Name
+		self = base + "/feed/categories/" + url.PathEscape(category.Slug) + ".xml"
+		alternate = base + "/categories/" + url.PathEscape(category.Slug)
+	}
+
+	notes, err := s.contentRepo.GetFeedNotes(categoryIDs, feedEntryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := atomFeed{
+		XMLNS: atomNamespace,
+		Title: title,
+		ID:    self,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Type: "text/html", Href: alternate},
+		},
+		Author: atomAuthor{Name: s.siteName},
+	}
+
+	var updated time.Time
+	for _, note := range notes {
+		published := note.CreatedAt
+		if note.PublishAt != nil {
+			published = *note.PublishAt
+		}
+		if note.UpdatedAt.After(updated) {
+			updated = note.UpdatedAt
+		}
+
+		link := base + "/notes/" + url.PathEscape(note.Slug)
+		entry := atomEntry{
+			Title:     note.Title,
+			ID:        link,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+			Published: published.UTC().Format(time.RFC3339),
+			Updated:   note.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary:   feedSummary(&note),
+		}
+		if note.Category.ID != 0 {
+			entry.Category = []atomCategory{{Term: note.Category.Slug, Label: note.Category.Name}}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	lastModified := updated
+	if updated.IsZero() {
+		// 没有笔记时使用固定时间，保证 ETag 稳定
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	return newXMLDocument(feed, lastModified)
+}
+
+// feedSummary 订阅源摘要：优先使用笔记摘要，否则截取正文开头（受限笔记只取节选）
+func feedSummary(note *model.Note) string {
+	if summary := strings.TrimSpace(note.Summary); summary != "" {
+		return summary
+	}
+	text := []rune(markdownPlainText(notePreview(note.Content)))
+	if len(text) > feedSummaryRunes {
+		return string(text[:feedSummaryRunes]) + "…"
+	}
+	return string(text)
+}
+
+// ========== Sitemap ==========
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap 生成站点地图：首页、分类、已发布的笔记和课程，lastmod 取更新时间
+// 分类的 lastmod 取分类自身与其下笔记中较新的时间
+func (s *FeedService) Sitemap() (*FeedDocument, error) {
+	base := s.siteURL
+
+	categories, err := s.contentRepo.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.contentRepo.GetSitemapNotes(sitemapURLLimit)
+	if err != nil {
+		return nil, err
+	}
+	courses, err := s.courseRepo.GetSitemapCourses(sitemapURLLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest time.Time
+	touch := func(t time.Time) string {
+		if t.After(latest) {
+			latest = t
+		}
+		return t.Format(sitemapDateLayout)
+	}
+
+	categoryUpdated := make(map[uint]time.Time, len(categories))
+	for _, note := range notes {
+		if note.UpdatedAt.After(categoryUpdated[note.CategoryID]) {
+			categoryUpdated[note.CategoryID] = note.UpdatedAt
+		}
+	}
+
+	set := sitemapURLSet{XMLNS: sitemapNamespace}
+	set.URLs = append(set.URLs, sitemapURL{Loc: base + "/"})
+	for _, category := range categories {
+		updated := category.UpdatedAt
+		if t := categoryUpdated[category.ID]; t.After(updated) {
+			updated = t
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + "/categories/" + url.PathEscape(category.Slug), LastMod: touch(updated)})
+	}
+	for _, note := range notes {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + "/notes/" + url.PathEscape(note.Slug), LastMod: touch(note.UpdatedAt)})
+	}
+	for _, course := range courses {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + "/courses/" + url.PathEscape(course.Slug), LastMod: touch(course.UpdatedAt)})
+	}
+	if len(set.URLs) > sitemapURLLimit {
+		set.URLs = set.URLs[:sitemapURLLimit]
+	}
+
+	return newXMLDocument(set, latest)
+}
+
+// ========== robots.txt ==========
+
+// Robots 生成 robots.txt：非生产环境禁止抓取，生产环境屏蔽接口和个人页面并声明站点地图
+func (s *FeedService) Robots() *FeedDocument {
+	body := robotsDisallowAll
+	if s.production {
+		body = "User-agent: *\n" + robotsPrivatePaths + "\nSitemap: " + s.siteURL + "/sitemap.xml\n"
+	}
+	return newDocument([]byte(body), time.Time{})
+}
+
+// newXMLDocument 序列化为带 XML 声明的文档
+func newXMLDocument(v interface{}, lastModified time.Time) (*FeedDocument, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return newDocument(append([]byte(xml.Header), body...), lastModified), nil
+}
+
+// newDocument 按内容计算 ETag，内容不变则 ETag 不变（删除内容同样会改变 ETag）
+func newDocument(body []byte, lastModified time.Time) *FeedDocument {
+	sum := sha256.Sum256(body)
+	return &FeedDocument{
+		Body:         body,
+		ETag:         `"` + hex.EncodeToString(sum[:12]) + `"`,
+		LastModified: lastModified,
+	}
+}
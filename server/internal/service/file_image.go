@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"io"
+	"regexp"
+
+	"car4race/pkg/errcode"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	imageObjectPrefix = "images/"
+	imagePublicPath   = "/api/v1/hpa/images/" // 图片公开访问地址前缀，由 ImageHandler 代理读取
+	maxImageSize      = 10 << 20              // 单张图片大小上限
+)
+
+// imageTypes 允许的图片 MIME 及其存储扩展名
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageKeyPattern 图片对象路径（不含 images/ 前缀）：{哈希前两位}/{sha256}[_变体].{ext}
+var imageKeyPattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{64}(_[a-z0-9]+)?\.(png|jpg|gif|webp)$`)
+
+// OpenImage 读取图片对象，key 为公开地址中 images/ 之后的部分
+func (s *FileService) OpenImage(key string) (io.ReadCloser, string, int64, error) {
+	if !imageKeyPattern.MatchString(key) {
+		return nil, "", 0, errcode.New(errcode.CodeNotFound)
+	}
+
+	obj, err := s.minioClient.GetObject(context.Background(), s.bucket, imageObjectPrefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, "", 0, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, "", 0, errcode.New(errcode.CodeNotFound)
+		}
+		return nil, "", 0, err
+	}
+	return obj, info.ContentType, info.Size, nil
+}
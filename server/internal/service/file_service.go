@@ -1,9 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"path/filepath"
 	"strings"
@@ -12,18 +15,23 @@ import (
 	"car4race/internal/config"
 	"car4race/internal/model"
 	"car4race/internal/repository"
+	"car4race/pkg/errcode"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"gorm.io/gorm"
 )
 
 type FileService struct {
-	courseRepo  *repository.CourseRepository
-	minioClient *minio.Client
-	bucket      string
+	courseRepo      *repository.CourseRepository
+	videoRepo       *repository.VideoRepository
+	minioClient     *minio.Client
+	bucket          string
+	maxIntroSize    int64 // intro 文件大小上限（字节）
+	maxResourceSize int64 // resource 文件大小上限（字节）
 }
 
-func NewFileService(courseRepo *repository.CourseRepository, cfg *config.Config) (*FileService, error) {
+func NewFileService(courseRepo *repository.CourseRepository, videoRepo *repository.VideoRepository, cfg *config.Config) (*FileService, error) {
 	// 初始化 MinIO 客户端
 	minioClient, err := minio.New(cfg.MinIOEndpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(cfg.MinIOAccessKey, cfg.MinIOSecretKey, ""),
@@ -47,9 +55,12 @@ func NewFileService(courseRepo *repository.CourseRepository, cfg *config.Config)
 	}
 
 	return &FileService{
-		courseRepo:  courseRepo,
-		minioClient: minioClient,
-		bucket:      cfg.MinIOBucket,
+		courseRepo:      courseRepo,
+		videoRepo:       videoRepo,
+		minioClient:     minioClient,
+		bucket:          cfg.MinIOBucket,
+		maxIntroSize:    cfg.UploadMaxIntroMB << 20,
+		maxResourceSize: cfg.UploadMaxResourceMB << 20,
 	}, nil
 }
 
@@ -61,11 +72,10 @@ func (s *FileService) UploadCourseFile(courseID uint, fileType string, file *mul
 		return nil, fmt.Errorf("课程不存在")
 	}
 
-	// 生成对象路径: courses/{course_id}/{file_type}/{timestamp}_{filename}
-	ext := filepath.Ext(file.Filename)
-	baseName := strings.TrimSuffix(file.Filename, ext)
-	timestamp := time.Now().UnixNano() / 1e6
-	objectName := fmt.Sprintf("courses/%d/%s/%s_%d%s", courseID, fileType, baseName, timestamp, ext)
+	fileName := sanitizeFileName(file.Filename)
+	if err := s.checkUploadMeta(fileType, fileName, file.Size); err != nil {
+		return nil, err
+	}
 
 	// 打开上传的文件
 	src, err := file.Open()
@@ -74,13 +84,28 @@ func (s *FileService) UploadCourseFile(courseID uint, fileType string, file *mul
 	}
 	defer src.Close()
 
-	// 设置 Content-Type
-	contentType := "application/octet-stream"
-	if ext == ".md" {
-		contentType = "text/markdown; charset=utf-8"
-	} else if ext == ".zip" {
-		contentType = "application/zip"
+	// 计算 SHA-256 并检查重复上传
+	checksum, err := hashReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	if err := s.checkDuplicate(courseID, checksum); err != nil {
+		return nil, err
+	}
+
+	// 嗅探文件内容
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	contentType, err := sniffContentType(fileType, fileName, src)
+	if err != nil {
+		return nil, err
 	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	objectName := buildObjectName(courseID, fileType, fileName)
 
 	// 上传到 MinIO
 	ctx := context.Background()
@@ -91,34 +116,248 @@ func (s *FileService) UploadCourseFile(courseID uint, fileType string, file *mul
 		return nil, fmt.Errorf("上传文件失败: %v", err)
 	}
 
-	// 获取当前文件的最大排序号
-	maxSort, _ := s.courseRepo.GetMaxCourseFileSort(courseID)
+	return s.saveCourseFile(course, fileType, fileName, objectName, file.Size, checksum, 0)
+}
 
-	// 创建文件记录
+// saveCourseFile 为已写入 MinIO 的对象创建文件记录
+// pendingUploadID 非 0 时在同一事务中认领直传记录，认领失败说明已被其他请求确认，对象保留
+func (s *FileService) saveCourseFile(course *model.Course, fileType, fileName, objectName string, size int64, checksum string, pendingUploadID uint) (*model.CourseFile, error) {
 	courseFile := &model.CourseFile{
-		CourseID: courseID,
+		CourseID: course.ID,
 		FileType: fileType, // intro | resource
-		FileName: file.Filename,
+		FileName: fileName,
 		FilePath: objectName, // 存储 MinIO 对象路径
-		FileSize: file.Size,
-		Sort:     maxSort + 1,
+		FileSize: size,
+		Checksum: checksum,
 	}
 
-	if err := s.courseRepo.CreateCourseFile(courseFile); err != nil {
+	// Markdown 介绍文件同时更新课程的 IntroPath，介绍中的图片不替换介绍内容
+	setIntro := fileType == "intro" && isMarkdownFile(fileName)
+	err := s.courseRepo.SaveCourseFile(courseFile, setIntro, pendingUploadID)
+	if pendingUploadID > 0 && errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "上传已确认，请勿重复提交")
+	}
+	if err != nil {
 		// 如果数据库操作失败，删除已上传的文件
-		s.minioClient.RemoveObject(ctx, s.bucket, objectName, minio.RemoveObjectOptions{})
+		s.minioClient.RemoveObject(context.Background(), s.bucket, objectName, minio.RemoveObjectOptions{})
 		return nil, fmt.Errorf("保存记录失败: %v", err)
 	}
-
-	// 如果是 intro 类型，更新课程的 IntroPath
-	if fileType == "intro" {
+	if setIntro {
 		course.IntroPath = objectName
-		s.courseRepo.UpdateCourse(course)
 	}
 
 	return courseFile, nil
 }
 
+// ========== 预签名直传 ==========
+
+const (
+	presignedUploadExpiry = 30 * time.Minute // 直传链接有效期
+	uploadConfirmGrace    = time.Hour        // 链接过期后允许确认的宽限期
+	uploadCleanupBatch    = 100              // 每轮清理的最大记录数
+)
+
+// PresignedUpload 预签名直传信息
+type PresignedUpload struct {
+	Method    string            `json:"method"` // PUT | POST
+	URL       string            `json:"url"`
+	FormData  map[string]string `json:"form_data,omitempty"` // POST 表单字段
+	ObjectKey string            `json:"object_key"`
+	ExpireAt  time.Time         `json:"expire_at"`
+}
+
+// CreatePresignedUpload 生成预签名直传链接（method: put | post）
+func (s *FileService) CreatePresignedUpload(courseID uint, fileType, fileName string, size int64, checksum, method string) (*PresignedUpload, error) {
+	if _, err := s.courseRepo.GetCourseByID(courseID); err != nil {
+		return nil, fmt.Errorf("课程不存在")
+	}
+
+	fileName = sanitizeFileName(fileName)
+	if err := s.checkUploadMeta(fileType, fileName, size); err != nil {
+		return nil, err
+	}
+	if !isSHA256Hex(checksum) {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "文件校验值无效")
+	}
+	checksum = strings.ToLower(checksum)
+	if err := s.checkDuplicate(courseID, checksum); err != nil {
+		return nil, err
+	}
+
+	objectName := buildObjectName(courseID, fileType, fileName)
+	contentType := contentTypeByExt(filepath.Ext(fileName))
+	now := time.Now()
+
+	result := &PresignedUpload{
+		ObjectKey: objectName,
+		ExpireAt:  now.Add(presignedUploadExpiry),
+	}
+
+	ctx := context.Background()
+	switch strings.ToLower(method) {
+	case "", "put":
+		u, err := s.minioClient.PresignedPutObject(ctx, s.bucket, objectName, presignedUploadExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("生成上传链接失败: %v", err)
+		}
+		result.Method = "PUT"
+		result.URL = u.String()
+	case "post":
+		policy := minio.NewPostPolicy()
+		policy.SetBucket(s.bucket)
+		policy.SetKey(objectName)
+		policy.SetExpires(result.ExpireAt.UTC())
+		policy.SetContentType(contentType)
+		// 限定上传大小必须与声明一致
+		policy.SetContentLengthRange(size, size)
+		u, formData, err := s.minioClient.PresignedPostPolicy(ctx, policy)
+		if err != nil {
+			return nil, fmt.Errorf("生成上传链接失败: %v", err)
+		}
+		result.Method = "POST"
+		result.URL = u.String()
+		result.FormData = formData
+	default:
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "上传方式无效")
+	}
+
+	upload := &model.PendingUpload{
+		CourseID:     courseID,
+		FileType:     fileType,
+		FileName:     fileName,
+		ObjectKey:    objectName,
+		ExpectedSize: size,
+		Checksum:     checksum,
+		ContentType:  contentType,
+		ExpireAt:     result.ExpireAt.Add(uploadConfirmGrace),
+	}
+	if err := s.courseRepo.CreatePendingUpload(upload); err != nil {
+		return nil, fmt.Errorf("保存上传记录失败: %v", err)
+	}
+
+	return result, nil
+}
+
+// ConfirmUpload 确认直传完成：校验对象大小和 SHA-256 后创建文件记录
+func (s *FileService) ConfirmUpload(courseID uint, objectKey string) (*model.CourseFile, error) {
+	upload, err := s.courseRepo.GetPendingUploadByKey(objectKey)
+	if err != nil || upload.CourseID != courseID {
+		return nil, fmt.Errorf("上传记录不存在")
+	}
+	if upload.ExpireAt.Before(time.Now()) {
+		return nil, fmt.Errorf("上传已过期，请重新上传")
+	}
+
+	course, err := s.courseRepo.GetCourseByID(courseID)
+	if err != nil {
+		return nil, fmt.Errorf("课程不存在")
+	}
+
+	ctx := context.Background()
+	info, err := s.minioClient.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("文件尚未上传完成")
+	}
+
+	// 校验失败的对象直接丢弃，避免残留
+	reject := func(err error) error {
+		s.minioClient.RemoveObject(ctx, s.bucket, objectKey, minio.RemoveObjectOptions{})
+		_ = s.courseRepo.DeletePendingUpload(upload.ID)
+		return err
+	}
+
+	if info.Size != upload.ExpectedSize {
+		return nil, reject(errcode.NewWithMessage(errcode.CodeInvalidParam, "文件大小不一致"))
+	}
+
+	sum, err := s.objectSHA256(ctx, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	if sum != upload.Checksum {
+		return nil, reject(errcode.NewWithMessage(errcode.CodeInvalidParam, "文件校验失败"))
+	}
+
+	// 嗅探对象内容，防止直传时替换为其他格式
+	obj, err := s.minioClient.GetObject(ctx, s.bucket, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	_, sniffErr := sniffContentType(upload.FileType, upload.FileName, obj)
+	obj.Close()
+	if sniffErr != nil {
+		return nil, reject(sniffErr)
+	}
+
+	return s.saveCourseFile(course, upload.FileType, upload.FileName, objectKey, info.Size, upload.Checksum, upload.ID)
+}
+
+// CleanupExpiredUploads 清理过期未确认的直传对象及记录
+func (s *FileService) CleanupExpiredUploads() (int, error) {
+	uploads, err := s.courseRepo.GetExpiredPendingUploads(time.Now(), uploadCleanupBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	cleaned := 0
+	for _, upload := range uploads {
+		// 对象已登记为课程文件时只删除记录，保留对象
+		exists, err := s.courseRepo.CourseFileExistsByPath(upload.ObjectKey)
+		if err != nil {
+			log.Printf("check upload %s failed: %v", upload.ObjectKey, err)
+			continue
+		}
+		if exists {
+			if err := s.courseRepo.DeletePendingUpload(upload.ID); err != nil {
+				log.Printf("delete pending upload %d failed: %v", upload.ID, err)
+			}
+			continue
+		}
+		// 对象不存在时 RemoveObject 也返回成功
+		if err := s.minioClient.RemoveObject(ctx, s.bucket, upload.ObjectKey, minio.RemoveObjectOptions{}); err != nil {
+			log.Printf("cleanup upload %s failed: %v", upload.ObjectKey, err)
+			continue
+		}
+		if err := s.courseRepo.DeletePendingUpload(upload.ID); err != nil {
+			log.Printf("delete pending upload %d failed: %v", upload.ID, err)
+			continue
+		}
+		cleaned++
+	}
+
+	return cleaned, nil
+}
+
+// StartUploadCleaner 启动后台任务，定期清理未确认的直传对象
+func (s *FileService) StartUploadCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			cleaned, err := s.CleanupExpiredUploads()
+			if err != nil {
+				log.Printf("cleanup expired uploads failed: %v", err)
+				continue
+			}
+			if cleaned > 0 {
+				log.Printf("cleaned %d expired uploads", cleaned)
+			}
+		}
+	}()
+}
+
+// objectSHA256 计算对象内容的 SHA-256
+func (s *FileService) objectSHA256(ctx context.Context, objectName string) (string, error) {
+	obj, err := s.minioClient.GetObject(ctx, s.bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	return hashReader(obj)
+}
+
 // DeleteCourseFile 删除课程文件
 func (s *FileService) DeleteCourseFile(fileID uint) error {
 	// 获取文件记录
@@ -133,14 +372,73 @@ func (s *FileService) DeleteCourseFile(fileID uint) error {
 		return fmt.Errorf("删除文件失败: %v", err)
 	}
 
-	// 删除数据库记录
-	if err := s.courseRepo.DeleteCourseFile(fileID); err != nil {
+	return s.removeCourseFileRecord(file)
+}
+
+// removeCourseFileRecord 删除文件记录及其关联：课时、课程介绍、HLS 转码结果
+// 对象本身由调用方处理（存储一致性检查时对象可能已经不存在）
+func (s *FileService) removeCourseFileRecord(file *model.CourseFile) error {
+	if err := s.courseRepo.DeleteCourseFile(file.ID); err != nil {
 		return fmt.Errorf("删除记录失败: %v", err)
 	}
 
+	// 解除课时关联
+	_ = s.courseRepo.ClearLessonFile(file.ID)
+
+	// 删除的是当前介绍文件时，清空课程的 IntroPath
+	if file.FileType == "intro" {
+		if course, err := s.courseRepo.GetCourseByID(file.CourseID); err == nil && course.IntroPath == file.FilePath {
+			_ = s.courseRepo.UpdateCourseIntroPath(course.ID, "")
+		}
+	}
+
+	// 清理转码生成的 HLS 文件（含各版本目录），对象删除失败时留给存储一致性检查
+	if asset, err := s.videoRepo.GetAssetByFileID(file.ID); err == nil {
+		if err := s.RemovePrefix(hlsFileDir(file.CourseID, file.ID)); err != nil {
+			log.Printf("remove video asset for file %d failed: %v", file.ID, err)
+		}
+		_ = s.videoRepo.DeleteAsset(asset.ID)
+	}
+
 	return nil
 }
 
+// FileVerifyResult 文件完整性校验结果
+type FileVerifyResult struct {
+	FileID   uint   `json:"file_id"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	OK       bool   `json:"ok"`
+}
+
+// VerifyCourseFile 重新计算对象的 SHA-256 并与记录比对
+// 历史文件没有校验值时，以本次计算结果补齐
+func (s *FileService) VerifyCourseFile(fileID uint) (*FileVerifyResult, error) {
+	file, err := s.courseRepo.GetCourseFileByID(fileID)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "文件不存在")
+	}
+
+	actual, err := s.objectSHA256(context.Background(), file.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	if file.Checksum == "" {
+		if err := s.courseRepo.UpdateCourseFileChecksum(file.ID, actual); err != nil {
+			return nil, fmt.Errorf("保存校验值失败: %v", err)
+		}
+		file.Checksum = actual
+	}
+
+	return &FileVerifyResult{
+		FileID:   file.ID,
+		Expected: file.Checksum,
+		Actual:   actual,
+		OK:       file.Checksum == actual,
+	}, nil
+}
+
 // GetCourseFiles 获取课程文件列表
 func (s *FileService) GetCourseFiles(courseID uint) ([]model.CourseFile, error) {
 	return s.courseRepo.GetCourseFiles(courseID)
@@ -205,3 +503,105 @@ func (s *FileService) GetPresignedURL(fileID uint, expiry time.Duration) (string
 
 	return url.String(), nil
 }
+
+// ========== 通用对象操作 ==========
+
+// DownloadObject 将对象下载到本地文件
+func (s *FileService) DownloadObject(objectName, localPath string) error {
+	return s.minioClient.FGetObject(context.Background(), s.bucket, objectName, localPath, minio.GetObjectOptions{})
+}
+
+// UploadLocalFile 将本地文件上传为对象
+func (s *FileService) UploadLocalFile(objectName, localPath, contentType string) error {
+	_, err := s.minioClient.FPutObject(context.Background(), s.bucket, objectName, localPath, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// PutObject 将内存数据上传为对象（仅用于小文件，如证书）
+func (s *FileService) PutObject(objectName string, data []byte, contentType string) error {
+	_, err := s.minioClient.PutObject(context.Background(), s.bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+// ReadObject 读取对象全部内容（仅用于小文件，如播放列表）
+func (s *FileService) ReadObject(objectName string) ([]byte, error) {
+	obj, err := s.minioClient.GetObject(context.Background(), s.bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// PresignObject 生成对象的预签名下载 URL
+func (s *FileService) PresignObject(objectName string, expiry time.Duration) (string, error) {
+	u, err := s.minioClient.PresignedGetObject(context.Background(), s.bucket, objectName, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// RemovePrefix 删除目录前缀下的全部对象
+func (s *FileService) RemovePrefix(prefix string) error {
+	return s.RemovePrefixExcept(prefix, "")
+}
+
+// RemovePrefixExcept 删除前缀下的对象，保留 keep 子目录（keep 为空时全部删除）
+func (s *FileService) RemovePrefixExcept(prefix, keep string) error {
+	ctx := context.Background()
+	listed := s.minioClient.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	objectsCh := make(chan minio.ObjectInfo)
+	var listErr error
+	go func() {
+		defer close(objectsCh)
+		for obj := range listed {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			if keep != "" && strings.HasPrefix(obj.Key, keep) {
+				continue
+			}
+			objectsCh <- obj
+		}
+	}()
+	for rerr := range s.minioClient.RemoveObjects(ctx, s.bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		if rerr.Err != nil {
+			return fmt.Errorf("删除对象 %s 失败: %v", rerr.ObjectName, rerr.Err)
+		}
+	}
+	if listErr != nil {
+		return fmt.Errorf("列出对象失败: %v", listErr)
+	}
+	return nil
+}
+
+// ========== Helper ==========
+
+// buildObjectName 生成对象路径: courses/{course_id}/{file_type}/{filename}_{timestamp}{ext}
+func buildObjectName(courseID uint, fileType, fileName string) string {
+	ext := filepath.Ext(fileName)
+	baseName := strings.TrimSuffix(fileName, ext)
+	timestamp := time.Now().UnixNano() / 1e6
+	return fmt.Sprintf("courses/%d/%s/%s_%d%s", courseID, fileType, baseName, timestamp, ext)
+}
+
+// contentTypeByExt 根据扩展名推断 Content-Type
+func contentTypeByExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".zip":
+		return "application/zip"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"car4race/pkg/errcode"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// 各文件类型允许的扩展名及其对应的 MIME（以 "/" 结尾表示前缀匹配）
+// intro: Markdown 或图片；resource: 压缩包、PDF 或视频
+var uploadRules = map[string]map[string][]string{
+	"intro": {
+		".md":       {"text/plain"},
+		".markdown": {"text/plain"},
+		".png":      {"image/png"},
+		".jpg":      {"image/jpeg"},
+		".jpeg":     {"image/jpeg"},
+		".gif":      {"image/gif"},
+		".webp":     {"image/webp"},
+	},
+	"resource": {
+		".zip":  {"application/zip"},
+		".rar":  {"application/x-rar-compressed"},
+		".7z":   {"application/x-7z-compressed"},
+		".tar":  {"application/x-tar"},
+		".gz":   {"application/gzip"},
+		".tgz":  {"application/gzip"},
+		".pdf":  {"application/pdf"},
+		".mp4":  {"video/"},
+		".m4v":  {"video/"},
+		".mov":  {"video/"},
+		".mkv":  {"video/"},
+		".webm": {"video/"},
+	},
+}
+
+// checkUploadMeta 校验扩展名与大小（上传前即可判断）
+func (s *FileService) checkUploadMeta(fileType, fileName string, size int64) error {
+	rules, ok := uploadRules[fileType]
+	if !ok {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "文件类型无效")
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if _, ok := rules[ext]; !ok {
+		return errcode.NewWithMessage(errcode.CodeFileTypeNotAllowed, fmt.Sprintf("不支持的文件格式: %s", ext))
+	}
+
+	if size <= 0 {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "文件大小无效")
+	}
+	if limit := s.maxUploadSize(fileType); limit > 0 && size > limit {
+		return errcode.NewWithMessage(errcode.CodeFileTooLarge, fmt.Sprintf("文件过大，最大允许 %d MB", limit>>20))
+	}
+	return nil
+}
+
+// sniffContentType 嗅探文件内容，确认与扩展名一致并返回 Content-Type
+func sniffContentType(fileType, fileName string, r io.Reader) (string, error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	allowed := uploadRules[fileType][ext]
+
+	mtype, err := mimetype.DetectReader(r)
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	if !mimeAllowed(mtype, allowed) {
+		return "", errcode.NewWithMessage(errcode.CodeFileTypeNotAllowed, fmt.Sprintf("文件内容与格式不符: %s", mtype.String()))
+	}
+
+	if isMarkdownFile(fileName) {
+		return "text/markdown; charset=utf-8", nil
+	}
+	return mtype.String(), nil
+}
+
+// isMarkdownFile 是否为 Markdown 文件（内容已通过嗅探校验）
+func isMarkdownFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// mimeAllowed 检查嗅探结果（含父类型）是否在允许列表中
+func mimeAllowed(mtype *mimetype.MIME, allowed []string) bool {
+	for m := mtype; m != nil; m = m.Parent() {
+		for _, a := range allowed {
+			if strings.HasSuffix(a, "/") {
+				if strings.HasPrefix(m.String(), a) {
+					return true
+				}
+			} else if m.Is(a) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsVideoFile 根据扩展名判断是否为视频文件
+func IsVideoFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, mime := range uploadRules["resource"][ext] {
+		if strings.HasPrefix(mime, "video/") {
+			return true
+		}
+	}
+	return false
+}
+
+// maxUploadSize 获取文件类型对应的大小上限（字节）
+func (s *FileService) maxUploadSize(fileType string) int64 {
+	if fileType == "intro" {
+		return s.maxIntroSize
+	}
+	return s.maxResourceSize
+}
+
+// checkDuplicate 检查课程下是否已存在相同内容的文件
+func (s *FileService) checkDuplicate(courseID uint, checksum string) error {
+	if existing, err := s.courseRepo.GetCourseFileByChecksum(courseID, checksum); err == nil {
+		return errcode.NewWithMessage(errcode.CodeFileDuplicate, fmt.Sprintf("文件已存在: %s", existing.FileName))
+	}
+	return nil
+}
+
+// sanitizeFileName 清理客户端文件名：去除路径和控制字符，仅保留安全字符
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	ext := strings.ToLower(filepath.Ext(name))
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	var b strings.Builder
+	for _, r := range base {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r), r == '.':
+			b.WriteRune('_')
+		}
+	}
+
+	cleaned := strings.Trim(b.String(), "_")
+	if runes := []rune(cleaned); len(runes) > 100 {
+		cleaned = string(runes[:100])
+	}
+	if cleaned == "" {
+		cleaned = "file"
+	}
+
+	// 扩展名只保留字母数字
+	if len(ext) > 1 && strings.IndexFunc(ext[1:], func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) >= 0 {
+		ext = ""
+	}
+
+	return cleaned + ext
+}
+
+// hashReader 计算内容的 SHA-256
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isSHA256Hex 检查是否为合法的 SHA-256 十六进制字符串
+func isSHA256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+)
+
+const (
+	historyMergeWindow   = 24 * time.Hour // 窗口内重复浏览同一目标合并为一条记录
+	historyPruneInterval = 24 * time.Hour
+)
+
+var historyTargetTypes = map[string]bool{
+	model.HistoryNote:   true,
+	model.HistoryCourse: true,
+}
+
+// HistoryService 用户浏览记录（笔记和课程）
+type HistoryService struct {
+	repo          *repository.HistoryRepository
+	retentionDays int
+}
+
+func NewHistoryService(repo *repository.HistoryRepository, cfg *config.Config) *HistoryService {
+	days := cfg.BrowseHistoryDays
+	if days <= 0 {
+		days = 180
+	}
+	return &HistoryService{repo: repo, retentionDays: days}
+}
+
+// Record 记录一次浏览，userID 为 0（未登录）时忽略；失败只记日志，不影响详情页
+func (s *HistoryService) Record(userID uint, targetType string, targetID uint) {
+	if userID == 0 || targetID == 0 || !historyTargetTypes[targetType] {
+		return
+	}
+	if err := s.repo.Touch(userID, targetType, targetID, historyWindow(time.Now())); err != nil {
+		log.Printf("record browse history failed (user %d, %s %d): %v", userID, targetType, targetID, err)
+	}
+}
+
+// historyWindow 浏览时间所在的合并窗口编号
+func historyWindow(t time.Time) int64 {
+	return t.Unix() / int64(historyMergeWindow/time.Second)
+}
+
+// List 获取用户浏览记录并附带笔记或课程，targetType 为空表示全部类型
+func (s *HistoryService) List(userID uint, targetType string, page, pageSize int) ([]model.BrowseHistory, int64, error) {
+	if targetType != "" && !historyTargetTypes[targetType] {
+		return nil, 0, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的记录类型")
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+
+	history, total, err := s.repo.List(userID, targetType, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.attachTargets(history); err != nil {
+		return nil, 0, err
+	}
+	return history, total, nil
+}
+
+// attachTargets 按类型批量加载浏览记录对应的笔记和课程
+func (s *HistoryService) attachTargets(history []model.BrowseHistory) error {
+	var noteIDs, courseIDs []uint
+	for _, h := range history {
+		switch h.TargetType {
+		case model.HistoryNote:
+			noteIDs = append(noteIDs, h.TargetID)
+		case model.HistoryCourse:
+			courseIDs = append(courseIDs, h.TargetID)
+		}
+	}
+
+	notes := make(map[uint]*model.Note)
+	if len(noteIDs) > 0 {
+		list, err := s.repo.GetNotes(noteIDs)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			notes[list[i].ID] = &list[i]
+		}
+	}
+	courses := make(map[uint]*model.Course)
+	if len(courseIDs) > 0 {
+		list, err := s.repo.GetCourses(courseIDs)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			courses[list[i].ID] = &list[i]
+		}
+	}
+
+	for i := range history {
+		switch history[i].TargetType {
+		case model.HistoryNote:
+			history[i].Note = notes[history[i].TargetID]
+		case model.HistoryCourse:
+			history[i].Course = courses[history[i].TargetID]
+		}
+	}
+	return nil
+}
+
+// Delete 删除一条浏览记录
+func (s *HistoryService) Delete(userID, id uint) error {
+	found, err := s.repo.Delete(userID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "浏览记录不存在")
+	}
+	return nil
+}
+
+// Clear 清空浏览记录，targetType 为空表示全部类型
+func (s *HistoryService) Clear(userID uint, targetType string) (int64, error) {
+	if targetType != "" && !historyTargetTypes[targetType] {
+		return 0, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的记录类型")
+	}
+	return s.repo.Clear(userID, targetType)
+}
+
+// StartPruner 启动后台任务，启动时及每天清理超过保留天数的浏览记录
+func (s *HistoryService) StartPruner() {
+	go func() {
+		ticker := time.NewTicker(historyPruneInterval)
+		defer ticker.Stop()
+		for {
+			before := time.Now().AddDate(0, 0, -s.retentionDays)
+			n, err := s.repo.Prune(before)
+			if err != nil {
+				log.Printf("prune browse history failed: %v", err)
+			} else if n > 0 {
+				log.Printf("pruned %d browse history rows older than %d days", n, s.retentionDays)
+			}
+			<-ticker.C
+		}
+	}()
+}
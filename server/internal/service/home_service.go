@@ -0,0 +1,135 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"car4race/internal/model"
+)
+
+const (
+	homeCacheTTL     = time.Minute // 首页数据缓存时间；后台修改笔记、课程或公告时立即失效
+	homeLatestNotes  = 8
+	homeHotCourses   = 6
+	homeCourseSortBy = "views"
+)
+
+// Home 首页数据：最新笔记、热门课程、公告
+type Home struct {
+	LatestNotes   []model.Note         `json:"latest_notes"`
+	HotCourses    []model.Course       `json:"hot_courses"`
+	Announcements []model.Announcement `json:"announcements"`
+}
+
+// HomePage 首页数据及其 ETag
+type HomePage struct {
+	Data *Home
+	ETag string
+}
+
+type homeCacheEntry struct {
+	page    *HomePage
+	expires time.Time
+}
+
+// HomeService 首页聚合接口，按可见范围缓存，减少首页对数据库的查询
+type HomeService struct {
+	contentService      *ContentService
+	courseService       *CourseService
+	announcementService *AnnouncementService
+
+	mu         sync.Mutex
+	cache      map[string]homeCacheEntry // 可见范围 → 首页数据
+	generation uint64                    // 每次 Invalidate 递增，丢弃失效前开始构建的结果
+}
+
+func NewHomeService(contentService *ContentService, courseService *CourseService, announcementService *AnnouncementService) *HomeService {
+	return &HomeService{
+		contentService:      contentService,
+		courseService:       courseService,
+		announcementService: announcementService,
+		cache:               make(map[string]homeCacheEntry),
+	}
+}
+
+// GetHome 获取首页数据，userID 为 0 表示未登录
+// 公告按登录状态和会员身份过滤，因此缓存按可见范围区分
+func (s *HomeService) GetHome(userID uint) (*HomePage, error) {
+	audience := s.announcementService.Audience(userID)
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[audience]
+	generation := s.generation
+	s.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.page, nil
+	}
+
+	page, expires, err := s.build(audience, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建期间缓存已失效时，结果可能读到修改前的数据，只返回不缓存
+	s.mu.Lock()
+	if s.generation == generation {
+		s.cache[audience] = homeCacheEntry{page: page, expires: expires}
+	}
+	s.mu.Unlock()
+	return page, nil
+}
+
+// Invalidate 清空首页缓存，后台修改笔记、课程或公告后调用
+func (s *HomeService) Invalidate() {
+	s.mu.Lock()
+	s.cache = make(map[string]homeCacheEntry)
+	s.generation++
+	s.mu.Unlock()
+}
+
+// build 查询首页各部分并计算 ETag，返回缓存到期时间
+// 有公告在缓存期内结束时提前到期，避免过期公告继续展示
+func (s *HomeService) build(audience string, now time.Time) (*HomePage, time.Time, error) {
+	notes, _, err := s.contentService.GetNotes(0, "", 1, homeLatestNotes)
+	if err != nil {
+		return nil, now, err
+	}
+	// 首页只展示标题和摘要，不输出正文
+	for i := range notes {
+		notes[i].Content = ""
+	}
+	courses, _, err := s.courseService.GetCourses(1, homeHotCourses, homeCourseSortBy, "")
+	if err != nil {
+		return nil, now, err
+	}
+	announcements, err := s.announcementService.GetActiveFor(audience)
+	if err != nil {
+		return nil, now, err
+	}
+
+	home := &Home{LatestNotes: notes, HotCourses: courses, Announcements: announcements}
+	if home.LatestNotes == nil {
+		home.LatestNotes = []model.Note{}
+	}
+	if home.HotCourses == nil {
+		home.HotCourses = []model.Course{}
+	}
+
+	body, err := json.Marshal(home)
+	if err != nil {
+		return nil, now, err
+	}
+	sum := sha256.Sum256(body)
+
+	expires := now.Add(homeCacheTTL)
+	for _, a := range announcements {
+		if a.EndAt != nil && a.EndAt.Before(expires) {
+			expires = *a.EndAt
+		}
+	}
+	return &HomePage{Data: home, ETag: `W/"` + hex.EncodeToString(sum[:12]) + `"`}, expires, nil
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+
+	"car4race/pkg/errcode"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// 图片用途
+const (
+	ImagePurposeCover   = "cover"   // 笔记、课程封面
+	ImagePurposeContent = "content" // 笔记正文插图
+	ImagePurposeAvatar  = "avatar"  // 用户头像，裁剪为正方形
+)
+
+const (
+	minImageSide     = 16
+	minAvatarSide    = 64
+	maxImageSide     = 10000
+	maxImagePixels   = 40_000_000 // 解码前按像素数拦截，防止解压炸弹
+	maxAvatarSide    = 1024       // 头像原图超过该尺寸时先缩小
+	imageJPEGQuality = 90
+	variantQuality   = 82
+)
+
+// imageVariantSpec 缩略图规格，size 为目标宽度（头像为边长）
+type imageVariantSpec struct {
+	name string
+	size int
+}
+
+var imageVariantSpecs = map[string][]imageVariantSpec{
+	ImagePurposeCover:   {{"lg", 1280}, {"md", 640}, {"sm", 320}},
+	ImagePurposeContent: {{"lg", 1600}, {"md", 800}},
+	ImagePurposeAvatar:  {{"md", 256}, {"sm", 64}},
+}
+
+// ImageVariant 缩略图
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// UploadedImage 图片上传结果
+type UploadedImage struct {
+	URL      string                  `json:"url"`
+	Width    int                     `json:"width"`
+	Height   int                     `json:"height"`
+	Format   string                  `json:"format"`
+	Size     int                     `json:"size"`
+	Variants map[string]ImageVariant `json:"variants"`
+}
+
+// ImageService 图片处理：校验、去除元数据、生成缩略图
+type ImageService struct {
+	fileService *FileService
+}
+
+func NewImageService(fileService *FileService) *ImageService {
+	return &ImageService{fileService: fileService}
+}
+
+// IsValidImagePurpose 图片用途是否合法
+func IsValidImagePurpose(purpose string) bool {
+	_, ok := imageVariantSpecs[purpose]
+	return ok
+}
+
+// Upload 处理并保存图片
+// 原图重新编码后保存，EXIF（含 GPS 定位）等元数据随之丢弃，JPEG 按 EXIF 方向先摆正
+// 缩略图只生成比原图小的规格；不透明图片输出 JPEG，带透明通道的输出 PNG
+// 标准库及 x/image 均无 WebP 编码器，WebP 原图同样转为 JPEG/PNG
+func (s *ImageService) Upload(data []byte, purpose string) (*UploadedImage, error) {
+	specs, ok := imageVariantSpecs[purpose]
+	if !ok {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的图片用途")
+	}
+
+	processed, err := processImage(data, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(processed.data)
+	hash := hex.EncodeToString(sum[:])
+	key := hash[:2] + "/" + hash + imageTypes[processed.mime]
+	if err := s.fileService.PutObject(imageObjectPrefix+key, processed.data, processed.mime); err != nil {
+		return nil, err
+	}
+
+	bounds := processed.img.Bounds()
+	result := &UploadedImage{
+		URL:      imagePublicPath + key,
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Format:   processed.mime[len("image/"):],
+		Size:     len(processed.data),
+		Variants: make(map[string]ImageVariant),
+	}
+
+	for _, spec := range specs {
+		if spec.size >= bounds.Dx() {
+			continue
+		}
+		thumb := resizeImage(processed.img, spec.size)
+		out, mime, err := encodeImage(thumb, "", variantQuality)
+		if err != nil {
+			return nil, err
+		}
+		vkey := hash[:2] + "/" + hash + "_" + spec.name + imageTypes[mime]
+		if err := s.fileService.PutObject(imageObjectPrefix+vkey, out, mime); err != nil {
+			return nil, err
+		}
+		tb := thumb.Bounds()
+		result.Variants[spec.name] = ImageVariant{URL: imagePublicPath + vkey, Width: tb.Dx(), Height: tb.Dy()}
+	}
+	return result, nil
+}
+
+// processedImage 处理后的原图
+type processedImage struct {
+	img  image.Image
+	data []byte
+	mime string
+}
+
+// processImage 校验格式和尺寸，解码后重新编码
+func processImage(data []byte, purpose string) (*processedImage, error) {
+	if len(data) == 0 {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片为空")
+	}
+	if len(data) > maxImageSize {
+		return nil, errcode.NewWithMessage(errcode.CodeFileTooLarge, "图片不能超过 10MB")
+	}
+	mime := mimetype.Detect(data).String()
+	if _, ok := imageTypes[mime]; !ok {
+		return nil, errcode.NewWithMessage(errcode.CodeFileTypeNotAllowed, "不支持的图片格式: "+mime)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片已损坏或无法识别")
+	}
+	minSide := minImageSide
+	if purpose == ImagePurposeAvatar {
+		minSide = minAvatarSide
+	}
+	if cfg.Width < minSide || cfg.Height < minSide {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片尺寸过小")
+	}
+	if cfg.Width > maxImageSide || cfg.Height > maxImageSide || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片尺寸过大")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片已损坏或无法识别")
+	}
+	if mime == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// GIF 不做重新编码以保留动画，只剔除注释和 XMP 等扩展块；头像仍需裁剪
+	if mime == "image/gif" && purpose != ImagePurposeAvatar {
+		out, err := stripGIFMetadata(data)
+		if err != nil {
+			return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "图片已损坏或无法识别")
+		}
+		return &processedImage{img: img, data: out, mime: mime}, nil
+	}
+
+	if purpose == ImagePurposeAvatar {
+		img = cropSquare(img)
+		if img.Bounds().Dx() > maxAvatarSide {
+			img = resizeImage(img, maxAvatarSide)
+		}
+	}
+
+	prefer := mime
+	if mime != "image/jpeg" && mime != "image/png" {
+		prefer = ""
+	}
+	out, outMime, err := encodeImage(img, prefer, imageJPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	return &processedImage{img: img, data: out, mime: outMime}, nil
+}
+
+// encodeImage 按 prefer 指定的格式编码；未指定时不透明图片用 JPEG，否则用 PNG
+func encodeImage(img image.Image, prefer string, quality int) ([]byte, string, error) {
+	mime := prefer
+	if mime == "" {
+		mime = "image/png"
+		if isOpaque(img) {
+			mime = "image/jpeg"
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if mime == "image/jpeg" {
+		err = jpeg.Encode(&buf, flattenImage(img), &jpeg.Options{Quality: quality})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mime, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// flattenImage 把透明部分铺在白底上，供 JPEG 编码
+func flattenImage(img image.Image) image.Image {
+	if isOpaque(img) {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
+
+// resizeImage 等比缩放到指定宽度
+func resizeImage(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	var dst draw.Image
+	if isOpaque(img) {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	} else {
+		dst = image.NewNRGBA(image.Rect(0, 0, width, height))
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// cropSquare 居中裁剪为正方形
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	origin := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+	dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, origin, draw.Src)
+	return dst
+}
+
+// ========== EXIF 方向 ==========
+
+// jpegOrientation 读取 JPEG 中 EXIF 的 Orientation（1-8），缺失或无法解析时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // 填充字节
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // 扫描数据开始，后面不再有元数据
+			return 1
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation 在 TIFF 结构的 IFD0 中查找 Orientation（0x0112）
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+	var u16 func([]byte) int
+	var u32 func([]byte) int
+	switch string(t[:2]) {
+	case "II":
+		u16 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+		u32 = func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 | int(b[3])<<24 }
+	case "MM":
+		u16 = func(b []byte) int { return int(b[1]) | int(b[0])<<8 }
+		u32 = func(b []byte) int { return int(b[3]) | int(b[2])<<8 | int(b[1])<<16 | int(b[0])<<24 }
+	default:
+		return 1
+	}
+	ifd := u32(t[4:8])
+	if ifd < 8 || ifd+2 > len(t) {
+		return 1
+	}
+	count := u16(t[ifd:])
+	for k := 0; k < count; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(t) {
+			break
+		}
+		if u16(t[e:]) == 0x0112 {
+			if v := u16(t[e+8:]); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation 按 EXIF 方向旋转/翻转，使图片按正确朝向保存
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	ox, oy := b.Min.X, b.Min.Y
+
+	// 目标坐标 (dx, dy) 对应的原图坐标
+	var src func(dx, dy int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2: // 水平翻转
+		src = func(dx, dy int) (int, int) { return w - 1 - dx, dy }
+	case 3: // 旋转 180°
+		src = func(dx, dy int) (int, int) { return w - 1 - dx, h - 1 - dy }
+	case 4: // 垂直翻转
+		src = func(dx, dy int) (int, int) { return dx, h - 1 - dy }
+	case 5: // 沿主对角线翻转
+		dw, dh = h, w
+		src = func(dx, dy int) (int, int) { return dy, dx }
+	case 6: // 顺时针旋转 90°
+		dw, dh = h, w
+		src = func(dx, dy int) (int, int) { return dy, h - 1 - dx }
+	case 7: // 沿副对角线翻转
+		dw, dh = h, w
+		src = func(dx, dy int) (int, int) { return w - 1 - dy, h - 1 - dx }
+	case 8: // 逆时针旋转 90°
+		dw, dh = h, w
+		src = func(dx, dy int) (int, int) { return w - 1 - dy, dx }
+	}
+	return transformImage(img, dw, dh, func(dx, dy int) (int, int) {
+		x, y := src(dx, dy)
+		return ox + x, oy + y
+	})
+}
+
+// transformImage 按坐标映射逐像素复制到新图
+func transformImage(img image.Image, w, h int, src func(dx, dy int) (int, int)) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for dy := 0; dy < h; dy++ {
+		for dx := 0; dx < w; dx++ {
+			x, y := src(dx, dy)
+			dst.Set(dx, dy, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// ========== GIF 元数据 ==========
+
+// stripGIFMetadata 去除 GIF 中的注释、纯文本和应用扩展块
+// 保留图形控制扩展（帧延时、透明色）和 NETSCAPE 循环扩展
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	errBad := errors.New("malformed gif")
+	if len(data) < 13 {
+		return nil, errBad
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 { // 全局颜色表
+		pos += 3 << (flags&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, errBad
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:pos]...)
+
+	// skipSubBlocks 返回数据子块序列（以 0 长度块结尾）之后的位置
+	skipSubBlocks := func(i int) (int, error) {
+		for i < len(data) {
+			n := int(data[i])
+			i++
+			if n == 0 {
+				return i, nil
+			}
+			i += n
+		}
+		return 0, errBad
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B: // 结束符，之后的附加数据一并丢弃
+			return append(out, 0x3B), nil
+		case 0x21: // 扩展块
+			if pos+2 > len(data) {
+				return nil, errBad
+			}
+			label := data[pos+1]
+			end, err := skipSubBlocks(pos + 2)
+			if err != nil {
+				return nil, err
+			}
+			keep := label == 0xF9
+			if label == 0xFF && end-pos >= 14 && data[pos+2] == 11 {
+				app := string(data[pos+3 : pos+14])
+				keep = app == "NETSCAPE2.0" || app == "ANIMEXTS1.0"
+			}
+			if keep {
+				out = append(out, data[pos:end]...)
+			}
+			pos = end
+		case 0x2C: // 图像描述符
+			start := pos
+			pos += 10
+			if pos > len(data) {
+				return nil, errBad
+			}
+			if flags := data[pos-1]; flags&0x80 != 0 { // 局部颜色表
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos++ // LZW 最小码长
+			end, err := skipSubBlocks(pos)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data[start:end]...)
+			pos = end
+		default:
+			return nil, errBad
+		}
+	}
+	// 缺少结束符的文件补上
+	return append(out, 0x3B), nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+const (
+	// markdownRenderVersion 渲染规则变更时递增，使旧缓存失效
+	markdownRenderVersion = "1"
+	markdownCacheSize     = 512 // 渲染结果缓存条数
+)
+
+// TOCItem 目录项，ID 与渲染结果中标题的 id 属性一致
+type TOCItem struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// RenderedMarkdown Markdown 渲染结果
+type RenderedMarkdown struct {
+	HTML string    `json:"html"`
+	TOC  []TOCItem `json:"toc"`
+}
+
+// markdownCacheEntry LRU 缓存项
+type markdownCacheEntry struct {
+	key    string
+	result RenderedMarkdown
+}
+
+// MarkdownService 将笔记正文、课程介绍等 Markdown 渲染为安全的 HTML
+// 渲染结果按内容哈希缓存，内容不变时直接复用
+type MarkdownService struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+}
+
+func NewMarkdownService() *MarkdownService {
+	return &MarkdownService{
+		capacity: markdownCacheSize,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Render 渲染 Markdown，返回 HTML 和标题目录
+// 原始 HTML 一律转义，链接和图片地址经过协议白名单过滤；note:slug 链接解析为站内笔记地址
+func (s *MarkdownService) Render(md string) RenderedMarkdown {
+	if md == "" {
+		return RenderedMarkdown{TOC: []TOCItem{}}
+	}
+
+	key := markdownCacheKey(md)
+	if result, ok := s.get(key); ok {
+		return result
+	}
+
+	result := renderMarkdown(md)
+	s.put(key, result)
+	return result
+}
+
+// get 读取缓存并标记为最近使用
+func (s *MarkdownService) get(key string) (RenderedMarkdown, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return RenderedMarkdown{}, false
+	}
+	s.order.MoveToFront(elem)
+	return elem.Value.(*markdownCacheEntry).result, true
+}
+
+// put 写入缓存，超出容量时淘汰最久未使用的条目
+func (s *MarkdownService) put(key string, result RenderedMarkdown) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.order.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&markdownCacheEntry{key: key, result: result})
+
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*markdownCacheEntry).key)
+	}
+}
+
+// markdownCacheKey 缓存键：渲染版本 + 内容的 SHA-256
+func markdownCacheKey(md string) string {
+	sum := sha256.Sum256([]byte(markdownRenderVersion + "\x00" + md))
+	return hex.EncodeToString(sum[:])
+}
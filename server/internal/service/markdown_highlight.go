@@ -0,0 +1,294 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+const maxHighlightSize = 64 << 10 // 超过该大小的代码块不做高亮，直接转义输出
+
+// 高亮输出的 CSS 类名
+const (
+	hlKeyword = "hl-keyword"
+	hlLiteral = "hl-literal"
+	hlBuiltin = "hl-builtin"
+	hlString  = "hl-string"
+	hlNumber  = "hl-number"
+	hlComment = "hl-comment"
+	hlAttr    = "hl-attr"
+)
+
+// codeLanguage 代码高亮规则
+type codeLanguage struct {
+	keywords     map[string]bool
+	literals     map[string]bool // true、false、nil 等字面量
+	builtins     map[string]bool // 内置函数和类型
+	lineComments []string
+	blockComment [2]string
+	quotes       string // 字符串定界符
+	tripleQuotes bool   // 支持 """ 多行字符串
+	keyColon     bool   // 冒号前的键名单独着色（JSON、YAML）
+	identDash    bool   // 标识符可包含 -（YAML 键名）
+	caseFold     bool   // 关键字不区分大小写（SQL）
+}
+
+var mdCodeLangName = regexp.MustCompile(`^[a-zA-Z0-9_+#-]{1,32}$`)
+
+// codeLanguageAliases 语言别名
+var codeLanguageAliases = map[string]string{
+	"golang": "go", "javascript": "js", "jsx": "js", "ts": "js", "typescript": "js", "tsx": "js", "vue": "js",
+	"py": "python", "python3": "python", "sh": "bash", "shell": "bash", "zsh": "bash", "console": "bash",
+	"cpp": "c", "c++": "c", "cc": "c", "h": "c", "hpp": "c", "kotlin": "java", "kt": "java",
+	"yml": "yaml", "mysql": "sql", "sqlite": "sql", "postgresql": "sql",
+}
+
+var codeLanguages = map[string]*codeLanguage{
+	"go": {
+		keywords: codeWords(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var`),
+		literals: codeWords(`true false nil iota`),
+		builtins: codeWords(`any append bool byte cap close complex copy delete error float32 float64 int int8 int16
+			int32 int64 len make new panic print println recover rune string uint uint8 uint16 uint32 uint64 uintptr`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"js": {
+		keywords: codeWords(`abstract as async await break case catch class const continue debugger declare default
+			delete do else enum export extends finally for from function get if implements import in instanceof
+			interface keyof let namespace new of private protected public readonly return set static super switch
+			this throw try type typeof var void while with yield`),
+		literals:     codeWords(`true false null undefined NaN Infinity`),
+		builtins:     codeWords(`Array Boolean Date Error JSON Map Math Number Object Promise RegExp Set String console window document`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	},
+	"python": {
+		keywords: codeWords(`and as assert async await break case class continue def del elif else except finally
+			for from global if import in is lambda match nonlocal not or pass raise return try while with yield`),
+		literals:     codeWords(`True False None`),
+		builtins:     codeWords(`bool dict enumerate float int isinstance len list open print range self set str super tuple type zip`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		tripleQuotes: true,
+	},
+	"bash": {
+		keywords: codeWords(`if then else elif fi for while until do done case esac in function return local
+			export readonly declare unset shift exit break continue source`),
+		builtins:     codeWords(`echo cd pwd printf read test set eval exec trap alias sudo`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"json": {
+		literals: codeWords(`true false null`),
+		quotes:   "\"",
+		keyColon: true,
+	},
+	"yaml": {
+		literals:     codeWords(`true false null yes no on off`),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		keyColon:     true,
+		identDash:    true,
+	},
+	"sql": {
+		keywords: codeWords(`add all alter and as asc begin between by case check column commit constraint create
+			default delete desc distinct drop else end exists foreign from full group having if in index inner insert
+			into is join key left like limit not null offset on or order outer primary references right rollback
+			select set table then union unique update values view when where with`),
+		literals:     codeWords(`true false`),
+		builtins:     codeWords(`avg coalesce count date ifnull length lower max min now substr sum upper`),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+		caseFold:     true,
+	},
+	"c": {
+		keywords: codeWords(`auto break case catch class const constexpr continue default define delete do else
+			endif enum extern for goto if ifdef ifndef include inline namespace new operator private protected public
+			register return sizeof static struct switch template this throw try typedef typename union using virtual
+			volatile while`),
+		literals: codeWords(`true false NULL nullptr`),
+		builtins: codeWords(`bool char double float int long short signed size_t std string unsigned vector void
+			printf malloc free`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"java": {
+		keywords: codeWords(`abstract assert break case catch class const continue default do else enum extends
+			final finally for fun if implements import instanceof interface native new package private protected
+			public record return static super switch synchronized this throw throws transient try val var volatile
+			when while`),
+		literals:     codeWords(`true false null`),
+		builtins:     codeWords(`boolean byte char double float int long short void String Integer List Map Object System`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+}
+
+func codeWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		words[w] = true
+	}
+	return words
+}
+
+// highlightCode 输出 <pre><code class="language-x">，支持的语言按词法规则包裹 <span class="hl-*">
+// 不支持的语言只做转义
+func highlightCode(lang, code string) string {
+	var b strings.Builder
+	b.WriteString("<pre><code")
+
+	var rules *codeLanguage
+	if mdCodeLangName.MatchString(lang) {
+		name := strings.ToLower(lang)
+		b.WriteString(` class="language-` + html.EscapeString(name) + `"`)
+		if alias, ok := codeLanguageAliases[name]; ok {
+			name = alias
+		}
+		rules = codeLanguages[name]
+	}
+	b.WriteString(">")
+
+	if rules != nil && len(code) <= maxHighlightSize {
+		highlightTokens(&b, code, rules)
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</code></pre>\n")
+	return b.String()
+}
+
+// highlightTokens 按注释、字符串、数字、标识符切分代码并着色
+func highlightTokens(b *strings.Builder, code string, lang *codeLanguage) {
+	plain := 0 // 尚未输出的普通文本起点
+	emit := func(start, end int, class string) {
+		b.WriteString(html.EscapeString(code[plain:start]))
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(html.EscapeString(code[start:end]))
+		b.WriteString("</span>")
+		plain = end
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if open := lang.blockComment[0]; open != "" && strings.HasPrefix(rest, open) {
+			end := len(code)
+			if k := strings.Index(rest[len(open):], lang.blockComment[1]); k >= 0 {
+				end = i + len(open) + k + len(lang.blockComment[1])
+			}
+			emit(i, end, hlComment)
+			i = end
+			continue
+		}
+		if lang.isLineComment(rest) {
+			end := len(code)
+			if k := strings.IndexByte(rest, '\n'); k >= 0 {
+				end = i + k
+			}
+			emit(i, end, hlComment)
+			i = end
+			continue
+		}
+
+		c := code[i]
+		switch {
+		case strings.IndexByte(lang.quotes, c) >= 0:
+			end := stringEnd(code, i, lang.tripleQuotes)
+			class := hlString
+			if lang.keyColon && keyColonAt(code, end) {
+				class = hlAttr
+			}
+			emit(i, end, class)
+			i = end
+
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentByte(code[i-1])):
+			end := i + 1
+			for end < len(code) && (isIdentByte(code[end]) || code[end] == '.') {
+				end++
+			}
+			emit(i, end, hlNumber)
+			i = end
+
+		case isIdentByte(c):
+			end := i + 1
+			for end < len(code) && (isIdentByte(code[end]) || lang.identDash && code[end] == '-') {
+				end++
+			}
+			word := code[i:end]
+			if lang.caseFold {
+				word = strings.ToLower(word)
+			}
+			switch {
+			case lang.keyColon && keyColonAt(code, end):
+				emit(i, end, hlAttr)
+			case lang.keywords[word]:
+				emit(i, end, hlKeyword)
+			case lang.literals[word]:
+				emit(i, end, hlLiteral)
+			case lang.builtins[word]:
+				emit(i, end, hlBuiltin)
+			}
+			i = end
+
+		default:
+			i++
+		}
+	}
+	b.WriteString(html.EscapeString(code[plain:]))
+}
+
+func (l *codeLanguage) isLineComment(rest string) bool {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd 返回从 i 开始的字符串字面量的结束位置；未闭合的单行字符串到行尾结束
+func stringEnd(code string, i int, triple bool) int {
+	q := code[i]
+	if triple {
+		delim := strings.Repeat(string(q), 3)
+		if strings.HasPrefix(code[i:], delim) {
+			if k := strings.Index(code[i+3:], delim); k >= 0 {
+				return i + 3 + k + 3
+			}
+			return len(code)
+		}
+	}
+	for j := i + 1; j < len(code); j++ {
+		switch code[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		case '\n':
+			if q != '`' {
+				return j
+			}
+		}
+	}
+	return len(code)
+}
+
+// keyColonAt 位置 i 之后（忽略空格）是否为键名后的冒号（排除 URL 中的 "://"）
+func keyColonAt(code string, i int) bool {
+	for i < len(code) && (code[i] == ' ' || code[i] == '\t') {
+		i++
+	}
+	return i < len(code) && code[i] == ':' && (i+1 == len(code) || code[i+1] != '/' && code[i+1] != ':')
+}
+
+// isIdentByte 标识符字符，非 ASCII 字节视为标识符的一部分
+func isIdentByte(c byte) bool {
+	return isASCIIAlnum(c) || c == '_' || c == '$' || c >= 0x80
+}
@@ -0,0 +1,1082 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxMarkdownDepth = 16   // 引用、列表、强调的最大嵌套层数，超出后按普通文本处理
+	maxTOCLevel      = 4    // 目录收录的最大标题层级
+	maxLinkTarget    = 2048 // 链接地址和标题的最大长度，避免未闭合的链接反复扫描到行尾
+)
+
+var (
+	mdATXHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdListItem    = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:([ \t]+)(.*)|$)`)
+	mdTaskItem    = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+(.*)|$)`)
+	mdTableDelim  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdEntity      = regexp.MustCompile(`^&(#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	mdEmail       = regexp.MustCompile(`^[A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+	mdNoteSlug    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	mdRenderedTag = regexp.MustCompile(`<[^>]*>`)
+)
+
+// markdownRenderer 块级渲染状态
+type markdownRenderer struct {
+	out strings.Builder
+	toc []TOCItem
+	ids map[string]bool // 已使用的标题 id
+}
+
+// renderMarkdown 渲染 Markdown（CommonMark 常用子集 + GFM 表格、任务列表、删除线、自动链接）
+// 不支持原始 HTML，所有尖括号均转义输出
+func renderMarkdown(md string) RenderedMarkdown {
+	md = strings.ReplaceAll(md, "\r\n", "\n")
+	md = strings.ReplaceAll(md, "\r", "\n")
+	// \x00 在段落中用作硬换行标记
+	md = strings.ReplaceAll(md, "\x00", "\uFFFD")
+
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		lines[i] = expandLeadingTabs(line)
+	}
+
+	r := &markdownRenderer{toc: []TOCItem{}, ids: make(map[string]bool)}
+	r.renderBlocks(lines, 0, false)
+	return RenderedMarkdown{HTML: r.out.String(), TOC: r.toc}
+}
+
+// ========== 块级元素 ==========
+
+// renderBlocks 渲染一组行；tight 为 true 时（紧凑列表项内）段落不包裹 <p>
+func (r *markdownRenderer) renderBlocks(lines []string, depth int, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlankLine(line):
+			i++
+		case isCodeFence(line):
+			i = r.renderFencedCode(lines, i)
+		case mdATXHeading.MatchString(line):
+			m := mdATXHeading.FindStringSubmatch(line)
+			r.renderHeading(len(m[1]), m[2])
+			i++
+		case isThematicBreak(line):
+			r.out.WriteString("<hr>\n")
+			i++
+		case leadingSpaces(line) >= 4:
+			i = r.renderIndentedCode(lines, i)
+		case depth < maxMarkdownDepth && isBlockquote(line):
+			i = r.renderBlockquote(lines, i, depth)
+		case depth < maxMarkdownDepth && isListItem(line):
+			i = r.renderList(lines, i, depth)
+		case isTableStart(lines, i):
+			i = r.renderTable(lines, i, depth)
+		default:
+			i = r.renderParagraph(lines, i, depth, tight)
+		}
+	}
+}
+
+// renderHeading 输出带 id 的标题并记录目录
+func (r *markdownRenderer) renderHeading(level int, text string) {
+	content := renderInline(strings.TrimSpace(text))
+	plain := strings.TrimSpace(html.UnescapeString(mdRenderedTag.ReplaceAllString(content, "")))
+	id := r.headingID(plain)
+
+	fmt.Fprintf(&r.out, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), content, level)
+	if level <= maxTOCLevel {
+		r.toc = append(r.toc, TOCItem{Level: level, Text: plain, ID: id})
+	}
+}
+
+// headingID 由标题文本生成锚点 id：保留字母（含中文）、数字和下划线，空白与连字符合并为 "-"
+// 重复时依次追加 -1、-2
+func (r *markdownRenderer) headingID(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsNumber(c) || c == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(c)
+		case c == '-' || unicode.IsSpace(c):
+			dash = true
+		}
+	}
+
+	base := b.String()
+	if base == "" {
+		base = "section"
+	}
+	id := base
+	for n := 1; r.ids[id]; n++ {
+		id = base + "-" + strconv.Itoa(n)
+	}
+	r.ids[id] = true
+	return id
+}
+
+// renderFencedCode 渲染 ``` 或 ~~~ 围栏代码块，返回下一行位置
+func (r *markdownRenderer) renderFencedCode(lines []string, i int) int {
+	indent := leadingSpaces(lines[i])
+	open := lines[i][indent:]
+	n := runLength(open, 0, open[0])
+	info := strings.TrimSpace(open[n:])
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if leadingSpaces(line) <= 3 {
+			trimmed := strings.TrimSpace(line)
+			if len(trimmed) >= n && runLength(trimmed, 0, open[0]) == len(trimmed) {
+				i++
+				break
+			}
+		}
+		// 去掉与起始围栏相同的缩进
+		strip := leadingSpaces(line)
+		if strip > indent {
+			strip = indent
+		}
+		code = append(code, line[strip:])
+	}
+
+	lang := ""
+	if fields := strings.Fields(info); len(fields) > 0 {
+		lang = fields[0]
+	}
+	text := strings.Join(code, "\n")
+	if len(code) > 0 {
+		text += "\n"
+	}
+	r.out.WriteString(highlightCode(lang, text))
+	return i
+}
+
+// renderIndentedCode 渲染缩进 4 个空格的代码块
+func (r *markdownRenderer) renderIndentedCode(lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) {
+			code = append(code, "")
+			continue
+		}
+		if leadingSpaces(line) < 4 {
+			break
+		}
+		code = append(code, line[4:])
+	}
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	r.out.WriteString(highlightCode("", strings.Join(code, "\n")+"\n"))
+	return i
+}
+
+// renderBlockquote 渲染引用块，支持省略 > 的段落续行
+func (r *markdownRenderer) renderBlockquote(lines []string, i, depth int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlockquote(line) {
+			rest := strings.TrimLeft(line, " ")[1:]
+			inner = append(inner, strings.TrimPrefix(rest, " "))
+			continue
+		}
+		if !isBlankLine(line) && len(inner) > 0 && !isBlankLine(inner[len(inner)-1]) && !startsBlock(line, depth) {
+			inner = append(inner, line)
+			continue
+		}
+		break
+	}
+
+	r.out.WriteString("<blockquote>\n")
+	r.renderBlocks(inner, depth+1, false)
+	r.out.WriteString("</blockquote>\n")
+	return i
+}
+
+// renderList 渲染有序或无序列表，列表项之间或项内有空行时为松散列表（段落包裹 <p>）
+func (r *markdownRenderer) renderList(lines []string, i, depth int) int {
+	first, _ := parseListItem(lines[i])
+	indent := first.indent
+	blank := false
+	items := [][]string{{first.content}}
+
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) {
+			items[len(items)-1] = append(items[len(items)-1], "")
+			blank = true
+			continue
+		}
+		if item, ok := parseListItem(line); ok && leadingSpaces(line) < indent {
+			if item.ordered != first.ordered || item.delim != first.delim || isThematicBreak(line) {
+				break
+			}
+			items = append(items, []string{item.content})
+			indent = item.indent
+			blank = false
+			continue
+		}
+		if leadingSpaces(line) >= indent {
+			items[len(items)-1] = append(items[len(items)-1], line[indent:])
+			blank = false
+			continue
+		}
+		// 懒惰续行：段落可不缩进地延续到下一行
+		if !blank && !startsBlock(line, depth) {
+			items[len(items)-1] = append(items[len(items)-1], strings.TrimLeft(line, " "))
+			continue
+		}
+		break
+	}
+
+	loose := false
+	for k, item := range items {
+		n := len(item)
+		for n > 1 && item[n-1] == "" {
+			n--
+		}
+		if n < len(item) && k < len(items)-1 {
+			loose = true
+		}
+		for _, line := range item[1:n] {
+			if line == "" {
+				loose = true
+			}
+		}
+		items[k] = item[:n]
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	if first.ordered && first.start != 1 {
+		fmt.Fprintf(&r.out, "<ol start=\"%d\">\n", first.start)
+	} else {
+		r.out.WriteString("<" + tag + ">\n")
+	}
+
+	for _, item := range items {
+		if m := mdTaskItem.FindStringSubmatch(item[0]); m != nil {
+			r.out.WriteString(`<li class="task-list-item"><input type="checkbox" disabled`)
+			if m[1] != " " {
+				r.out.WriteString(" checked")
+			}
+			r.out.WriteString("> ")
+			item[0] = m[2]
+		} else {
+			r.out.WriteString("<li>")
+		}
+		r.renderBlocks(item, depth+1, !loose)
+		r.out.WriteString("</li>\n")
+	}
+	r.out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderTable 渲染 GFM 表格
+func (r *markdownRenderer) renderTable(lines []string, i, depth int) int {
+	header := splitTableRow(lines[i])
+	aligns := make([]string, 0, len(header))
+	for _, cell := range splitTableRow(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		r.out.WriteString("<tr>\n")
+		for k, align := range aligns {
+			cell := ""
+			if k < len(cells) {
+				cell = cells[k]
+			}
+			r.out.WriteString("<" + tag)
+			if align != "" {
+				r.out.WriteString(` style="text-align:` + align + `"`)
+			}
+			r.out.WriteString(">" + renderInline(cell) + "</" + tag + ">\n")
+		}
+		r.out.WriteString("</tr>\n")
+	}
+
+	r.out.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	r.out.WriteString("</thead>\n")
+
+	i += 2
+	body := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) || startsBlock(line, depth) {
+			break
+		}
+		if !body {
+			r.out.WriteString("<tbody>\n")
+			body = true
+		}
+		writeRow(splitTableRow(line), "td")
+	}
+	if body {
+		r.out.WriteString("</tbody>\n")
+	}
+	r.out.WriteString("</table>\n")
+	return i
+}
+
+// renderParagraph 渲染段落；下一行为 === 或 --- 时作为 Setext 标题
+// 行尾两个以上空格或反斜杠表示硬换行
+func (r *markdownRenderer) renderParagraph(lines []string, i, depth int, tight bool) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) {
+			break
+		}
+		if len(para) > 0 {
+			if level := setextLevel(line); level > 0 {
+				r.renderHeading(level, strings.Join(para, "\n"))
+				return i + 1
+			}
+			if startsBlock(line, depth) {
+				break
+			}
+		}
+		para = append(para, strings.TrimLeft(line, " "))
+	}
+
+	for k, line := range para {
+		if k == len(para)-1 {
+			para[k] = strings.TrimRight(line, " ")
+			break
+		}
+		switch {
+		case strings.HasSuffix(line, "  "):
+			para[k] = strings.TrimRight(line, " ") + "\x00"
+		case strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\"):
+			para[k] = line[:len(line)-1] + "\x00"
+		}
+	}
+
+	content := renderInline(strings.Join(para, "\n"))
+	if tight {
+		r.out.WriteString(content + "\n")
+	} else {
+		r.out.WriteString("<p>" + content + "</p>\n")
+	}
+	return i
+}
+
+// ========== 块级判断 ==========
+
+// listItem 列表项标记
+type listItem struct {
+	ordered bool
+	delim   byte   // 无序列表为 - * +，有序列表为 . )
+	start   int    // 有序列表起始序号
+	indent  int    // 项内容的缩进宽度，后续行缩进达到该宽度即属于本项
+	content string // 首行内容
+}
+
+func parseListItem(line string) (listItem, bool) {
+	m := mdListItem.FindStringSubmatch(line)
+	if m == nil {
+		return listItem{}, false
+	}
+	marker := m[2]
+	item := listItem{delim: marker[len(marker)-1], content: m[4]}
+	if item.delim == '.' || item.delim == ')' {
+		item.ordered = true
+		item.start, _ = strconv.Atoi(marker[:len(marker)-1])
+	}
+
+	spaces := len(m[3])
+	if spaces > 4 {
+		// 标记后空格过多时按一个空格计算缩进，其余属于内容
+		item.content = m[3][1:] + m[4]
+		spaces = 1
+	}
+	if m[4] == "" {
+		spaces = 1
+	}
+	item.indent = len(m[1]) + len(marker) + spaces
+	return item, true
+}
+
+func isListItem(line string) bool {
+	_, ok := parseListItem(line)
+	return ok
+}
+
+// startsBlock 该行是否开始新的块，用于判断段落是否结束
+// 与 CommonMark 一致，有序列表只有从 1 开始时才能打断段落，空列表项不能打断段落
+func startsBlock(line string, depth int) bool {
+	if isCodeFence(line) || mdATXHeading.MatchString(line) || isThematicBreak(line) {
+		return true
+	}
+	if depth >= maxMarkdownDepth {
+		return false
+	}
+	if isBlockquote(line) {
+		return true
+	}
+	item, ok := parseListItem(line)
+	return ok && strings.TrimSpace(item.content) != "" && (!item.ordered || item.start == 1)
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isCodeFence(line string) bool {
+	indent := leadingSpaces(line)
+	if indent > 3 {
+		return false
+	}
+	rest := line[indent:]
+	if len(rest) < 3 || (rest[0] != '`' && rest[0] != '~') {
+		return false
+	}
+	n := runLength(rest, 0, rest[0])
+	if n < 3 {
+		return false
+	}
+	// 反引号围栏的信息串中不能再出现反引号，否则是行内代码
+	return rest[0] != '`' || !strings.Contains(rest[n:], "`")
+}
+
+func isThematicBreak(line string) bool {
+	if leadingSpaces(line) > 3 {
+		return false
+	}
+	var mark rune
+	count := 0
+	for _, c := range line {
+		switch {
+		case c == ' ' || c == '\t':
+		case (c == '-' || c == '*' || c == '_') && (mark == 0 || c == mark):
+			mark = c
+			count++
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+func isBlockquote(line string) bool {
+	indent := leadingSpaces(line)
+	return indent <= 3 && indent < len(line) && line[indent] == '>'
+}
+
+// isTableStart 表头行含 |，下一行为分隔行且列数一致
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || leadingSpaces(lines[i]) > 3 || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	if !mdTableDelim.MatchString(lines[i+1]) {
+		return false
+	}
+	return len(splitTableRow(lines[i])) == len(splitTableRow(lines[i+1]))
+}
+
+// splitTableRow 按 | 切分表格行，\| 表示单元格内的竖线
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// setextLevel 段落下一行为 === 返回 1，为 --- 返回 2，否则返回 0
+func setextLevel(line string) int {
+	if leadingSpaces(line) > 3 {
+		return 0
+	}
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return 0
+	}
+	switch {
+	case runLength(trimmed, 0, '=') == len(trimmed):
+		return 1
+	case runLength(trimmed, 0, '-') == len(trimmed):
+		return 2
+	}
+	return 0
+}
+
+func leadingSpaces(line string) int {
+	n := 0
+	for n < len(line) && line[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// expandLeadingTabs 将行首的制表符展开为空格（制表位宽 4），便于统一计算缩进
+func expandLeadingTabs(line string) string {
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	if !strings.Contains(indent, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	i := 0
+	for ; i < len(line) && (line[i] == ' ' || line[i] == '\t'); i++ {
+		if line[i] == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		} else {
+			b.WriteByte(' ')
+			col++
+		}
+	}
+	return b.String() + line[i:]
+}
+
+// runLength 从 i 开始连续字符 c 的个数
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// ========== 行内元素 ==========
+
+// inlineParser 行内渲染状态
+// brackets 预先配对的方括号位置；noCloser 记录已确认之后不存在闭合分隔符的起点，避免重复扫描
+type inlineParser struct {
+	s        string
+	out      *strings.Builder
+	inLink   bool
+	depth    int
+	brackets map[int]int
+	noCloser map[int]int
+}
+
+// renderInline 渲染行内元素：代码、强调、删除线、链接、图片、自动链接、转义和硬换行
+func renderInline(s string) string {
+	var b strings.Builder
+	writeInline(&b, s, false, 0)
+	return b.String()
+}
+
+func writeInline(b *strings.Builder, s string, inLink bool, depth int) {
+	if depth > maxMarkdownDepth {
+		b.WriteString(html.EscapeString(strings.ReplaceAll(s, "\x00", "\n")))
+		return
+	}
+	p := &inlineParser{s: s, out: b, inLink: inLink, depth: depth, noCloser: make(map[int]int)}
+	p.pairBrackets()
+	p.render()
+}
+
+func (p *inlineParser) render() {
+	s := p.s
+	text := 0 // 尚未输出的普通文本起点
+	emit := func(i int, markup string) {
+		p.out.WriteString(html.EscapeString(s[text:i]))
+		p.out.WriteString(markup)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			emit(i, html.EscapeString(s[i+1:i+2]))
+			i += 2
+			text = i
+			continue
+
+		case c == '\x00':
+			emit(i, "<br>")
+			i++
+			text = i
+			continue
+
+		case c == '`':
+			if code, end, ok := p.codeSpan(i); ok {
+				emit(i, "<code>"+html.EscapeString(code)+"</code>")
+				i, text = end, end
+				continue
+			}
+			i += runLength(s, i, '`')
+			continue
+
+		case c == '&':
+			if entity := mdEntity.FindString(s[i:]); entity != "" {
+				emit(i, entity)
+				i += len(entity)
+				text = i
+				continue
+			}
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if markup, end, ok := p.link(i+1, true); ok {
+				emit(i, markup)
+				i, text = end, end
+				continue
+			}
+
+		case c == '[' && !p.inLink:
+			if markup, end, ok := p.link(i, false); ok {
+				emit(i, markup)
+				i, text = end, end
+				continue
+			}
+
+		case c == '<' && !p.inLink:
+			if markup, end, ok := p.autolink(i); ok {
+				emit(i, markup)
+				i, text = end, end
+				continue
+			}
+
+		case c == 'h' && !p.inLink && (i == 0 || !isASCIIAlnum(s[i-1])):
+			if markup, end, ok := p.bareURL(i); ok {
+				emit(i, markup)
+				i, text = end, end
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if literal, markup, end, ok := p.emphasis(i); ok {
+				emit(i, html.EscapeString(literal)+markup)
+				i, text = end, end
+				continue
+			}
+			i += runLength(s, i, c)
+			continue
+		}
+		i++
+	}
+	p.out.WriteString(html.EscapeString(s[text:]))
+}
+
+// pairBrackets 为方括号配对，跳过转义字符和行内代码
+func (p *inlineParser) pairBrackets() {
+	p.brackets = make(map[int]int)
+	var stack []int
+	for i := 0; i < len(p.s); i++ {
+		switch p.s[i] {
+		case '\\':
+			i++
+		case '`':
+			if _, end, ok := p.codeSpan(i); ok {
+				i = end - 1
+			} else {
+				i += runLength(p.s, i, '`') - 1
+			}
+		case '[':
+			stack = append(stack, i)
+		case ']':
+			if len(stack) > 0 {
+				p.brackets[stack[len(stack)-1]] = i
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// codeSpan 解析行内代码，i 指向起始反引号
+func (p *inlineParser) codeSpan(i int) (string, int, bool) {
+	s := p.s
+	n := runLength(s, i, '`')
+	key := -n // 负数键，与强调分隔符的键区分
+	if from, ok := p.noCloser[key]; ok && i >= from {
+		return "", 0, false
+	}
+
+	for j := i + n; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		m := runLength(s, j, '`')
+		if m == n {
+			code := strings.NewReplacer("\n", " ", "\x00", " ").Replace(s[i+n : j])
+			if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			return code, j + m, true
+		}
+		j += m
+	}
+	p.noCloser[key] = i
+	return "", 0, false
+}
+
+// link 解析链接 [text](url "title") 或图片 ![alt](url "title")，open 指向 '['
+func (p *inlineParser) link(open int, image bool) (string, int, bool) {
+	s := p.s
+	closeAt, ok := p.brackets[open]
+	if !ok || closeAt+1 >= len(s) || s[closeAt+1] != '(' {
+		return "", 0, false
+	}
+	dest, title, end, ok := parseLinkTarget(s[:min(len(s), closeAt+2+maxLinkTarget)], closeAt+2)
+	if !ok {
+		return "", 0, false
+	}
+	text := s[open+1 : closeAt]
+
+	if image {
+		alt := inlinePlainText(text)
+		src, _, ok := resolveMarkdownURL(dest, true)
+		if !ok {
+			return html.EscapeString(alt), end, true
+		}
+		markup := `<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(alt) + `"`
+		if title != "" {
+			markup += ` title="` + html.EscapeString(title) + `"`
+		}
+		return markup + ` loading="lazy" decoding="async">`, end, true
+	}
+
+	var inner strings.Builder
+	writeInline(&inner, text, true, p.depth+1)
+	href, external, ok := resolveMarkdownURL(dest, false)
+	if !ok {
+		// 不安全的地址只保留链接文字
+		return inner.String(), end, true
+	}
+	return anchorTag(href, title, external, inner.String()), end, true
+}
+
+// autolink 解析 <https://...> 和 <user@example.com>
+// 地址中不能包含空白和 '<'，遇到时立即停止，避免未闭合的 '<' 反复扫描到行尾
+func (p *inlineParser) autolink(i int) (string, int, bool) {
+	end := 1
+	for ; i+end < len(p.s) && p.s[i+end] != '>'; end++ {
+		if strings.IndexByte(" \t\n\x00<", p.s[i+end]) >= 0 {
+			return "", 0, false
+		}
+	}
+	if i+end >= len(p.s) || end == 1 {
+		return "", 0, false
+	}
+	inner := p.s[i+1 : i+end]
+
+	if mdEmail.MatchString(inner) {
+		return anchorTag("mailto:"+inner, "", false, html.EscapeString(inner)), i + end + 1, true
+	}
+	if !strings.Contains(inner, ":") {
+		return "", 0, false
+	}
+	href, external, ok := resolveMarkdownURL(inner, false)
+	if !ok {
+		return "", 0, false
+	}
+	return anchorTag(href, "", external, html.EscapeString(inner)), i + end + 1, true
+}
+
+// bareURL 识别正文中直接书写的 http(s) 地址，遇到空白或非 ASCII 字符（如中文标点）结束
+func (p *inlineParser) bareURL(i int) (string, int, bool) {
+	s := p.s
+	if !strings.HasPrefix(s[i:], "http://") && !strings.HasPrefix(s[i:], "https://") {
+		return "", 0, false
+	}
+	end := i
+	for end < len(s) && s[end] > ' ' && s[end] < 0x7f && s[end] != '<' {
+		end++
+	}
+	// 去掉末尾的标点和未配对的右括号
+	for end > i {
+		last := s[end-1]
+		if strings.IndexByte(".,:;!?\"'*_~", last) >= 0 {
+			end--
+			continue
+		}
+		if last == ')' && strings.Count(s[i:end], ")") > strings.Count(s[i:end], "(") {
+			end--
+			continue
+		}
+		break
+	}
+	raw := s[i:end]
+	if raw == "http://" || raw == "https://" || len(raw) <= len("https://") {
+		return "", 0, false
+	}
+	return anchorTag(raw, "", true, html.EscapeString(raw)), end, true
+}
+
+// emphasis 解析 *em*、**strong**、***both***、_em_、__strong__ 和 ~~del~~
+// 分隔符多于所需时，多出的部分作为普通文本返回
+func (p *inlineParser) emphasis(i int) (literal, markup string, end int, ok bool) {
+	s := p.s
+	c := s[i]
+	run := runLength(s, i, c)
+	if run > 3 || (c == '~' && run != 2) {
+		return "", "", 0, false
+	}
+	// 下划线在单词内部（如 snake_case）不作为强调
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", "", 0, false
+	}
+	if i+run >= len(s) || isSpaceByte(s[i+run]) {
+		return "", "", 0, false
+	}
+
+	for n := run; n >= 1; n-- {
+		closeAt := p.findCloser(i+run, c, n)
+		if closeAt < 0 {
+			continue
+		}
+		var inner strings.Builder
+		writeInline(&inner, s[i+run:closeAt], p.inLink, p.depth+1)
+		content := inner.String()
+
+		switch {
+		case c == '~':
+			markup = "<del>" + content + "</del>"
+		case n == 1:
+			markup = "<em>" + content + "</em>"
+		case n == 2:
+			markup = "<strong>" + content + "</strong>"
+		default:
+			markup = "<em><strong>" + content + "</strong></em>"
+		}
+		return s[i : i+run-n], markup, closeAt + n, true
+	}
+	return "", "", 0, false
+}
+
+// findCloser 从 from 开始查找长度恰为 n 的闭合分隔符，跳过转义和行内代码；未找到返回 -1
+// 闭合分隔符是否有效只取决于其自身位置，因此某起点查找失败后，之后的起点也必然失败
+func (p *inlineParser) findCloser(from int, c byte, n int) int {
+	s := p.s
+	key := int(c)*4 + n
+	if failed, ok := p.noCloser[key]; ok && from >= failed {
+		return -1
+	}
+
+	for j := from; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			if _, end, ok := p.codeSpan(j); ok {
+				j = end
+			} else {
+				j += runLength(s, j, '`')
+			}
+			continue
+		case c:
+			m := runLength(s, j, c)
+			if m == n && !isSpaceByte(s[j-1]) && (c != '_' || j+m >= len(s) || !isWordByte(s[j+m])) {
+				return j
+			}
+			j += m
+			continue
+		}
+		j++
+	}
+	p.noCloser[key] = from
+	return -1
+}
+
+// parseLinkTarget 解析 (url "title") 中的地址和标题，start 指向 '(' 之后
+func parseLinkTarget(s string, start int) (dest, title string, end int, ok bool) {
+	i := skipLinkSpaces(s, start)
+
+	if i < len(s) && s[i] == '<' {
+		closeAt := strings.IndexAny(s[i+1:], ">\n")
+		if closeAt < 0 || s[i+1+closeAt] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+1+closeAt]
+		i += closeAt + 2
+	} else {
+		begin, parens := i, 0
+	loop:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+			case c == '(':
+				parens++
+			case c == ')':
+				if parens == 0 {
+					break loop
+				}
+				parens--
+			case c <= ' ':
+				break loop
+			}
+		}
+		dest = s[begin:i]
+	}
+
+	i = skipLinkSpaces(s, i)
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		quote := s[i]
+		if quote == '(' {
+			quote = ')'
+		}
+		closeAt := -1
+		for j := i + 1; j < len(s); j++ {
+			if s[j] == '\\' {
+				j++
+				continue
+			}
+			if s[j] == quote {
+				closeAt = j
+				break
+			}
+		}
+		if closeAt < 0 {
+			return "", "", 0, false
+		}
+		title = html.UnescapeString(unescapeMarkdown(s[i+1 : closeAt]))
+		i = skipLinkSpaces(s, closeAt+1)
+	}
+
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0, false
+	}
+	return html.UnescapeString(unescapeMarkdown(dest)), title, i + 1, true
+}
+
+func skipLinkSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\x00') {
+		i++
+	}
+	return i
+}
+
+// resolveMarkdownURL 过滤链接地址，返回处理后的地址及是否为站外链接
+// 仅允许 http、https、mailto（图片不允许）和站内相对地址；note:slug[#anchor] 解析为 /notes/slug
+func resolveMarkdownURL(raw string, image bool) (string, bool, bool) {
+	u := strings.TrimSpace(raw)
+	if u == "" {
+		return "", false, !image
+	}
+
+	// 浏览器解析协议时会忽略其中的空白和控制字符（如 "java\tscript:"），判断前先去除
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	lower := strings.ToLower(cleaned)
+
+	if strings.HasPrefix(lower, "note:") {
+		if image {
+			return "", false, false
+		}
+		slug, anchor := cleaned[len("note:"):], ""
+		if k := strings.IndexByte(slug, '#'); k >= 0 {
+			slug, anchor = slug[:k], slug[k:]
+		}
+		if !mdNoteSlug.MatchString(slug) {
+			return "", false, false
+		}
+		return "/notes/" + slug + anchor, false, true
+	}
+
+	u = strings.ReplaceAll(u, " ", "%20")
+	if colon := strings.IndexByte(cleaned, ':'); colon >= 0 && !strings.ContainsAny(cleaned[:colon], "/?#") {
+		switch lower[:colon] {
+		case "http", "https":
+			return u, true, true
+		case "mailto":
+			return u, false, !image
+		}
+		return "", false, false
+	}
+	// 协议相对地址指向站外（浏览器将 \ 视同 /）
+	external := strings.HasPrefix(cleaned, "//") || strings.HasPrefix(cleaned, "/\\") || strings.HasPrefix(cleaned, "\\")
+	return u, external, true
+}
+
+// anchorTag 生成链接标签，站外链接在新窗口打开且不传递权重
+func anchorTag(href, title string, external bool, content string) string {
+	tag := `<a href="` + html.EscapeString(href) + `"`
+	if title != "" {
+		tag += ` title="` + html.EscapeString(title) + `"`
+	}
+	if external {
+		tag += ` target="_blank" rel="noopener noreferrer nofollow"`
+	}
+	return tag + ">" + content + "</a>"
+}
+
+// inlinePlainText 行内 Markdown 渲染后的纯文本，用于图片 alt 等属性
+func inlinePlainText(s string) string {
+	return html.UnescapeString(mdRenderedTag.ReplaceAllString(renderInline(s), ""))
+}
+
+// unescapeMarkdown 去掉 ASCII 标点前的反斜杠转义
+func unescapeMarkdown(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < 0x80 && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isASCIIAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isWordByte 单词字符，非 ASCII 字节（多字节字符的一部分）也视为单词字符
+func isWordByte(c byte) bool {
+	return isASCIIAlnum(c) || c == '_' || c >= 0x80
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\x00'
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 渲染结果中的链接和图片地址
+var testURLAttr = regexp.MustCompile(`(?:href|src)="([^"]*)"`)
+
+func TestRenderMarkdownURLSchemes(t *testing.T) {
+	const ext = ` target="_blank" rel="noopener noreferrer nofollow"`
+	cases := []struct {
+		name, in, want string
+	}{
+		{"javascript", "[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"mixed case", "[x](JaVaScRiPt:alert(1))", "<p>x</p>\n"},
+		{"tab in scheme", "[x](<java\tscript:alert(1)>)", "<p>x</p>\n"},
+		{"bare tab is not a link", "[x](java\tscript:alert(1))", "<p>[x](java\tscript:alert(1))</p>\n"},
+		{"leading space", "[x]( javascript:alert(1))", "<p>x</p>\n"},
+		{"escaped letter", "[x](java\\script:alert(1))", "<p>x</p>\n"},
+		{"vbscript", "[x](vbscript:msgbox)", "<p>x</p>\n"},
+		{"data link", "[x](data:text/html;base64,PHNjcmlwdD4=)", "<p>x</p>\n"},
+		{"data image", "![x](data:image/png;base64,AAA)", "<p>x</p>\n"},
+		{"javascript image", "![x](javascript:alert(1))", "<p>x</p>\n"},
+		{"unknown scheme", "[x](a:b/c)", "<p>x</p>\n"},
+		{"entity encoded letter", "[x](&#106;avascript:alert(1))", "<p>x</p>\n"},
+		{"entity encoded colon", "[x](javascript&#58;alert(1))", "<p>x</p>\n"},
+		{"named entity colon", "[x](&#x6A;avascript&colon;alert(1))", "<p>x</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"mailto link", "[x](mailto:a@b.c)", `<p><a href="mailto:a@b.c">x</a></p>` + "\n"},
+		{"mailto image", "![x](mailto:a@b.c)", "<p>x</p>\n"},
+		{"protocol relative", "[x](//evil.com)", `<p><a href="//evil.com"` + ext + ">x</a></p>\n"},
+		{"backslash host", "[x](/\\evil.com)", `<p><a href="/\evil.com"` + ext + ">x</a></p>\n"},
+		{"https", "[x](https://a.com)", `<p><a href="https://a.com"` + ext + ">x</a></p>\n"},
+		{"relative", "[x](/notes/a)", `<p><a href="/notes/a">x</a></p>` + "\n"},
+		{"query only", "[x](?a=1)", `<p><a href="?a=1">x</a></p>` + "\n"},
+		{"quote in href", `[x](https://a.com/"><script>)`, `<p><a href="https://a.com/&#34;&gt;&lt;script&gt;"` + ext + ">x</a></p>\n"},
+		{"quote in title", `[x](https://a.com "t\" onmouseover=\"alert(1)")`, `<p><a href="https://a.com" title="t&#34; onmouseover=&#34;alert(1)"` + ext + ">x</a></p>\n"},
+		{"quote in bare url", `https://a.com/"onmouseover=alert(1)`, `<p><a href="https://a.com/&#34;onmouseover=alert(1)"` + ext + `>https://a.com/&#34;onmouseover=alert(1)</a></p>` + "\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := renderMarkdown(tc.in).HTML
+			if got != tc.want {
+				t.Errorf("render(%q)\n got  %q\n want %q", tc.in, got, tc.want)
+			}
+			assertSafeURLs(t, got)
+		})
+	}
+}
+
+// assertSafeURLs 检查渲染结果中的地址在浏览器解码后不会以可执行协议开头
+func assertSafeURLs(t *testing.T, out string) {
+	t.Helper()
+	for _, m := range testURLAttr.FindAllStringSubmatch(out, -1) {
+		u := strings.Map(func(r rune) rune {
+			if r <= ' ' || r == 0x7f {
+				return -1
+			}
+			return r
+		}, strings.ToLower(html.UnescapeString(m[1])))
+		for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+			if strings.HasPrefix(u, scheme) {
+				t.Errorf("unsafe url %q in %q", m[1], out)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownRawHTML(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+		{"```html\n<script>alert(1)</script>\n```", "&lt;script&gt;"},
+		{"`<script>`", "<p><code>&lt;script&gt;</code></p>\n"},
+		{"# <script>", "&lt;script&gt;</h1>"},
+		{"| <b> |\n| --- |\n| <i> |", "&lt;i&gt;"},
+	}
+	for _, tc := range cases {
+		got := renderMarkdown(tc.in).HTML
+		if !strings.Contains(got, tc.want) {
+			t.Errorf("render(%q) = %q, want it to contain %q", tc.in, got, tc.want)
+		}
+		if strings.Contains(got, "<script") || strings.Contains(got, "<img src=x") {
+			t.Errorf("render(%q) = %q contains raw html", tc.in, got)
+		}
+	}
+}
+
+func TestRenderMarkdownNoteLinks(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"[x](note:my-slug)", `<p><a href="/notes/my-slug">x</a></p>` + "\n"},
+		{"[x](note:my_slug#sec)", `<p><a href="/notes/my_slug#sec">x</a></p>` + "\n"},
+		{"[x](NOTE:Abc)", `<p><a href="/notes/Abc">x</a></p>` + "\n"},
+		{"[x](note:../etc)", "<p>x</p>\n"},
+		{"[x](note:a/b)", "<p>x</p>\n"},
+		{"[x](note:)", "<p>x</p>\n"},
+		{`[x](note:a"onmouseover=1)`, "<p>x</p>\n"},
+		{"![x](note:abc)", "<p>x</p>\n"},
+	}
+	for _, tc := range cases {
+		if got := renderMarkdown(tc.in).HTML; got != tc.want {
+			t.Errorf("render(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestRenderMarkdownNestingLimits(t *testing.T) {
+	// 不同分隔符可以逐层嵌套
+	got := renderMarkdown("***a __b _c ~~d **e *f x* e** d~~ c_ b__ a***").HTML
+	want := "<p><em><strong>a <strong>b <em>c <del>d <strong>e <em>f x</em> e</strong> d</del> c</em> b</strong> a</strong></em></p>\n"
+	if got != want {
+		t.Errorf("nested emphasis\n got  %q\n want %q", got, want)
+	}
+
+	// 超过最大层数的行内内容按普通文本转义输出
+	var b strings.Builder
+	writeInline(&b, "*a* <b>", false, maxMarkdownDepth+1)
+	if b.String() != "*a* &lt;b&gt;" {
+		t.Errorf("inline beyond depth limit = %q", b.String())
+	}
+
+	for _, in := range []string{strings.Repeat(">", 100) + " x", strings.Repeat("- ", 100) + "x"} {
+		out := renderMarkdown(in).HTML
+		for _, tag := range []string{"blockquote", "ul"} {
+			open, closed := strings.Count(out, "<"+tag+">"), strings.Count(out, "</"+tag+">")
+			if open > maxMarkdownDepth || open != closed {
+				t.Errorf("render(%.10q...) has %d <%s> and %d closers, limit %d", in, open, tag, closed, maxMarkdownDepth)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownPathologicalInput(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("[", 50000) + strings.Repeat("](", 50000),
+		strings.Repeat("![a](", 20000),
+		strings.Repeat("<a ", 50000),
+		strings.Repeat("*a _b [c `d ", 20000),
+	}
+	for _, in := range inputs {
+		start := time.Now()
+		renderMarkdown(in)
+		if d := time.Since(start); d > 2*time.Second {
+			t.Errorf("render(%.10q...) took %v", in, d)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+const memberMaxMonths = 120
+
+// MemberService 会员开通与续期
+type MemberService struct {
+	userRepo      *repository.UserRepository
+	announcement  *AnnouncementService
+	defaultMonths int
+}
+
+func NewMemberService(userRepo *repository.UserRepository, announcement *AnnouncementService, cfg *config.Config) *MemberService {
+	months := cfg.VIPMonths
+	if months <= 0 {
+		months = 12
+	}
+	return &MemberService{userRepo: userRepo, announcement: announcement, defaultMonths: months}
+}
+
+// GrantVIP 开通或续期会员 months 个月（为 0 时使用默认时长）：未过期的会员从原到期时间顺延
+// 非会员首次开通（或过期后重新开通）时记录成为会员的时间，并在首页播报
+func (s *MemberService) GrantVIP(userID uint, months int) (*model.User, error) {
+	if months == 0 {
+		months = s.defaultMonths
+	}
+	if months < 1 || months > memberMaxMonths {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "会员月数无效")
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.New(errcode.CodeUserNotFound)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	wasVIP := user.IsVIP(now)
+	if wasVIP && user.VIPExpireAt == nil {
+		// 永久会员无需续期
+		return user, nil
+	}
+
+	base := now
+	if wasVIP {
+		base = *user.VIPExpireAt
+	} else {
+		user.VIPSince = &now
+	}
+	expireAt := base.AddDate(0, months, 0)
+	user.VIPExpireAt = &expireAt
+	if user.Role != "admin" {
+		user.Role = "vip"
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	if !wasVIP {
+		// 播报失败不影响开通
+		if err := s.announcement.AnnounceNewVIP(user); err != nil {
+			log.Printf("announce new vip %d failed: %v", user.ID, err)
+		}
+	}
+	return user, nil
+}
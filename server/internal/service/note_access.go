@@ -0,0 +1,127 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"car4race/internal/model"
+	"car4race/pkg/errcode"
+)
+
+const notePreviewRunes = 600 // 锁定笔记返回的节选长度（按字符计）
+
+var noteAccessLevels = map[string]bool{
+	model.NoteAccessPublic: true,
+	model.NoteAccessLogin:  true,
+	model.NoteAccessVIP:    true,
+	model.NoteAccessCourse: true,
+}
+
+// applyNoteAccess 校验并规范化笔记访问级别
+func (s *ContentService) applyNoteAccess(note *model.Note) error {
+	if note.AccessLevel == "" {
+		note.AccessLevel = model.NoteAccessPublic
+	}
+	if !noteAccessLevels[note.AccessLevel] {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "无效的访问级别")
+	}
+	if note.AccessLevel != model.NoteAccessCourse {
+		note.AccessCourseID = nil
+		return nil
+	}
+	if note.AccessCourseID == nil || *note.AccessCourseID == 0 {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "请选择关联课程")
+	}
+	if _, err := s.courseRepo.GetCourseByID(*note.AccessCourseID); err != nil {
+		return errcode.New(errcode.CodeCourseNotFound)
+	}
+	return nil
+}
+
+// noteLockedReason 判断用户能否查看笔记全文，不能时返回锁定原因
+// 管理员不受限制；课程笔记需已购买该课程（含套餐、兑换）
+func (s *ContentService) noteLockedReason(note *model.Note, userID uint) string {
+	if !note.IsRestricted() {
+		return ""
+	}
+	if userID == 0 {
+		return model.NoteLockedLogin
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return model.NoteLockedLogin
+	}
+	if user.Role == "admin" {
+		return ""
+	}
+
+	switch note.AccessLevel {
+	case model.NoteAccessLogin:
+		return ""
+	case model.NoteAccessVIP:
+		if user.IsVIP(time.Now()) {
+			return ""
+		}
+		return model.NoteLockedVIP
+	case model.NoteAccessCourse:
+		if note.AccessCourseID != nil {
+			if owned, _ := s.courseRepo.CheckUserPurchased(userID, *note.AccessCourseID); owned {
+				return ""
+			}
+		}
+		return model.NoteLockedPurchase
+	}
+	// 未知级别按最严格处理
+	return model.NoteLockedPurchase
+}
+
+// lockNote 把笔记替换为节选，并附带锁定原因
+func (s *ContentService) lockNote(note *model.Note, reason string) {
+	note.Content = notePreview(note.Content)
+	note.Locked = reason
+	if reason == model.NoteLockedPurchase && note.AccessCourseID != nil {
+		if course, err := s.courseRepo.GetCourseByID(*note.AccessCourseID); err == nil {
+			note.AccessCourse = course
+		}
+	}
+}
+
+// redactRestrictedNotes 列表中的受限笔记只保留节选
+func redactRestrictedNotes(notes []model.Note) {
+	for i := range notes {
+		if notes[i].IsRestricted() {
+			notes[i].Content = notePreview(notes[i].Content)
+		}
+	}
+}
+
+// notePreview 截取笔记开头作为节选
+// 尽量在代码块之外的段落边界截断，避免节选停在未闭合的代码块或半个段落中
+func notePreview(content string) string {
+	if utf8.RuneCountInString(content) <= notePreviewRunes {
+		return content
+	}
+
+	runes, offset, inFence := 0, 0, false
+	cut, cutRunes := 0, 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if isCodeFence(trimmed) {
+			inFence = !inFence
+		}
+		if !inFence && strings.TrimSpace(trimmed) == "" {
+			cut, cutRunes = offset, runes
+		}
+		runes += utf8.RuneCountInString(line)
+		offset += len(line)
+		if runes > notePreviewRunes {
+			break
+		}
+	}
+	// 段落边界太靠前时（如开头只有一行）直接按长度截断，但不截在代码块中间
+	if cutRunes >= notePreviewRunes/2 || inFence && cut > 0 {
+		return strings.TrimRight(content[:cut], "\n")
+	}
+	return truncateRunes(content, notePreviewRunes)
+}
@@ -0,0 +1,491 @@
+package service
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	maxImportArchiveSize = 200 << 20 // 导入 zip 包大小上限
+	maxImportNoteSize    = 5 << 20   // 单个 Markdown 文件大小上限
+	maxImportSummaryLen  = 200       // front matter 未指定摘要时，从正文截取的字数
+	noteImportComment    = "批量导入"
+)
+
+var (
+	// ![alt](path "title")
+	importMarkdownImage = regexp.MustCompile(`!\[([^\]]*)\]\(\s*(<[^>\n]+>|[^)\s]+)((?:\s+"[^"\n]*")?)\s*\)`)
+	// Obsidian 嵌入语法 ![[image.png]]、![[image.png|300]]
+	importWikiImage = regexp.MustCompile(`!\[\[([^\]|#\n]+)(?:#[^\]|\n]*)?(?:\|([^\]\n]*))?\]\]`)
+	importImageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+)
+
+// NoteImportOptions 导入参数
+type NoteImportOptions struct {
+	DefaultCategoryID uint // front matter 未指定分类时使用，0 表示必须在 front matter 中指定
+	AuthorID          uint // 修订记录中的操作人，命令行导入为 0（系统）
+}
+
+// NoteImportSkip 跳过的文件及原因
+type NoteImportSkip struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// NoteImportReport 导入报告，格式与圈速榜 RaceChrono 导入一致，按文件列出跳过原因
+type NoteImportReport struct {
+	Total          int              `json:"total"`
+	Imported       int              `json:"imported"`
+	Created        int              `json:"created"`
+	Updated        int              `json:"updated"`
+	Skipped        int              `json:"skipped"`
+	SkippedReasons []NoteImportSkip `json:"skipped_reasons"`
+	NoteIDs        []uint           `json:"-"` // 导入成功的笔记，用于刷新搜索索引
+}
+
+// noteFrontMatter Markdown 文件头部的 YAML 元数据
+type noteFrontMatter struct {
+	Title    string          `yaml:"title"`
+	Slug     string          `yaml:"slug"`
+	Category string          `yaml:"category"` // 分类 slug 或名称
+	Summary  string          `yaml:"summary"`
+	Cover    string          `yaml:"cover"`
+	Tags     frontMatterList `yaml:"tags"`
+	Public   *bool           `yaml:"public"` // true 发布，false 草稿，不填则新建为草稿、更新时保持原状态
+}
+
+// frontMatterList 兼容列表写法和逗号分隔的字符串写法，忽略 Obsidian 标签前的 #
+type frontMatterList []string
+
+func (l *frontMatterList) UnmarshalYAML(node *yaml.Node) error {
+	var items []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+	case yaml.ScalarNode:
+		items = strings.Split(node.Value, ",")
+	default:
+		return fmt.Errorf("tags 格式错误")
+	}
+
+	list := frontMatterList{}
+	for _, item := range items {
+		if item = strings.TrimPrefix(strings.TrimSpace(item), "#"); item != "" {
+			list = append(list, item)
+		}
+	}
+	*l = list
+	return nil
+}
+
+// noteImportRun 单次导入的上下文
+type noteImportRun struct {
+	fsys       fs.FS
+	opts       NoteImportOptions
+	categories map[string]uint     // 分类 slug 和名称 → ID
+	images     map[string][]string // 小写文件名 → 路径，用于 Obsidian 按文件名引用图片
+	uploaded   map[string]string   // 用途:图片路径 → 上传后的地址
+	slugs      map[string]string   // 本次已导入的 slug → 文件
+}
+
+type NoteImportService struct {
+	contentRepo    *repository.ContentRepository
+	contentService *ContentService
+	tagService     *TagService
+	imageService   *ImageService
+}
+
+func NewNoteImportService(contentRepo *repository.ContentRepository, contentService *ContentService, tagService *TagService, imageService *ImageService) *NoteImportService {
+	return &NoteImportService{
+		contentRepo:    contentRepo,
+		contentService: contentService,
+		tagService:     tagService,
+		imageService:   imageService,
+	}
+}
+
+// ImportZip 从 zip 包导入（管理后台上传）
+func (s *NoteImportService) ImportZip(r io.ReaderAt, size int64, opts NoteImportOptions) (*NoteImportReport, error) {
+	if size > maxImportArchiveSize {
+		return nil, errcode.NewWithMessage(errcode.CodeFileTooLarge, "zip 文件不能超过 200MB")
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "无法解析 zip 文件")
+	}
+	return s.Import(zr, opts)
+}
+
+// ImportDir 从服务器本地目录导入（命令行）
+func (s *NoteImportService) ImportDir(dir string, opts NoteImportOptions) (*NoteImportReport, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("目录不存在: %s", dir)
+	}
+	return s.Import(os.DirFS(dir), opts)
+}
+
+// Import 导入目录树中的全部 Markdown 文件，按 slug 新建或更新笔记
+// 单个文件出错时记录原因并继续处理其他文件
+func (s *NoteImportService) Import(fsys fs.FS, opts NoteImportOptions) (*NoteImportReport, error) {
+	run := &noteImportRun{
+		fsys:       fsys,
+		opts:       opts,
+		categories: make(map[string]uint),
+		images:     make(map[string][]string),
+		uploaded:   make(map[string]string),
+		slugs:      make(map[string]string),
+	}
+
+	categories, err := s.contentRepo.GetAllCategories()
+	if err != nil {
+		return nil, err
+	}
+	defaultFound := opts.DefaultCategoryID == 0
+	for _, category := range categories {
+		run.categories[category.Slug] = category.ID
+		if _, ok := run.categories[category.Name]; !ok {
+			run.categories[category.Name] = category.ID
+		}
+		defaultFound = defaultFound || category.ID == opts.DefaultCategoryID
+	}
+	if !defaultFound {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "默认分类不存在")
+	}
+
+	// 收集 Markdown 文件，跳过隐藏目录（如 .obsidian、.trash）和 macOS 打包产生的 __MACOSX
+	var files []string
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if p != "." && (strings.HasPrefix(name, ".") || name == "__MACOSX") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch ext := strings.ToLower(path.Ext(name)); {
+		case ext == ".md" || ext == ".markdown":
+			files = append(files, p)
+		case importImageExts[ext]:
+			key := strings.ToLower(name)
+			run.images[key] = append(run.images[key], p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取文件列表失败: %v", err)
+	}
+	sort.Strings(files)
+
+	report := &NoteImportReport{Total: len(files), SkippedReasons: []NoteImportSkip{}, NoteIDs: []uint{}}
+	for _, file := range files {
+		note, created, err := s.importFile(run, file)
+		if err != nil {
+			report.Skipped++
+			report.SkippedReasons = append(report.SkippedReasons, NoteImportSkip{File: file, Reason: err.Error()})
+			continue
+		}
+		report.Imported++
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		report.NoteIDs = append(report.NoteIDs, note.ID)
+	}
+	return report, nil
+}
+
+// importFile 导入单个 Markdown 文件
+func (s *NoteImportService) importFile(run *noteImportRun, file string) (*model.Note, bool, error) {
+	data, err := readLimited(run.fsys, file, maxImportNoteSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if !utf8.Valid(data) {
+		return nil, false, fmt.Errorf("文件不是 UTF-8 编码")
+	}
+
+	fm, body, err := parseFrontMatter(string(data))
+	if err != nil {
+		return nil, false, err
+	}
+
+	base := strings.TrimSuffix(path.Base(file), path.Ext(file))
+	note := &model.Note{
+		Title:   strings.TrimSpace(fm.Title),
+		Slug:    strings.TrimSpace(fm.Slug),
+		Summary: strings.TrimSpace(fm.Summary),
+	}
+	if note.Title == "" {
+		note.Title = base
+	}
+	if note.Slug == "" {
+		note.Slug = slugify(base)
+		if note.Slug == "" {
+			return nil, false, fmt.Errorf("缺少 slug：文件名无法生成 slug，请在 front matter 中指定")
+		}
+	}
+	if !mdNoteSlug.MatchString(note.Slug) {
+		return nil, false, fmt.Errorf("slug 格式无效: %s（只能包含字母、数字、- 和 _）", note.Slug)
+	}
+	if other, ok := run.slugs[note.Slug]; ok {
+		return nil, false, fmt.Errorf("slug %s 与 %s 重复", note.Slug, other)
+	}
+	if utf8.RuneCountInString(note.Title) > 200 {
+		return nil, false, fmt.Errorf("标题超过 200 字")
+	}
+	if utf8.RuneCountInString(note.Summary) > 500 {
+		return nil, false, fmt.Errorf("摘要超过 500 字")
+	}
+
+	if ref := strings.TrimSpace(fm.Category); ref != "" {
+		id, ok := run.categories[ref]
+		if !ok {
+			return nil, false, fmt.Errorf("分类不存在: %s", ref)
+		}
+		note.CategoryID = id
+	} else if run.opts.DefaultCategoryID > 0 {
+		note.CategoryID = run.opts.DefaultCategoryID
+	} else {
+		return nil, false, fmt.Errorf("未指定分类")
+	}
+
+	if fm.Public != nil {
+		note.Status = model.StatusDraft
+		if *fm.Public {
+			note.Status = model.StatusPublished
+		}
+	}
+
+	dir := path.Dir(file)
+	if note.Content, err = s.rewriteImages(run, dir, body); err != nil {
+		return nil, false, err
+	}
+	if cover := strings.Trim(strings.TrimSpace(fm.Cover), "[]"); cover != "" {
+		if note.CoverImage, err = s.uploadImage(run, dir, cover, ImagePurposeCover); err != nil {
+			return nil, false, err
+		}
+	}
+	if note.Summary == "" {
+		note.Summary = truncateRunes(markdownPlainText(body), maxImportSummaryLen)
+	}
+
+	var tags []model.Tag
+	if fm.Tags != nil {
+		if tags, err = s.tagService.ResolveTagNames(fm.Tags); err != nil {
+			return nil, false, err
+		}
+	}
+
+	created, err := s.contentService.ImportNote(note, run.opts.AuthorID)
+	if err != nil {
+		return nil, false, err
+	}
+	if tags != nil {
+		if err := s.tagService.SetNoteTags(note.ID, tags); err != nil {
+			return nil, false, err
+		}
+	}
+	run.slugs[note.Slug] = file
+	return note, created, nil
+}
+
+// rewriteImages 上传正文引用的本地图片并替换为上传后的地址，代码块中的内容保持不变
+// Obsidian 嵌入语法同时转换为标准 Markdown 图片
+func (s *NoteImportService) rewriteImages(run *noteImportRun, dir, body string) (string, error) {
+	var firstErr error
+	replace := func(text string) string {
+		text = importMarkdownImage.ReplaceAllStringFunc(text, func(m string) string {
+			parts := importMarkdownImage.FindStringSubmatch(m)
+			ref := strings.TrimSuffix(strings.TrimPrefix(parts[2], "<"), ">")
+			u, err := s.uploadImage(run, dir, ref, ImagePurposeContent)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return m
+			}
+			return "![" + parts[1] + "](" + u + parts[3] + ")"
+		})
+		return importWikiImage.ReplaceAllStringFunc(text, func(m string) string {
+			parts := importWikiImage.FindStringSubmatch(m)
+			ref := strings.TrimSpace(parts[1])
+			if !importImageExts[strings.ToLower(path.Ext(ref))] {
+				return m // 嵌入的是笔记等非图片内容
+			}
+			u, err := s.uploadImage(run, dir, ref, ImagePurposeContent)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return m
+			}
+			alt := strings.TrimSuffix(path.Base(ref), path.Ext(ref))
+			return "![" + alt + "](" + u + ")"
+		})
+	}
+
+	var out strings.Builder
+	var chunk []string
+	inFence := false
+	flush := func() {
+		if len(chunk) > 0 {
+			out.WriteString(replace(strings.Join(chunk, "")))
+			chunk = chunk[:0]
+		}
+	}
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if isCodeFence(strings.TrimRight(line, "\n")) {
+			if !inFence {
+				flush()
+			}
+			inFence = !inFence
+			out.WriteString(line)
+			continue
+		}
+		if inFence {
+			out.WriteString(line)
+		} else {
+			chunk = append(chunk, line)
+		}
+	}
+	flush()
+	return out.String(), firstErr
+}
+
+// uploadImage 上传本地图片并返回地址；站外地址和站内绝对路径原样返回
+// 先按相对笔记文件的路径查找，找不到时按文件名在整个目录树中查找（Obsidian 的默认引用方式）
+func (s *NoteImportService) uploadImage(run *noteImportRun, dir, ref, purpose string) (string, error) {
+	lower := strings.ToLower(ref)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(ref, "/") || strings.HasPrefix(lower, "data:") {
+		return ref, nil
+	}
+
+	name := ref
+	if k := strings.IndexAny(name, "?#"); k >= 0 {
+		name = name[:k]
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	if !importImageExts[strings.ToLower(path.Ext(name))] {
+		return "", fmt.Errorf("不支持的图片格式: %s", ref)
+	}
+
+	file := path.Join(dir, name)
+	if _, err := fs.Stat(run.fsys, file); err != nil {
+		candidates := run.images[strings.ToLower(path.Base(name))]
+		if len(candidates) == 0 {
+			return "", fmt.Errorf("图片不存在: %s", ref)
+		}
+		file = candidates[0]
+	}
+
+	cacheKey := purpose + ":" + file
+	if u, ok := run.uploaded[cacheKey]; ok {
+		return u, nil
+	}
+	data, err := readLimited(run.fsys, file, maxImageSize)
+	if err != nil {
+		return "", fmt.Errorf("图片 %s: %v", ref, err)
+	}
+	img, err := s.imageService.Upload(data, purpose)
+	if err != nil {
+		return "", fmt.Errorf("图片 %s: %v", ref, err)
+	}
+	run.uploaded[cacheKey] = img.URL
+	return img.URL, nil
+}
+
+// parseFrontMatter 拆分 YAML front matter 和正文；没有 front matter 时整篇作为正文
+func parseFrontMatter(content string) (noteFrontMatter, string, error) {
+	var fm noteFrontMatter
+	content = strings.TrimPrefix(content, "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		return fm, content, nil
+	}
+
+	rest := content[len("---\n"):]
+	offset := 0
+	for _, line := range strings.SplitAfter(rest, "\n") {
+		if trimmed := strings.TrimRight(line, " \n"); trimmed == "---" || trimmed == "..." {
+			if err := yaml.Unmarshal([]byte(rest[:offset]), &fm); err != nil {
+				return fm, "", fmt.Errorf("front matter 格式错误: %v", err)
+			}
+			return fm, strings.TrimLeft(rest[offset+len(line):], "\n"), nil
+		}
+		offset += len(line)
+	}
+	return fm, "", fmt.Errorf("front matter 缺少结束标记 ---")
+}
+
+// readLimited 读取文件，超过 limit 字节时返回错误
+func readLimited(fsys fs.FS, name string, limit int64) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件")
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件")
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("文件超过 %dMB", limit>>20)
+	}
+	return data, nil
+}
+
+// slugify 由名称生成 slug：保留小写字母和数字，其余字符合并为 "-"
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(name) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(c)
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// truncateRunes 按字数截断
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
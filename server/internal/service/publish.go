@@ -0,0 +1,127 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"car4race/internal/model"
+	"car4race/pkg/errcode"
+)
+
+// 草稿预览链接有效期
+const previewLinkTTL = 24 * time.Hour
+
+// PreviewToken 预览链接中的签名参数
+type PreviewToken struct {
+	Exp string
+	Sig string
+}
+
+// PreviewLink 管理员生成的草稿预览链接
+type PreviewLink struct {
+	URL      string    `json:"url"`     // 站点页面地址
+	APIURL   string    `json:"api_url"` // 对应的详情接口地址
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// previewSigner 为草稿生成/校验预览签名，签名绑定内容类型和 ID
+type previewSigner struct {
+	secret  []byte
+	siteURL string
+}
+
+func newPreviewSigner(secret, siteURL string) *previewSigner {
+	return &previewSigner{secret: []byte(secret), siteURL: siteURL}
+}
+
+// Link 生成预览链接，kind 为 notes 或 courses
+func (p *previewSigner) Link(kind string, id uint, slug string) *PreviewLink {
+	expireAt := time.Now().Add(previewLinkTTL)
+	exp := strconv.FormatInt(expireAt.Unix(), 10)
+
+	q := url.Values{}
+	q.Set("preview_exp", exp)
+	q.Set("preview_sig", p.sign(kind, id, exp))
+	query := q.Encode()
+
+	path := "/" + kind + "/" + url.PathEscape(slug)
+	return &PreviewLink{
+		URL:      p.siteURL + path + "?" + query,
+		APIURL:   "/api/v1/hpa" + path + "?" + query,
+		ExpireAt: expireAt,
+	}
+}
+
+// Verify 校验预览签名及有效期
+func (p *previewSigner) Verify(kind string, id uint, token *PreviewToken) bool {
+	if token == nil || token.Sig == "" {
+		return false
+	}
+	exp, err := strconv.ParseInt(token.Exp, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(token.Sig), []byte(p.sign(kind, id, token.Exp)))
+}
+
+func (p *previewSigner) sign(kind string, id uint, exp string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	fmt.Fprintf(mac, "preview:%s:%d:%s", kind, id, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolvePublishState 计算保存时的发布状态
+// status 为空时按 isPublic 兼容旧接口；定时时间已过则直接发布，发布时间在未来则转为定时发布
+func resolvePublishState(status string, publishAt *time.Time, isPublic bool) (string, *time.Time, error) {
+	now := time.Now()
+	if status == "" {
+		status = model.StatusDraft
+		if isPublic {
+			status = model.StatusPublished
+		}
+	}
+
+	switch status {
+	case model.StatusScheduled:
+		if publishAt == nil {
+			return "", nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "定时发布需指定发布时间")
+		}
+		if !publishAt.After(now) {
+			status = model.StatusPublished
+		}
+	case model.StatusPublished:
+		if publishAt == nil {
+			publishAt = &now
+		} else if publishAt.After(now) {
+			status = model.StatusScheduled
+		}
+	case model.StatusDraft, model.StatusArchived:
+	default:
+		return "", nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "发布状态无效")
+	}
+	return status, publishAt, nil
+}
+
+// startPublishTicker 定时执行发布任务
+func startPublishTicker(name string, interval time.Duration, publish func(time.Time) (int64, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := publish(time.Now())
+			if err != nil {
+				log.Printf("publish scheduled %s failed: %v", name, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("published %d scheduled %s", n, name)
+			}
+		}
+	}()
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	searchQueueLen = 200 // 索引更新队列长度
+	maxQueryLength = 100 // 搜索关键词最大字数
+)
+
+// SearchResult 搜索结果，TitleHTML 和 Snippet 已转义并用 <mark> 标记命中词
+type SearchResult struct {
+	Type       string `json:"type"` // note | course
+	ID         uint   `json:"id"`
+	Title      string `json:"title"`
+	Slug       string `json:"slug"`
+	CoverImage string `json:"cover_image"`
+	TitleHTML  string `json:"title_html"`
+	Snippet    string `json:"snippet"`
+}
+
+// searchTask 索引更新任务
+type searchTask struct {
+	docType string
+	id      uint
+}
+
+type SearchService struct {
+	repo        *repository.SearchRepository
+	contentRepo *repository.ContentRepository
+	courseRepo  *repository.CourseRepository
+	fileService *FileService
+	tasks       chan searchTask
+}
+
+func NewSearchService(repo *repository.SearchRepository, contentRepo *repository.ContentRepository, courseRepo *repository.CourseRepository, fileService *FileService) *SearchService {
+	if err := repo.EnableFTS(); err != nil {
+		log.Printf("FTS5 unavailable (build with -tags sqlite_fts5), search falls back to LIKE: %v", err)
+	}
+	return &SearchService{
+		repo:        repo,
+		contentRepo: contentRepo,
+		courseRepo:  courseRepo,
+		fileService: fileService,
+		tasks:       make(chan searchTask, searchQueueLen),
+	}
+}
+
+// Search 搜索笔记和课程，仅返回公开可见的内容
+// types 为空表示不限类型
+func (s *SearchService) Search(q string, types []string, page, pageSize int) ([]SearchResult, int64, error) {
+	q = strings.TrimSpace(q)
+	if utf8.RuneCountInString(q) > maxQueryLength {
+		q = string([]rune(q)[:maxQueryLength])
+	}
+	tokens := queryTokens(q)
+	if len(tokens) == 0 {
+		return nil, 0, errcode.NewWithMessage(errcode.CodeInvalidParam, "请输入搜索关键词")
+	}
+	for _, t := range types {
+		if t != model.SearchTypeNote && t != model.SearchTypeCourse {
+			return nil, 0, errcode.NewWithMessage(errcode.CodeInvalidParam, "不支持的搜索类型")
+		}
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 20
+	}
+
+	hits, total, err := s.repo.Search(tokens, types, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	terms := queryTerms(q, tokens)
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, SearchResult{
+			Type:       hit.DocType,
+			ID:         hit.RefID,
+			Title:      hit.Title,
+			Slug:       hit.Slug,
+			CoverImage: hit.CoverImage,
+			TitleHTML:  highlightText(hit.Title, terms),
+			Snippet:    searchSnippet(hit.Body, terms),
+		})
+	}
+	return results, total, nil
+}
+
+// ========== 索引维护 ==========
+
+// RefreshNote 笔记创建、修改或删除后更新索引（异步）
+func (s *SearchService) RefreshNote(id uint) {
+	s.enqueue(searchTask{docType: model.SearchTypeNote, id: id})
+}
+
+// RefreshCourse 课程或其介绍文件变更后更新索引（异步）
+func (s *SearchService) RefreshCourse(id uint) {
+	s.enqueue(searchTask{docType: model.SearchTypeCourse, id: id})
+}
+
+// RefreshNotes 批量更新笔记索引（如批量导入后），在后台依次投递，不受队列长度限制
+func (s *SearchService) RefreshNotes(ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	go func() {
+		for _, id := range ids {
+			s.tasks <- searchTask{docType: model.SearchTypeNote, id: id}
+		}
+	}()
+}
+
+// StartIndexer 启动索引更新协程；索引为空时（首次上线）先全量构建
+func (s *SearchService) StartIndexer() {
+	go func() {
+		if count, err := s.repo.CountSearchDocs(); err == nil && count == 0 {
+			if n, err := s.Rebuild(); err != nil {
+				log.Printf("build search index failed: %v", err)
+			} else {
+				log.Printf("search index built: %d docs", n)
+			}
+		}
+		for task := range s.tasks {
+			if err := s.index(task); err != nil {
+				log.Printf("update search index failed: %s %d: %v", task.docType, task.id, err)
+			}
+		}
+	}()
+}
+
+// Rebuild 重建全部笔记和课程的索引，返回写入的文档数
+func (s *SearchService) Rebuild() (int, error) {
+	if err := s.repo.ClearSearchDocs(); err != nil {
+		return 0, err
+	}
+
+	noteIDs, err := s.contentRepo.GetNoteIDs()
+	if err != nil {
+		return 0, err
+	}
+	courseIDs, err := s.courseRepo.GetCourseIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range noteIDs {
+		if err := s.index(searchTask{docType: model.SearchTypeNote, id: id}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	for _, id := range courseIDs {
+		if err := s.index(searchTask{docType: model.SearchTypeCourse, id: id}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// enqueue 投递索引任务；队列已满时丢弃，可通过 reindex 命令补齐
+func (s *SearchService) enqueue(task searchTask) {
+	select {
+	case s.tasks <- task:
+	default:
+		log.Printf("search index queue full, %s %d skipped", task.docType, task.id)
+	}
+}
+
+// index 按来源内容写入或删除索引文档
+// 草稿等未公开内容同样入索引，可见性在查询时判断，发布状态变化无需重建
+func (s *SearchService) index(task searchTask) error {
+	var title, body string
+
+	switch task.docType {
+	case model.SearchTypeNote:
+		note, err := s.contentRepo.GetNoteByID(task.id)
+		if err == gorm.ErrRecordNotFound {
+			return s.repo.DeleteSearchDoc(task.docType, task.id)
+		}
+		if err != nil {
+			return err
+		}
+		title = note.Title
+		content := note.Content
+		if note.IsRestricted() {
+			content = notePreview(content) // 受限笔记只索引节选，避免搜索摘要泄露全文
+		}
+		body = note.Summary + "\n\n" + content
+
+	case model.SearchTypeCourse:
+		course, err := s.courseRepo.GetCourseByID(task.id)
+		if err == gorm.ErrRecordNotFound {
+			return s.repo.DeleteSearchDoc(task.docType, task.id)
+		}
+		if err != nil {
+			return err
+		}
+		title = course.Title
+		body = course.Description
+		if course.IntroPath != "" {
+			intro, err := s.fileService.GetCourseIntroContent(course.ID)
+			if err != nil {
+				// 介绍文件读取失败时仍索引标题和简介，下次更新时补齐
+				log.Printf("load course intro for search failed: course=%d err=%v", course.ID, err)
+			}
+			body += "\n\n" + intro
+		}
+	}
+
+	body = markdownPlainText(body)
+	return s.repo.SaveSearchDoc(&model.SearchDoc{
+		DocType:     task.docType,
+		RefID:       task.id,
+		Body:        body,
+		TitleTokens: strings.Join(searchTokens(title), " "),
+		BodyTokens:  strings.Join(searchTokens(body), " "),
+	})
+}
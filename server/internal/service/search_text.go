@@ -0,0 +1,212 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxSearchTokens  = 32  // 查询词切分后的最大词数
+	maxTokenLength   = 64  // 单个英文/数字词的最大长度
+	snippetBefore    = 30  // 摘要片段命中位置之前保留的字数
+	snippetLength    = 120 // 摘要片段总字数
+	highlightOpenTag = "<mark>"
+	highlightEndTag  = "</mark>"
+)
+
+// isCJK 中日韩文字，按二元切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// searchTokens 将文本切分为检索词：
+// 连续的中日韩文字按相邻二字切分（"赛车调校" → 赛车 车调 调校），单字保留原样；
+// 英文和数字按单词切分并转为小写；其余字符视为分隔符
+func searchTokens(text string) []string {
+	var tokens []string
+	var word, cjk []rune
+
+	flush := func() {
+		if len(word) > 0 {
+			if len(word) > maxTokenLength {
+				word = word[:maxTokenLength]
+			}
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+		if len(cjk) == 1 {
+			tokens = append(tokens, string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// queryTokens 切分搜索关键词，去重并限制数量
+func queryTokens(q string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, token := range searchTokens(q) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+		if len(tokens) >= maxSearchTokens {
+			break
+		}
+	}
+	return tokens
+}
+
+// queryTerms 用于高亮的关键词：用户输入的原始词组和切分后的词，长词优先匹配
+func queryTerms(q string, tokens []string) [][]rune {
+	words := append([]string{}, tokens...)
+	words = append(words, strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !isCJK(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})...)
+
+	terms := make([][]rune, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, []rune(word))
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i]) > len(terms[j])
+	})
+	return terms
+}
+
+var (
+	mdCodeFence  = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdHTMLTag    = regexp.MustCompile(`<[^>]+>`)
+	mdLinePrefix = regexp.MustCompile(`(?m)^\s*(#{1,6}\s+|>\s*|[-*+]\s+|\d+\.\s+)`)
+	mdEmphasis   = regexp.MustCompile("[*_`~]+")
+	mdSpaces     = regexp.MustCompile(`\s+`)
+)
+
+// markdownPlainText 去除 Markdown 标记，保留可读文本用于索引和摘要
+func markdownPlainText(md string) string {
+	text := mdCodeFence.ReplaceAllString(md, "")
+	text = mdImage.ReplaceAllString(text, "$1")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdHTMLTag.ReplaceAllString(text, "")
+	text = mdLinePrefix.ReplaceAllString(text, "")
+	text = mdEmphasis.ReplaceAllString(text, "")
+	text = mdSpaces.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+// searchSnippet 截取正文中首个命中位置附近的片段，并高亮关键词
+func searchSnippet(body string, terms [][]rune) string {
+	runes := []rune(body)
+	lower := []rune(strings.ToLower(body))
+	if len(lower) != len(runes) {
+		runes = lower
+	}
+
+	start := 0
+	if pos := firstMatch(lower, terms); pos > snippetBefore {
+		start = pos - snippetBefore
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := highlight(runes[start:end], lower[start:end], terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// highlightText 对完整文本（如标题）高亮关键词
+func highlightText(text string, terms [][]rune) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		runes = lower
+	}
+	return highlight(runes, lower, terms)
+}
+
+// highlight 转义 HTML 后用 <mark> 包裹命中的关键词
+func highlight(runes, lower []rune, terms [][]rune) string {
+	var b strings.Builder
+	plain := 0
+	for i := 0; i < len(lower); {
+		n := matchAt(lower, i, terms)
+		if n == 0 {
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[plain:i])))
+		b.WriteString(highlightOpenTag)
+		b.WriteString(html.EscapeString(string(runes[i : i+n])))
+		b.WriteString(highlightEndTag)
+		i += n
+		plain = i
+	}
+	b.WriteString(html.EscapeString(string(runes[plain:])))
+	return b.String()
+}
+
+// firstMatch 返回首个关键词的位置，未命中返回 -1
+func firstMatch(lower []rune, terms [][]rune) int {
+	for i := range lower {
+		if matchAt(lower, i, terms) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchAt 返回在位置 i 命中的最长关键词长度（terms 已按长度降序排列）
+func matchAt(lower []rune, i int, terms [][]rune) int {
+	for _, term := range terms {
+		if len(term) == 0 || i+len(term) > len(lower) {
+			continue
+		}
+		matched := true
+		for j, r := range term {
+			if lower[i+j] != r {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return len(term)
+		}
+	}
+	return 0
+}
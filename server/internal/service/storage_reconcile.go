@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"car4race/internal/model"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 新写入的对象可能还在等待数据库记录（普通上传先写对象再写记录），
+// 晚于该时间的对象不视为孤儿
+const orphanMinAge = time.Hour
+
+// reconcilePrefixes 一致性检查扫描的对象目录
+var reconcilePrefixes = []string{"courses/", "hls/", "certificates/", imageObjectPrefix}
+
+// imageHashPattern 图片对象及引用地址中的 SHA-256
+var imageHashPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// OrphanObject MinIO 中没有数据库记录的对象
+type OrphanObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ReconcileReport 存储一致性检查报告
+type ReconcileReport struct {
+	DryRun         bool               `json:"dry_run"`
+	ScannedObjects int                `json:"scanned_objects"`
+	ScannedRecords int                `json:"scanned_records"`
+	OrphanObjects  []OrphanObject     `json:"orphan_objects"`  // 有对象无记录
+	UnusedImages   []OrphanObject     `json:"unused_images"`   // 没有被引用的图片，仅报告
+	MissingObjects []model.CourseFile `json:"missing_objects"` // 有记录无对象
+	DeletedCourses []model.CourseFile `json:"deleted_courses"` // 已删除课程仍有对象
+	BrokenIntros   []uint             `json:"broken_intros"`   // IntroPath 指向不存在对象的课程
+	Errors         []string           `json:"errors"`
+}
+
+// ReconcileStorage 比对 MinIO 与数据库记录，apply 为 false 时只输出报告
+// courses/ 对应课程文件与待确认上传，hls/ 对应视频资源，certificates/ 对应证书，
+// images/ 对应各文本字段和课程介绍中的图片地址。
+// 图片在编辑器中上传后，草稿保存前不会出现在任何文本里，也可能被站外引用，
+// 因此未引用的图片只报告，apply 时不删除
+func (s *FileService) ReconcileStorage(apply bool) (*ReconcileReport, error) {
+	ctx := context.Background()
+	report := &ReconcileReport{
+		DryRun:         !apply,
+		OrphanObjects:  []OrphanObject{},
+		UnusedImages:   []OrphanObject{},
+		MissingObjects: []model.CourseFile{},
+		DeletedCourses: []model.CourseFile{},
+		BrokenIntros:   []uint{},
+		Errors:         []string{},
+	}
+
+	// 列出各目录下的全部对象
+	objects := make(map[string]minio.ObjectInfo)
+	for _, prefix := range reconcilePrefixes {
+		for obj := range s.minioClient.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+			Prefix:    prefix,
+			Recursive: true,
+		}) {
+			if obj.Err != nil {
+				return nil, fmt.Errorf("列出对象失败: %v", obj.Err)
+			}
+			objects[obj.Key] = obj
+		}
+	}
+	report.ScannedObjects = len(objects)
+
+	files, err := s.courseRepo.GetAllCourseFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件记录失败: %v", err)
+	}
+	report.ScannedRecords = len(files)
+
+	deletedIDs, err := s.courseRepo.GetDeletedCourseIDs()
+	if err != nil {
+		return nil, fmt.Errorf("读取已删除课程失败: %v", err)
+	}
+	deletedCourses := make(map[uint]bool, len(deletedIDs))
+	for _, id := range deletedIDs {
+		deletedCourses[id] = true
+	}
+
+	pendingKeys, err := s.courseRepo.GetPendingUploadKeys()
+	if err != nil {
+		return nil, fmt.Errorf("读取待确认上传失败: %v", err)
+	}
+
+	certPaths, err := s.courseRepo.GetCertificatePaths()
+	if err != nil {
+		return nil, fmt.Errorf("读取证书记录失败: %v", err)
+	}
+
+	hlsPrefixes, err := s.videoRepo.GetAssetPrefixes()
+	if err != nil {
+		return nil, fmt.Errorf("读取视频资源失败: %v", err)
+	}
+	assetDirs := make(map[string]bool, len(hlsPrefixes))
+	for _, prefix := range hlsPrefixes {
+		assetDirs[strings.TrimSuffix(prefix, "/")] = true
+	}
+
+	known := make(map[string]bool, len(files)+len(pendingKeys)+len(certPaths))
+	for _, key := range pendingKeys {
+		known[key] = true
+	}
+	for _, key := range certPaths {
+		known[key] = true
+	}
+
+	// 有记录的文件：对象缺失 / 课程已删除
+	for _, file := range files {
+		known[file.FilePath] = true
+		_, exists := objects[file.FilePath]
+		switch {
+		case !exists:
+			report.MissingObjects = append(report.MissingObjects, file)
+		case deletedCourses[file.CourseID]:
+			report.DeletedCourses = append(report.DeletedCourses, file)
+		}
+	}
+
+	// IntroPath 指向不存在的对象
+	courses, err := s.courseRepo.GetCoursesWithIntro()
+	if err != nil {
+		return nil, fmt.Errorf("读取课程失败: %v", err)
+	}
+	for _, course := range courses {
+		if _, exists := objects[course.IntroPath]; !exists {
+			report.BrokenIntros = append(report.BrokenIntros, course.ID)
+		}
+	}
+
+	imageRefs, err := s.referencedImageHashes(ctx, courses, objects, report)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有记录的对象
+	cutoff := time.Now().Add(-orphanMinAge)
+	for key, obj := range objects {
+		if obj.LastModified.After(cutoff) {
+			continue
+		}
+		orphan := OrphanObject{
+			Key:          key,
+			Size:         obj.Size,
+			LastModified: obj.LastModified,
+		}
+		switch {
+		case strings.HasPrefix(key, imageObjectPrefix):
+			if !imageRefs[imageHashPattern.FindString(key)] {
+				report.UnusedImages = append(report.UnusedImages, orphan)
+			}
+		case strings.HasPrefix(key, "hls/"):
+			if !underAssetDir(key, assetDirs) {
+				report.OrphanObjects = append(report.OrphanObjects, orphan)
+			}
+		case !known[key]:
+			report.OrphanObjects = append(report.OrphanObjects, orphan)
+		}
+	}
+
+	if apply {
+		s.applyReconcile(ctx, report)
+	}
+
+	return report, nil
+}
+
+// applyReconcile 按报告清理不一致的数据，失败项记录到 Errors
+func (s *FileService) applyReconcile(ctx context.Context, report *ReconcileReport) {
+	fail := func(format string, args ...interface{}) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	for _, obj := range report.OrphanObjects {
+		if err := s.minioClient.RemoveObject(ctx, s.bucket, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+			fail("删除孤儿对象 %s 失败: %v", obj.Key, err)
+		}
+	}
+
+	// 记录删除与后台删除文件走同一清理路径，课时关联和 HLS 文件一并处理
+	for i := range report.MissingObjects {
+		file := &report.MissingObjects[i]
+		if err := s.removeCourseFileRecord(file); err != nil {
+			fail("删除文件记录 %d 失败: %v", file.ID, err)
+		}
+	}
+
+	for i := range report.DeletedCourses {
+		file := &report.DeletedCourses[i]
+		if err := s.minioClient.RemoveObject(ctx, s.bucket, file.FilePath, minio.RemoveObjectOptions{}); err != nil {
+			fail("删除对象 %s 失败: %v", file.FilePath, err)
+			continue
+		}
+		if err := s.removeCourseFileRecord(file); err != nil {
+			fail("删除文件记录 %d 失败: %v", file.ID, err)
+		}
+	}
+
+	for _, courseID := range report.BrokenIntros {
+		if err := s.courseRepo.UpdateCourseIntroPath(courseID, ""); err != nil {
+			fail("清空课程 %d 介绍路径失败: %v", courseID, err)
+		}
+	}
+}
+
+// referencedImageHashes 收集数据库文本字段和 Markdown 课程介绍中引用的图片哈希
+// 介绍读取失败只记录错误，图片本身不会被删除
+func (s *FileService) referencedImageHashes(ctx context.Context, courses []model.Course, objects map[string]minio.ObjectInfo, report *ReconcileReport) (map[string]bool, error) {
+	texts, err := s.courseRepo.GetImageReferenceTexts(imagePublicPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片引用失败: %v", err)
+	}
+
+	for _, course := range courses {
+		if _, exists := objects[course.IntroPath]; !exists || !isMarkdownFile(course.IntroPath) {
+			continue
+		}
+		obj, err := s.minioClient.GetObject(ctx, s.bucket, course.IntroPath, minio.GetObjectOptions{})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("读取课程 %d 介绍失败: %v", course.ID, err))
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(obj, s.maxIntroSize))
+		obj.Close()
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("读取课程 %d 介绍失败: %v", course.ID, err))
+			continue
+		}
+		texts = append(texts, string(data))
+	}
+
+	refs := make(map[string]bool)
+	for _, text := range texts {
+		for _, hash := range imageHashPattern.FindAllString(text, -1) {
+			refs[hash] = true
+		}
+	}
+	return refs, nil
+}
+
+// underAssetDir 对象是否位于某个视频资源的 HLS 目录下（含清晰度子目录）
+func underAssetDir(key string, dirs map[string]bool) bool {
+	for dir := path.Dir(key); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if dirs[dir] {
+			return true
+		}
+	}
+	return false
+}
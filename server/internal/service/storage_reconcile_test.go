@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func TestRemoveCourseFileRecordClearsReferences(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewCourseRepository(db)
+	videoRepo := repository.NewVideoRepository(db)
+	// 对象存储不可达：HLS 文件删除失败只记日志，记录仍然清理
+	client, err := minio.New("127.0.0.1:1", &minio.Options{MaxRetries: 1})
+	if err != nil {
+		t.Fatalf("minio client: %v", err)
+	}
+	svc := &FileService{courseRepo: repo, videoRepo: videoRepo, minioClient: client, bucket: "test"}
+
+	course := model.Course{Title: "a", Slug: "a"}
+	if err := repo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	file := model.CourseFile{CourseID: course.ID, FileType: "intro", FileName: "a.md", FilePath: "courses/1/intro/a.md"}
+	if err := repo.SaveCourseFile(&file, true, 0); err != nil {
+		t.Fatalf("save file: %v", err)
+	}
+	chapter := model.Chapter{CourseID: course.ID, Title: "c"}
+	if err := repo.CreateChapter(&chapter); err != nil {
+		t.Fatalf("create chapter: %v", err)
+	}
+	lesson := model.Lesson{CourseID: course.ID, ChapterID: chapter.ID, Title: "l", FileID: &file.ID}
+	if err := repo.CreateLesson(&lesson); err != nil {
+		t.Fatalf("create lesson: %v", err)
+	}
+	if err := videoRepo.SaveAsset(&model.VideoAsset{CourseID: course.ID, FileID: file.ID}); err != nil {
+		t.Fatalf("save asset: %v", err)
+	}
+
+	if err := svc.removeCourseFileRecord(&file); err != nil {
+		t.Fatalf("remove record: %v", err)
+	}
+
+	if _, err := repo.GetCourseFileByID(file.ID); err == nil {
+		t.Error("course file record still exists")
+	}
+	if got, err := repo.GetLessonByID(lesson.ID); err != nil || got.FileID != nil {
+		t.Errorf("lesson file = %v, err = %v; want nil", got.FileID, err)
+	}
+	if got, err := repo.GetCourseByID(course.ID); err != nil || got.IntroPath != "" {
+		t.Errorf("intro path = %q, err = %v; want empty", got.IntroPath, err)
+	}
+	if _, err := videoRepo.GetAssetByFileID(file.ID); err == nil {
+		t.Error("video asset still exists")
+	}
+}
+
+func TestUnderAssetDir(t *testing.T) {
+	dirs := map[string]bool{"hls/courses/1/2": true}
+	cases := map[string]bool{
+		"hls/courses/1/2/index.m3u8":  true,
+		"hls/courses/1/2/720p/seg.ts": true,
+		"hls/courses/1/20/index.m3u8": false,
+		"hls/courses/1/3/720p/seg.ts": false,
+		"hls/courses/1/index.m3u8":    false,
+	}
+	for key, want := range cases {
+		if got := underAssetDir(key, dirs); got != want {
+			t.Errorf("underAssetDir(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxTagsPerItem = 10 // 单篇笔记/单门课程最多标签数
+	tagLandingSize = 12 // 标签页每类内容展示数量，更多内容通过列表接口按标签分页
+)
+
+// TagLanding 标签页：标签信息及其下的笔记和课程
+type TagLanding struct {
+	Tag         *model.Tag     `json:"tag"`
+	Notes       []model.Note   `json:"notes"`
+	NoteTotal   int64          `json:"note_total"`
+	Courses     []model.Course `json:"courses"`
+	CourseTotal int64          `json:"course_total"`
+}
+
+type TagService struct {
+	repo        *repository.TagRepository
+	contentRepo *repository.ContentRepository
+	courseRepo  *repository.CourseRepository
+}
+
+func NewTagService(repo *repository.TagRepository, contentRepo *repository.ContentRepository, courseRepo *repository.CourseRepository) *TagService {
+	return &TagService{
+		repo:        repo,
+		contentRepo: contentRepo,
+		courseRepo:  courseRepo,
+	}
+}
+
+// GetTagCloud 标签云：有公开内容的标签及数量
+func (s *TagService) GetTagCloud() ([]repository.TagWithCount, error) {
+	return s.repo.GetTagsWithCount(true)
+}
+
+// GetAllTags 获取全部标签（管理后台）
+func (s *TagService) GetAllTags() ([]repository.TagWithCount, error) {
+	return s.repo.GetTagsWithCount(false)
+}
+
+// GetTagLanding 获取标签页数据
+func (s *TagService) GetTagLanding(slug string) (*TagLanding, error) {
+	tag, err := s.repo.GetTagBySlug(slug)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "标签不存在")
+	}
+
+	notes, noteTotal, err := s.contentRepo.GetNotes(nil, tag.Slug, 1, tagLandingSize)
+	if err != nil {
+		return nil, err
+	}
+	redactRestrictedNotes(notes)
+	courses, courseTotal, err := s.courseRepo.GetCourses(1, tagLandingSize, "sales", tag.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TagLanding{
+		Tag:         tag,
+		Notes:       notes,
+		NoteTotal:   noteTotal,
+		Courses:     courses,
+		CourseTotal: courseTotal,
+	}, nil
+}
+
+// CreateTag 创建标签
+func (s *TagService) CreateTag(tag *model.Tag) error {
+	if err := s.validateTag(tag); err != nil {
+		return err
+	}
+	return s.repo.CreateTag(tag)
+}
+
+// UpdateTag 更新标签
+func (s *TagService) UpdateTag(tag *model.Tag) error {
+	existing, err := s.repo.GetTagByID(tag.ID)
+	if err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "标签不存在")
+	}
+	if err := s.validateTag(tag); err != nil {
+		return err
+	}
+	tag.CreatedAt = existing.CreatedAt
+	return s.repo.UpdateTag(tag)
+}
+
+// DeleteTag 删除标签（同时解除与笔记、课程的关联）
+func (s *TagService) DeleteTag(id uint) error {
+	if _, err := s.repo.GetTagByID(id); err != nil {
+		return errcode.NewWithMessage(errcode.CodeNotFound, "标签不存在")
+	}
+	return s.repo.DeleteTag(id)
+}
+
+// ResolveTags 校验并加载标签；ids 为 nil 时返回 nil（表示不修改标签）
+func (s *TagService) ResolveTags(ids []uint) ([]model.Tag, error) {
+	if ids == nil {
+		return nil, nil
+	}
+	ids = uniqueIDs(ids)
+	if len(ids) > maxTagsPerItem {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "标签数量过多")
+	}
+	if len(ids) == 0 {
+		return []model.Tag{}, nil
+	}
+
+	tags, err := s.repo.GetTagsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(ids) {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "标签不存在")
+	}
+	return tags, nil
+}
+
+// ResolveTagNames 按名称或 slug 查找标签，不存在的自动创建（用于批量导入）
+// names 为 nil 时返回 nil（表示不修改标签）
+func (s *TagService) ResolveTagNames(names []string) ([]model.Tag, error) {
+	if names == nil {
+		return nil, nil
+	}
+
+	var unique []string
+	seenName := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !seenName[name] {
+			seenName[name] = true
+			unique = append(unique, name)
+		}
+	}
+	if len(unique) > maxTagsPerItem {
+		return nil, errcode.NewWithMessage(errcode.CodeInvalidParam, "标签数量过多")
+	}
+
+	tags := []model.Tag{}
+	seen := make(map[uint]bool)
+	for _, name := range unique {
+		slug := slugify(name)
+		if slug == "" {
+			// 纯中文等无法生成 slug 的名称，用名称哈希
+			sum := sha1.Sum([]byte(name))
+			slug = "tag-" + hex.EncodeToString(sum[:4])
+		}
+		tag, err := s.repo.GetTagByNameOrSlug(name, slug)
+		if err == gorm.ErrRecordNotFound {
+			tag = &model.Tag{Name: name, Slug: slug}
+			err = s.CreateTag(tag)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			tags = append(tags, *tag)
+		}
+	}
+	return tags, nil
+}
+
+// SetNoteTags 设置笔记标签
+func (s *TagService) SetNoteTags(noteID uint, tags []model.Tag) error {
+	return s.repo.ReplaceNoteTags(noteID, tags)
+}
+
+// SetCourseTags 设置课程标签
+func (s *TagService) SetCourseTags(courseID uint, tags []model.Tag) error {
+	return s.repo.ReplaceCourseTags(courseID, tags)
+}
+
+// validateTag 校验标签名称与 slug
+func (s *TagService) validateTag(tag *model.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	tag.Slug = strings.TrimSpace(tag.Slug)
+	if tag.Name == "" || tag.Slug == "" {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "标签名称和 slug 不能为空")
+	}
+
+	exists, err := s.repo.TagExists(tag.Name, tag.Slug, tag.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errcode.NewWithMessage(errcode.CodeInvalidParam, "标签名称或 slug 已存在")
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"car4race/internal/config"
+	"car4race/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 在临时目录中创建已迁移的数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := repository.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	db.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newTestCourseService 基于临时数据库创建课程服务
+func newTestCourseService(t *testing.T) (*CourseService, *repository.CourseRepository) {
+	t.Helper()
+	db := newTestDB(t)
+	repo := repository.NewCourseRepository(db)
+	return NewCourseService(repo, repository.NewUserRepository(db), &config.Config{JWTSecret: "test"}), repo
+}
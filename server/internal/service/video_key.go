@@ -0,0 +1,234 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+)
+
+const (
+	keyRequestLimit  = 20               // 每个用户每个视频在窗口内允许的密钥请求数
+	keyRequestWindow = 10 * time.Minute // 密钥请求限流窗口
+)
+
+// currentCourseKey 获取课程当前密钥，超过轮换周期时生成新密钥
+func (s *VideoService) currentCourseKey(courseID uint) (*model.VideoKey, error) {
+	key, err := s.repo.GetLatestCourseKey(courseID)
+	if err == nil && (s.keyRotate <= 0 || time.Since(key.CreatedAt) < s.keyRotate) {
+		return key, nil
+	}
+	return s.newCourseKey(courseID)
+}
+
+// newCourseKey 生成新的 AES-128 密钥和 IV
+func (s *VideoService) newCourseKey(courseID uint) (*model.VideoKey, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %v", err)
+	}
+	key := &model.VideoKey{
+		CourseID: courseID,
+		Key:      hex.EncodeToString(buf[:16]),
+		IV:       hex.EncodeToString(buf[16:]),
+	}
+	if err := s.repo.CreateKey(key); err != nil {
+		return nil, fmt.Errorf("保存密钥失败: %v", err)
+	}
+	return key, nil
+}
+
+// RotateCourseKey 立即轮换课程密钥，并将课程全部视频重新转码加密
+// 已可播放的视频在新版本完成前继续使用旧密钥播放，未完成转码的视频直接使用新密钥重新排队
+func (s *VideoService) RotateCourseKey(courseID uint) (int, error) {
+	if _, err := s.courseRepo.GetCourseByID(courseID); err != nil {
+		return 0, errcode.New(errcode.CodeCourseNotFound)
+	}
+	key, err := s.newCourseKey(courseID)
+	if err != nil {
+		return 0, err
+	}
+
+	assets, err := s.repo.GetCourseAssets(courseID)
+	if err != nil {
+		return 0, err
+	}
+	for i := range assets {
+		if assets[i].Status == "ready" {
+			assets[i].PendingKey = key.ID
+		} else {
+			assets[i].Status = "pending"
+		}
+		assets[i].Error = ""
+		if err := s.repo.SaveAsset(&assets[i]); err != nil {
+			return 0, err
+		}
+		s.enqueue(assets[i].ID)
+	}
+	return len(assets), nil
+}
+
+// writeKeyInfo 在工作目录写入 ffmpeg 的 key info 文件（密钥文件不会上传到对象存储）
+// 播放列表中的密钥地址指向密钥接口，播放时再附加签名参数
+func writeKeyInfo(workDir string, assetID uint, key *model.VideoKey) (string, error) {
+	raw, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return "", fmt.Errorf("密钥格式错误: %v", err)
+	}
+	keyPath := filepath.Join(workDir, "enc.key")
+	if err := os.WriteFile(keyPath, raw, 0600); err != nil {
+		return "", err
+	}
+
+	keyURI := fmt.Sprintf("/api/v1/hpa/videos/keys/%d?asset=%d", key.ID, assetID)
+	infoPath := filepath.Join(workDir, "enc.keyinfo")
+	content := fmt.Sprintf("%s\n%s\n%s\n", keyURI, keyPath, key.IV)
+	if err := os.WriteFile(infoPath, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return infoPath, nil
+}
+
+// KeyRequest 密钥请求上下文
+type KeyRequest struct {
+	KeyID     uint
+	Query     url.Values // 播放签名参数及 asset
+	IP        string
+	UserAgent string
+}
+
+// DeliverKey 校验播放签名、访问权限和请求频率后返回密钥，每次请求都会记录日志
+// 播放器请求密钥时不携带登录凭证，签名只证明链接由 AuthorizePlayback 签发给 uid；
+// 退款或撤销后签名仍在有效期内，因此每次都按 uid 重新校验购买（或试看、管理员）
+func (s *VideoService) DeliverKey(req *KeyRequest) ([]byte, error) {
+	assetID, _ := strconv.ParseUint(req.Query.Get("asset"), 10, 64)
+	asset, err := s.VerifyStream(uint(assetID), req.Query)
+	if err != nil {
+		return nil, err
+	}
+	if asset.KeyID != req.KeyID {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "密钥不存在")
+	}
+	uid, _ := strconv.ParseUint(req.Query.Get("uid"), 10, 64)
+	if !s.courseService.CanAccessFile(uint(uid), asset.CourseID, asset.FileID) && !s.courseService.IsAdmin(uint(uid)) {
+		return nil, errcode.New(errcode.CodeNotPurchased)
+	}
+
+	session := req.Query.Get("sig")
+	if len(session) > 16 {
+		session = session[:16]
+	}
+	// 按用户和视频限流：重新请求 /play 会得到新签名，不能按签名限流
+	// 未登录的试看用户共用 uid 0，再按 IP 区分
+	limitKey := fmt.Sprintf("%d:%d", uid, asset.ID)
+	if uid == 0 {
+		limitKey += ":" + req.IP
+	}
+	allowed := s.keyLimiter.Allow(limitKey)
+
+	access := &model.VideoKeyAccess{
+		UserID:    uint(uid),
+		CourseID:  asset.CourseID,
+		AssetID:   asset.ID,
+		KeyID:     req.KeyID,
+		Session:   session,
+		IP:        req.IP,
+		UserAgent: truncate(req.UserAgent, 300),
+		Blocked:   !allowed,
+	}
+	if err := s.repo.CreateKeyAccess(access); err != nil {
+		log.Printf("record key access failed: %v", err)
+	}
+
+	if !allowed {
+		log.Printf("video key rate limited: user=%d asset=%d session=%s ip=%s", uid, asset.ID, session, req.IP)
+		return nil, errcode.New(errcode.CodeRateLimitExceed)
+	}
+
+	key, err := s.repo.GetKeyByID(req.KeyID)
+	if err != nil {
+		return nil, errcode.NewWithMessage(errcode.CodeNotFound, "密钥不存在")
+	}
+	raw, err := hex.DecodeString(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("密钥格式错误: %v", err)
+	}
+	return raw, nil
+}
+
+// GetKeyAccessStats 统计最近一段时间各用户的密钥请求，用于发现批量盗录
+func (s *VideoService) GetKeyAccessStats(hours int) ([]repository.KeyAccessStat, error) {
+	if hours < 1 || hours > 24*30 {
+		hours = 24
+	}
+	return s.repo.GetKeyAccessStats(time.Now().Add(-time.Duration(hours)*time.Hour), 100)
+}
+
+// sessionLimiter 按会话的滑动窗口限流
+type sessionLimiter struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	limit    int
+	window   time.Duration
+}
+
+func newSessionLimiter(limit int, window time.Duration) *sessionLimiter {
+	return &sessionLimiter{
+		requests: make(map[string][]time.Time),
+		limit:    limit,
+		window:   window,
+	}
+}
+
+// Allow 记录一次请求并返回是否未超限
+func (l *sessionLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-l.window)
+
+	// 会话数量过多时整体清理一次过期记录
+	if len(l.requests) > 10000 {
+		for k, times := range l.requests {
+			if len(times) == 0 || times[len(times)-1].Before(windowStart) {
+				delete(l.requests, k)
+			}
+		}
+	}
+
+	var valid []time.Time
+	for _, t := range l.requests[key] {
+		if t.After(windowStart) {
+			valid = append(valid, t)
+		}
+	}
+	if len(valid) >= l.limit {
+		l.requests[key] = valid
+		return false
+	}
+	l.requests[key] = append(valid, now)
+	return true
+}
+
+// truncate 按字节截断字符串（保证不截断 UTF-8 字符）
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
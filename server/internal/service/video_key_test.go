@@ -0,0 +1,104 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"car4race/internal/config"
+	"car4race/internal/model"
+	"car4race/internal/repository"
+	"car4race/pkg/errcode"
+)
+
+func TestRotateCourseKeyKeepsReadyAssetsPlayable(t *testing.T) {
+	db := newTestDB(t)
+	courseRepo := repository.NewCourseRepository(db)
+	videoRepo := repository.NewVideoRepository(db)
+	svc := NewVideoService(videoRepo, courseRepo, nil, nil, &config.Config{})
+
+	course := model.Course{Title: "a", Slug: "a"}
+	if err := courseRepo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	oldKey, err := svc.newCourseKey(course.ID)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	// 课程文件不存在，重新转码必然失败
+	ready := model.VideoAsset{CourseID: course.ID, FileID: 1, Status: "ready", Prefix: "hls/courses/1/1/v1/", KeyID: oldKey.ID}
+	failed := model.VideoAsset{CourseID: course.ID, FileID: 2, Status: "failed", Error: "boom"}
+	for _, asset := range []*model.VideoAsset{&ready, &failed} {
+		if err := videoRepo.SaveAsset(asset); err != nil {
+			t.Fatalf("save asset: %v", err)
+		}
+	}
+
+	if n, err := svc.RotateCourseKey(course.ID); err != nil || n != 2 {
+		t.Fatalf("rotate = %d, %v; want 2 assets", n, err)
+	}
+	got, _ := videoRepo.GetAssetByID(ready.ID)
+	if got.Status != "ready" || got.KeyID != oldKey.ID || got.PendingKey == 0 || got.PendingKey == oldKey.ID {
+		t.Errorf("ready asset after rotate = %+v; want still ready on the old key with a new pending key", got)
+	}
+	if got, _ := videoRepo.GetAssetByID(failed.ID); got.Status != "pending" {
+		t.Errorf("failed asset status = %q, want pending", got.Status)
+	}
+
+	// 重新加密失败时继续使用旧版本播放
+	svc.process(ready.ID)
+	got, _ = videoRepo.GetAssetByID(ready.ID)
+	if got.Status != "ready" || got.KeyID != oldKey.ID || got.Prefix != ready.Prefix || got.PendingKey != 0 || got.Error == "" {
+		t.Errorf("ready asset after failed re-encrypt = %+v; want old version kept with the error recorded", got)
+	}
+}
+
+func TestDeliverKeyRechecksPurchaseAndLimitsPerUser(t *testing.T) {
+	db := newTestDB(t)
+	courseRepo := repository.NewCourseRepository(db)
+	videoRepo := repository.NewVideoRepository(db)
+	courseService := NewCourseService(courseRepo, repository.NewUserRepository(db), &config.Config{JWTSecret: "test"})
+	svc := NewVideoService(videoRepo, courseRepo, courseService, nil, &config.Config{HLSSignSecret: "test"})
+
+	course := model.Course{Title: "a", Slug: "a", Price: 10}
+	if err := courseRepo.CreateCourse(&course); err != nil {
+		t.Fatalf("create course: %v", err)
+	}
+	key, err := svc.newCourseKey(course.ID)
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	asset := model.VideoAsset{CourseID: course.ID, FileID: 1, Status: "ready", KeyID: key.ID}
+	if err := videoRepo.SaveAsset(&asset); err != nil {
+		t.Fatalf("save asset: %v", err)
+	}
+	payCourse(t, courseRepo, 1, course.ID)
+
+	calls := 0
+	request := func() error {
+		// 每次都像重新调用 /play 一样使用新的签名
+		calls++
+		query := svc.signQuery(asset.ID, 1, time.Now().Add(time.Hour).Unix()+int64(calls))
+		query.Set("asset", strconv.FormatUint(uint64(asset.ID), 10))
+		_, err := svc.DeliverKey(&KeyRequest{KeyID: key.ID, Query: query, IP: "127.0.0.1"})
+		return err
+	}
+
+	for i := 0; i < keyRequestLimit; i++ {
+		if err := request(); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := request(); !errcode.Is(err, errcode.CodeRateLimitExceed) {
+		t.Errorf("request over the limit with a fresh signature: err = %v, want rate limited", err)
+	}
+
+	// 退款后签名仍有效，但不再下发密钥
+	svc.keyLimiter = newSessionLimiter(keyRequestLimit, keyRequestWindow)
+	if err := db.Model(&model.Order{}).Where("user_id = ?", 1).Update("status", "refunded").Error; err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if err := request(); !errcode.Is(err, errcode.CodeNotPurchased) {
+		t.Errorf("request after refund: err = %v, want not purchased", err)
+	}
+}
@@ -14,6 +14,11 @@ const (
 	CodeRateLimitExceed  = 40010 // 请求过于频繁
 	CodeQueueRequired    = 40011 // 当前访问人数较多，请稍后再试（保留）
 
+	// 文件错误 400xx
+	CodeFileTypeNotAllowed = 40012 // 文件格式不允许
+	CodeFileTooLarge       = 40013 // 文件过大
+	CodeFileDuplicate      = 40014 // 文件已存在
+
 	// 下载错误 400xx
 	CodeDownloadExpired  = 40003 // 下载链接已过期
 	CodeDownloadExceeded = 40004 // 下载次数已用完
@@ -28,6 +33,10 @@ const (
 	CodeNotFound       = 40401 // 资源不存在
 	CodeUserNotFound   = 40402 // 用户不存在
 	CodeCourseNotFound = 40403 // 课程不存在
+
+	// 冲突错误 409xx
+	CodeVersionConflict  = 40901 // 内容已被他人修改
+	CodeCategoryNotEmpty = 40902 // 分类下仍有内容
 )
 
 // 错误码对应的消息
@@ -39,6 +48,9 @@ var codeMessages = map[int]string{
 	CodeInvalidInvite:    "邀请码无效或已被使用",
 	CodeRateLimitExceed:  "请求过于频繁，请稍后再试",
 	CodeQueueRequired:    "当前访问人数较多，请稍后再试",
+	CodeFileTypeNotAllowed: "不支持的文件格式",
+	CodeFileTooLarge:     "文件过大",
+	CodeFileDuplicate:    "文件已存在",
 	CodeDownloadExpired:  "下载链接已过期",
 	CodeDownloadExceeded: "下载次数已用完，请联系客服",
 	CodeForbidden:        "无权限",
@@ -48,6 +60,8 @@ var codeMessages = map[int]string{
 	CodeNotFound:         "资源不存在",
 	CodeUserNotFound:     "用户不存在",
 	CodeCourseNotFound:   "课程不存在",
+	CodeVersionConflict:  "内容已被他人修改，请刷新后重试",
+	CodeCategoryNotEmpty: "分类下仍有子分类或笔记",
 }
 
 // Message 获取错误码对应的消息
@@ -66,6 +66,8 @@ func getHTTPCode(code int) int {
 		return 403 // 禁止访问
 	case code >= 40401 && code < 40500:
 		return 404 // 资源不存在
+	case code >= 40901 && code < 41000:
+		return 409 // 资源冲突
 	case code == errcode.CodeRateLimitExceed:
 		return 429 // 请求过多
 	default: